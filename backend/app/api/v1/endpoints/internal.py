@@ -158,7 +158,7 @@ async def update_job_status(
     if update.error_message:
         job.error_message = update.error_message
     
-    if update.status in ("completed", "failed", "cancelled"):
+    if update.status in ("completed", "failed", "cancelled", "timeout"):
         job.finished_at = datetime.now(timezone.utc)
     
     await db.commit()
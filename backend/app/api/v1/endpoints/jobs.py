@@ -202,7 +202,7 @@ async def create_job(
 
     - **name**: Job name for identification
     - **command**: Command to execute
-    - **job_type**: Execution environment (docker/conda/venv)
+    - **job_type**: Execution environment (docker/conda/venv/apptainer)
     - **image**: Docker image (for docker jobs)
     - **node_id**: Target node (optional, auto-assigned if not specified)
     - **cpu_limit**: CPU core limit
@@ -344,7 +344,12 @@ async def cancel_job(
             detail="Not allowed to cancel this job",
         )
 
-    if job.status in [JobStatus.COMPLETED.value, JobStatus.FAILED.value, JobStatus.CANCELLED.value]:
+    if job.status in [
+        JobStatus.COMPLETED.value,
+        JobStatus.FAILED.value,
+        JobStatus.CANCELLED.value,
+        JobStatus.TIMEOUT.value,
+    ]:
         raise HTTPException(
             status_code=status.HTTP_400_BAD_REQUEST,
             detail=f"Cannot cancel job in {job.status} status",
@@ -244,7 +244,7 @@ async def node_heartbeat(
     Receive heartbeat from worker node.
 
     This endpoint is called periodically by the worker agent to report:
-    - Node status (online/offline)
+    - Node status (online, or unhealthy with a reason)
     - Resource metrics (CPU, memory, GPU, storage)
     """
     result = await db.execute(select(Node).where(Node.node_id == node_id))
@@ -257,6 +257,7 @@ async def node_heartbeat(
 
     # Update node status and info
     node.status = heartbeat.status.value
+    node.status_reason = heartbeat.status_reason
     node.last_heartbeat = datetime.now(timezone.utc)
 
     if heartbeat.cpu_count is not None:
@@ -20,6 +20,7 @@ class JobStatus(str, Enum):
     COMPLETED = "completed"
     FAILED = "failed"
     CANCELLED = "cancelled"
+    TIMEOUT = "timeout"
 
 
 class JobType(str, Enum):
@@ -28,6 +29,7 @@ class JobType(str, Enum):
     DOCKER = "docker"
     CONDA = "conda"
     VENV = "venv"
+    APPTAINER = "apptainer"
 
 
 class Job(Base):
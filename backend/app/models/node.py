@@ -28,6 +28,7 @@ class NodeStatus(str, Enum):
     ONLINE = "online"
     OFFLINE = "offline"
     MAINTENANCE = "maintenance"
+    UNHEALTHY = "unhealthy"
 
 
 class Node(Base):
@@ -43,6 +44,7 @@ class Node(Base):
     hostname: Mapped[str | None] = mapped_column(String(255))  # Hostname for agent communication
     port: Mapped[int] = mapped_column(Integer, default=8000)
     status: Mapped[str] = mapped_column(String(20), default=NodeStatus.OFFLINE.value)
+    status_reason: Mapped[str | None] = mapped_column(Text)  # Why the agent reports itself unhealthy
     is_active: Mapped[bool] = mapped_column(Boolean, default=True)
 
     # Agent configuration
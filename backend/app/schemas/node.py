@@ -69,6 +69,11 @@ class NodeHeartbeat(BaseModel):
         default=NodeStatus.ONLINE,
         description="Current node status",
     )
+    status_reason: str | None = Field(
+        None,
+        description="Why the node reports itself unhealthy",
+        examples=["GPU count dropped from 4 to 3"],
+    )
     cpu_count: int | None = Field(
         None,
         description="Number of CPU cores",
@@ -108,6 +113,7 @@ class NodeRead(NodeBase):
     node_id: str = Field(..., description="Unique node identifier")
     node_type: NodeType = Field(..., description="Node type")
     status: NodeStatus = Field(..., description="Current status")
+    status_reason: str | None = Field(None, description="Why the node is unhealthy")
     is_active: bool = Field(..., description="Whether node is active")
     hostname: str | None = Field(None, description="Hostname for agent communication")
     agent_port: int | None = Field(8081, description="Worker agent HTTP API port")
@@ -111,7 +111,7 @@ class JobService:
         if status == JobStatus.RUNNING and not job.started_at:
             job.started_at = datetime.now(UTC)
 
-        if status in [JobStatus.COMPLETED, JobStatus.FAILED, JobStatus.CANCELLED]:
+        if status in [JobStatus.COMPLETED, JobStatus.FAILED, JobStatus.CANCELLED, JobStatus.TIMEOUT]:
             job.completed_at = datetime.now(UTC)
 
         if exit_code is not None:
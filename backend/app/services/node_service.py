@@ -148,10 +148,10 @@ class NodeService:
         """
         threshold = datetime.now(UTC) - timedelta(seconds=timeout_seconds)
 
-        # Find nodes that are online but haven't sent heartbeat
+        # Find nodes that are online (or unhealthy) but haven't sent heartbeat
         result = await self.db.execute(
             select(Node).where(
-                Node.status == NodeStatus.ONLINE.value,
+                Node.status.in_([NodeStatus.ONLINE.value, NodeStatus.UNHEALTHY.value]),
                 Node.last_heartbeat < threshold,
             )
         )
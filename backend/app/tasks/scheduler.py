@@ -32,10 +32,10 @@ async def check_offline_nodes(timeout_seconds: int = 90) -> int:
                 seconds=timeout_seconds
             )
 
-            # Find nodes that are online but haven't sent heartbeat
+            # Find nodes that are online (or unhealthy) but haven't sent heartbeat
             result = await db.execute(
                 select(Node).where(
-                    Node.status == NodeStatus.ONLINE.value,
+                    Node.status.in_([NodeStatus.ONLINE.value, NodeStatus.UNHEALTHY.value]),
                     Node.is_active.is_(True),
                     Node.last_heartbeat < threshold,
                 )
@@ -119,6 +119,7 @@ async def cleanup_old_jobs(days: int = 30) -> int:
                         JobStatus.COMPLETED.value,
                         JobStatus.FAILED.value,
                         JobStatus.CANCELLED.value,
+                        JobStatus.TIMEOUT.value,
                     ]),
                     Job.completed_at < threshold,
                 )
@@ -1141,7 +1141,7 @@ export type JobStats = {
  *
  * Job status enumeration.
  */
-export type JobStatus = 'pending' | 'queued' | 'running' | 'completed' | 'failed' | 'cancelled'
+export type JobStatus = 'pending' | 'queued' | 'running' | 'completed' | 'failed' | 'cancelled' | 'timeout'
 
 /**
  * JobStatusUpdate
@@ -1184,7 +1184,7 @@ export type JobStatusUpdate = {
  *
  * Job execution environment type.
  */
-export type JobType = 'docker' | 'conda' | 'venv'
+export type JobType = 'docker' | 'conda' | 'venv' | 'apptainer'
 
 /**
  * JobUpdate
@@ -1268,6 +1268,12 @@ export type NodeHeartbeat = {
    * Current node status
    */
   status?: NodeStatus
+  /**
+   * Status Reason
+   *
+   * Why the node reports itself unhealthy
+   */
+  status_reason?: string | null
   /**
    * Cpu Count
    *
@@ -1350,6 +1356,12 @@ export type NodeRead = {
    * Current status
    */
   status: NodeStatus
+  /**
+   * Status Reason
+   *
+   * Why the node is unhealthy
+   */
+  status_reason?: string | null
   /**
    * Is Active
    *
@@ -1575,7 +1587,7 @@ export type NodeStats = {
  *
  * Node status enumeration.
  */
-export type NodeStatus = 'online' | 'offline' | 'maintenance'
+export type NodeStatus = 'online' | 'offline' | 'maintenance' | 'unhealthy'
 
 /**
  * NodeType
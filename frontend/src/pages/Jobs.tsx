@@ -272,6 +272,7 @@ const Jobs: React.FC = () => {
             { label: 'Docker', value: 'docker' },
             { label: 'Conda', value: 'conda' },
             { label: 'Venv', value: 'venv' },
+            { label: 'Apptainer', value: 'apptainer' },
           ]}
           rules={[{ required: true }]}
         />
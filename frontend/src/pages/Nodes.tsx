@@ -8,7 +8,7 @@ import {
   ProFormSelect,
   ProFormDigit,
 } from '@ant-design/pro-components'
-import { Tag, Button, Space, message, Popconfirm } from 'antd'
+import { Tag, Button, Space, message, Popconfirm, Tooltip } from 'antd'
 import { PlusOutlined, ReloadOutlined, DeleteOutlined } from '@ant-design/icons'
 import { useTranslation } from 'react-i18next'
 import {
@@ -25,6 +25,7 @@ const statusColorMap: Record<string, string> = {
   online: 'success',
   offline: 'default',
   maintenance: 'warning',
+  unhealthy: 'error',
 }
 
 const Nodes: React.FC = () => {
@@ -99,9 +100,11 @@ const Nodes: React.FC = () => {
       title: t('common.status'),
       dataIndex: 'status',
       render: (_, record) => (
-        <Tag color={statusColorMap[record.status] || 'default'}>
-          {record.status === 'online' ? t('nodes.online') : t('nodes.offline')}
-        </Tag>
+        <Tooltip title={record.status_reason}>
+          <Tag color={statusColorMap[record.status] || 'default'}>
+            {t(`nodes.status.${record.status}`)}
+          </Tag>
+        </Tooltip>
       ),
       width: 100,
     },
@@ -3,108 +3,269 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/YangYuS8/mlsmanager-worker/internal/api"
+	"github.com/YangYuS8/mlsmanager-worker/internal/audit"
+	"github.com/YangYuS8/mlsmanager-worker/internal/backoff"
 	"github.com/YangYuS8/mlsmanager-worker/internal/client"
 	"github.com/YangYuS8/mlsmanager-worker/internal/config"
 	"github.com/YangYuS8/mlsmanager-worker/internal/executor"
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+	"github.com/YangYuS8/mlsmanager-worker/internal/logging"
+	"github.com/YangYuS8/mlsmanager-worker/internal/metrics"
 	"github.com/YangYuS8/mlsmanager-worker/internal/scanner"
+	"github.com/YangYuS8/mlsmanager-worker/internal/sysinfo"
+	"github.com/YangYuS8/mlsmanager-worker/internal/version"
 )
 
 func main() {
+	// Invoked by git as a credential helper: agent git-credential <operation>
+	if len(os.Args) > 1 && os.Args[1] == "git-credential" {
+		os.Exit(runGitCredentialHelper(os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log("FATAL", "Failed to load configuration: %v", err)
-		os.Exit(1)
+		fatal("Failed to load configuration", "error", err)
+	}
+
+	// Structured logs for the aggregator; everything else logs through the default
+	logger, err := logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fatal("Failed to configure logging", "error", err)
+	}
+	slog.SetDefault(logger.With("node_name", cfg.NodeName))
+
+	// Site-specific accelerators take precedence over the built-in detectors
+	if cfg.GPUDetectCommand != "" {
+		sysinfo.RegisterGPUDetector(sysinfo.CommandDetector{Command: cfg.GPUDetectCommand})
+	}
+
+	// One-shot environment check: agent --preflight
+	if len(os.Args) > 1 && os.Args[1] == "--preflight" {
+		os.Exit(runPreflight(cfg))
+	}
+
+	// Catch unusable or overlapping paths before they cause runtime failures
+	if err := cfg.ValidatePaths(); err != nil {
+		fatal("Invalid paths", "error", err)
 	}
 
 	// Create context with cancellation for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Handle shutdown signals
+	// Jobs run under their own context so they can outlive the main loop
+	// while draining (AGENT_SHUTDOWN_DRAIN_SECONDS)
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	// Handle shutdown signals; a second one skips the drain
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		sig := <-sigChan
-		log("INFO", "Received signal %v, shutting down...", sig)
+		slog.Info("Received signal, shutting down...", "signal", sig.String())
 		cancel()
+
+		sig = <-sigChan
+		slog.Warn("Received signal again, cancelling running jobs...", "signal", sig.String())
+		cancelJobs()
 	}()
 
-	// Print startup banner
-	printBanner(cfg)
+	// SIGHUP reloads the configuration; the main loop applies it between ticks
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	// Log startup settings
+	logStartup(cfg)
 
 	// Create master client
-	masterClient := client.NewMasterClient(cfg)
+	masterClient, err := client.NewMasterClient(cfg)
+	if err != nil {
+		fatal("Failed to create master client", "error", err)
+	}
 
 	// Register with master if no token
 	if masterClient.Token() == "" {
-		log("INFO", "No token found, registering with master...")
-		if err := registerWithRetry(ctx, masterClient, 5); err != nil {
-			log("FATAL", "Failed to register: %v", err)
-			os.Exit(1)
+		slog.Info("No token found, registering with master...")
+		if err := registerWithRetry(ctx, cfg, masterClient); err != nil {
+			fatal("Failed to register", "error", err)
 		}
 	}
 
+	// Final job statuses from a master outage, possibly before a restart
+	go masterClient.RunStatusQueue(ctx)
+
 	// Create executor and scanner
 	exec := executor.NewExecutor(cfg, masterClient)
-	scan := scanner.NewScanner()
+	masterClient.SetGPUUsageProvider(exec.GPUUsage)
+	masterClient.SetRuntimeProvider(exec.UnavailableRuntimes)
+	sinks, err := executor.NewOutputSinks(cfg, masterClient)
+	if err != nil {
+		fatal("Invalid output sinks", "error", err)
+	}
+	exec.SetOutputSinks(sinks)
+
+	// Jobs left running by a previous agent process are reported before new work
+	exec.RecoverOrphans(ctx)
+	go exec.RunReaper(ctx, time.Duration(cfg.ZombieReapInterval)*time.Second)
+	formats, err := cfg.DatasetFormats()
+	if err != nil {
+		fatal("Invalid dataset formats", "error", err)
+	}
+	scan := scanner.NewScanner(scanner.Options{
+		RelativePaths: cfg.DatasetRelativePaths,
+		SettleWindow:  time.Duration(cfg.DatasetSettleSeconds) * time.Second,
+		MaxDepth:      cfg.DatasetScanMaxDepth,
+		FormatMap:     formats,
+		Parallelism:   cfg.DatasetScanParallelism,
+
+		ContentChecksum: cfg.DatasetContentChecksum,
+
+		Cache:              cfg.DatasetScanCache,
+		FullRescanInterval: time.Duration(cfg.DatasetFullRescanInterval) * time.Second,
+	})
+
+	// Optional metrics push for collectors that don't scrape
+	if cfg.MetricsSink != "" {
+		pusher, err := metrics.NewPusher(cfg.MetricsSink, cfg.NodeName, metrics.Default)
+		if err != nil {
+			fatal("Invalid metrics sink", "error", err)
+		}
+		slog.Info("Pushing metrics", "sink", cfg.MetricsSink)
+		go pusher.Run(ctx, time.Duration(cfg.MetricsPushInterval)*time.Second)
+	}
 
-	// Start HTTP API server
-	apiServer := api.NewServer(cfg, masterClient)
+	// Audit log for authenticated API calls
+	auditLog, err := audit.NewLogger(cfg.AuditLogFile)
+	if err != nil {
+		fatal("Failed to open audit log", "error", err)
+	}
+	defer auditLog.Close()
+
+	// Start HTTP API server; if it can't serve, the agent stops
+	apiServer := api.NewServer(cfg, masterClient, exec, auditLog)
+	apiErr := make(chan error, 1)
 	go func() {
 		addr := fmt.Sprintf(":%d", cfg.APIPort)
-		log("INFO", "Starting HTTP API server on %s", addr)
+		slog.Info("Starting HTTP API server", "addr", addr)
 		if err := apiServer.Start(addr); err != nil && err != http.ErrServerClosed {
-			log("ERROR", "API server error: %v", err)
+			apiErr <- err
+			cancel()
 		}
 	}()
 
+	// An API shutdown either drains (current jobs finish, no new ones start)
+	// or cancels like SIGTERM
+	drain := make(chan struct{})
+	var drainOnce sync.Once
+	apiServer.SetShutdownHandler(func(graceful bool) {
+		if !graceful {
+			slog.Info("Shutdown requested over the API, shutting down...")
+			cancel()
+			return
+		}
+		drainOnce.Do(func() {
+			slog.Info("Graceful shutdown requested over the API, finishing current jobs...")
+			close(drain)
+		})
+	})
+
 	// Start main loop
-	if err := runMainLoop(ctx, cfg, masterClient, exec, scan); err != nil {
+	if err := runMainLoop(ctx, jobCtx, cfg, masterClient, exec, scan, drain, reload); err != nil {
 		if err != context.Canceled {
-			log("ERROR", "Main loop error: %v", err)
+			slog.Error("Main loop error", "error", err)
 		}
 	}
 
-	// Cleanup
-	log("INFO", "Shutting down API server...")
+	// Cleanup; the API keeps serving job logs while draining
+	drainJobs(masterClient, exec, time.Duration(cfg.ShutdownDrainSeconds)*time.Second,
+		time.Duration(cfg.HeartbeatInterval)*time.Second)
+
+	slog.Info("Cancelling running jobs...")
+	cancelJobs()
+	exec.CancelAll()
+
+	slog.Info("Shutting down API server...")
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
-	apiServer.Shutdown(shutdownCtx)
+	if err := apiServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("API server shutdown failed", "error", err)
+	}
 
-	log("INFO", "Cancelling running jobs...")
-	exec.CancelAll()
+	// Give cancelled jobs a moment to report their final status and finished
+	// jobs' output to finish uploading
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer waitCancel()
+	if err := exec.Wait(waitCtx); err != nil {
+		slog.Warn("Jobs still running at exit", "error", err)
+	}
+	if err := exec.WaitOutputSinks(waitCtx); err != nil {
+		slog.Warn("Job output uploads aborted at exit", "error", err)
+	}
+
+	select {
+	case err := <-apiErr:
+		fatal("API server error", "error", err)
+	default:
+	}
+
+	slog.Info("Agent stopped gracefully")
+}
+
+// runGitCredentialHelper answers a git credential helper request. Errors go to
+// stderr without ever including the credentials.
+func runGitCredentialHelper(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: agent git-credential <get|store|erase>")
+		return 1
+	}
+
+	cfg, err := config.Load()
+	if err != nil || cfg.GitCredentialsFile == "" {
+		return 0 // Nothing to offer, let git fail on its own
+	}
 
-	log("INFO", "Agent stopped gracefully")
+	if err := fileops.ServeCredentialHelper(cfg.GitCredentialsFile, args[0], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "git-credential: %v\n", err)
+		return 1
+	}
+	return 0
 }
 
-// printBanner prints the startup banner.
-func printBanner(cfg *config.Config) {
-	log("INFO", strings.Repeat("=", 60))
-	log("INFO", "Starting ML-Server-Manager Worker Agent (Go)")
-	log("INFO", "Version: 1.0.0")
-	log("INFO", strings.Repeat("-", 60))
-	log("INFO", "Node Name:    %s", cfg.NodeName)
-	log("INFO", "Hostname:     %s", cfg.NodeHostname)
-	log("INFO", "Master URL:   %s", cfg.MasterURL)
-	log("INFO", "API Port:     %d", cfg.APIPort)
-	log("INFO", "Storage Path: %s", cfg.StoragePath)
-	log("INFO", "Dev Mode:     %v", cfg.DevMode)
-	log("INFO", strings.Repeat("=", 60))
+// logStartup logs the agent version and main settings.
+func logStartup(cfg *config.Config) {
+	slog.Info("Starting ML-Server-Manager Worker Agent (Go)",
+		"version", version.String(),
+		"hostname", cfg.NodeHostname,
+		"master_url", cfg.MasterURL,
+		"api_port", cfg.APIPort,
+		"storage_path", cfg.StoragePath,
+		"dev_mode", cfg.DevMode,
+	)
 }
 
 // registerWithRetry attempts to register with the master with retries.
-func registerWithRetry(ctx context.Context, client *client.MasterClient, maxAttempts int) error {
+func registerWithRetry(ctx context.Context, cfg *config.Config, client *client.MasterClient) error {
+	maxAttempts := max(cfg.RegisterMaxAttempts, 1)
+	retry := backoff.Backoff{
+		Base: 2 * time.Second,
+		Max:  time.Duration(max(cfg.RegisterBackoffMax, 2)) * time.Second,
+	}
+
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		select {
 		case <-ctx.Done():
@@ -114,14 +275,16 @@ func registerWithRetry(ctx context.Context, client *client.MasterClient, maxAtte
 
 		err := client.Register(ctx)
 		if err == nil {
-			log("INFO", "Registered successfully. Node ID: %s", client.NodeID())
+			slog.Info("Registered successfully", "node_id", client.NodeID())
 			return nil
 		}
 
-		log("WARN", "Registration attempt %d/%d failed: %v", attempt, maxAttempts, err)
+		slog.Warn("Registration attempt failed", "attempt", attempt, "max_attempts", maxAttempts, "error", err)
 
 		if attempt < maxAttempts {
-			time.Sleep(5 * time.Second)
+			if err := retry.Wait(ctx); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -131,11 +294,17 @@ func registerWithRetry(ctx context.Context, client *client.MasterClient, maxAtte
 // runMainLoop runs the main agent loop.
 func runMainLoop(
 	ctx context.Context,
+	jobCtx context.Context,
 	cfg *config.Config,
 	masterClient *client.MasterClient,
 	exec *executor.Executor,
 	scan *scanner.Scanner,
+	drain <-chan struct{},
+	reload <-chan os.Signal,
 ) error {
+	// Intervals can change on reload; the rest of cfg is fixed until restart
+	current := *cfg
+
 	heartbeatTicker := time.NewTicker(time.Duration(cfg.HeartbeatInterval) * time.Second)
 	defer heartbeatTicker.Stop()
 
@@ -145,53 +314,201 @@ func runMainLoop(
 	datasetScanTicker := time.NewTicker(time.Duration(cfg.DatasetScanInterval) * time.Second)
 	defer datasetScanTicker.Stop()
 
+	// Hourly cleanup of old job logs and trashed projects
+	housekeepingTicker := time.NewTicker(time.Hour)
+	defer housekeepingTicker.Stop()
+
 	// Initial heartbeat
 	sendHeartbeat(ctx, masterClient)
 
+	// Unavailable dataset storage and failed reports are rescanned with backoff
+	// instead of waiting a full interval
+	scanInterval := time.Duration(cfg.DatasetScanInterval) * time.Second
+	scanBackoff := 10 * time.Second
+	var scanRetry <-chan time.Time
+	rescan := func() {
+		if scanDatasets(ctx, cfg, masterClient, scan) {
+			scanBackoff = 10 * time.Second
+			scanRetry = nil
+			return
+		}
+		slog.Warn("Retrying dataset scan", "in", scanBackoff.String())
+		scanRetry = time.After(scanBackoff)
+		scanBackoff = min(scanBackoff*2, scanInterval)
+	}
+
 	// Initial dataset scan
-	scanDatasets(ctx, cfg, masterClient, scan)
+	rescan()
 
-	log("INFO", "Agent started, entering main loop...")
+	slog.Info("Agent started, entering main loop...")
+
+	// While draining, heartbeats continue until the running jobs are done
+	draining := drain
+	var drained chan struct{}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 
+		case <-draining:
+			draining = nil
+			drained = make(chan struct{})
+			go func() {
+				exec.Wait(ctx)
+				close(drained)
+			}()
+
+		case <-drained:
+			return nil
+
 		case <-heartbeatTicker.C:
 			sendHeartbeat(ctx, masterClient)
 
 		case <-jobPollTicker.C:
-			processJobs(ctx, masterClient, exec)
+			processJobs(ctx, jobCtx, masterClient, exec, drain)
 
 		case <-datasetScanTicker.C:
-			scanDatasets(ctx, cfg, masterClient, scan)
+			rescan()
+
+		case <-scanRetry:
+			rescan()
+
+		case <-reload:
+			// Tickers are only touched from this loop, so a reload can't race a tick
+			next, ok := reloadConfig(&current)
+			if !ok {
+				continue
+			}
+			heartbeatTicker.Reset(time.Duration(next.HeartbeatInterval) * time.Second)
+			jobPollTicker.Reset(time.Duration(next.JobPollInterval) * time.Second)
+			datasetScanTicker.Reset(time.Duration(next.DatasetScanInterval) * time.Second)
+			scanInterval = time.Duration(next.DatasetScanInterval) * time.Second
+			current.HeartbeatInterval = next.HeartbeatInterval
+			current.JobPollInterval = next.JobPollInterval
+			current.DatasetScanInterval = next.DatasetScanInterval
+
+		case <-housekeepingTicker.C:
+			if purged := exec.PurgeJobLogs(); purged > 0 {
+				slog.Info("Purged old job logs", "count", purged)
+			}
+			if cfg.ProjectTrashDir != "" {
+				sweepTrash(cfg)
+			}
 		}
 	}
 }
 
+// drainJobs waits up to timeout for running jobs to finish on their own,
+// logging every second how many are left. Heartbeats go on meanwhile, so the
+// master doesn't take the node for dead while its jobs finish.
+func drainJobs(masterClient *client.MasterClient, exec *executor.Executor, timeout, heartbeatInterval time.Duration) {
+	if timeout <= 0 || exec.ActiveCount() == 0 {
+		return
+	}
+	slog.Info("Waiting for running jobs to finish...", "timeout", timeout.String(), "running", exec.ActiveCount())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- exec.Wait(ctx) }()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	heartbeatTicker := time.NewTicker(heartbeatInterval)
+	defer heartbeatTicker.Stop()
+	for {
+		select {
+		case <-heartbeatTicker.C:
+			sendHeartbeat(ctx, masterClient)
+		case err := <-done:
+			if err != nil {
+				slog.Warn("Drain timed out", "running", exec.ActiveCount())
+			} else {
+				slog.Info("All running jobs finished")
+			}
+			return
+		case <-ticker.C:
+			slog.Info("Draining", "running", exec.ActiveCount())
+		}
+	}
+}
+
+// sweepTrash purges trashed projects past their retention.
+func sweepTrash(cfg *config.Config) {
+	retention := time.Duration(cfg.ProjectTrashRetention) * time.Hour
+	purged, err := fileops.PurgeTrash(cfg.ProjectTrashDir, retention)
+	if err != nil {
+		slog.Warn("Failed to purge project trash", "error", err)
+	}
+	if purged > 0 {
+		slog.Info("Purged trashed projects", "count", purged)
+	}
+}
+
+// reloadConfig loads the configuration again and logs what changed relative
+// to current. It returns false when there is nothing to apply.
+func reloadConfig(current *config.Config) (*config.Config, bool) {
+	slog.Info("Received SIGHUP, reloading configuration...")
+
+	next, err := config.Load()
+	if err != nil {
+		slog.Error("Config reload failed", "error", err)
+		return nil, false
+	}
+
+	applied, ignored := current.Changes(next)
+	for _, name := range ignored {
+		slog.Warn("Config reload ignored: restart the agent to apply it", "setting", name)
+	}
+	if len(applied) == 0 {
+		slog.Info("Config reloaded, no interval changes")
+		return nil, false
+	}
+
+	slog.Info("Config reloaded", "applied", applied)
+	return next, true
+}
+
 // sendHeartbeat sends a heartbeat to the master.
 func sendHeartbeat(ctx context.Context, masterClient *client.MasterClient) {
-	if err := masterClient.Heartbeat(ctx); err != nil {
-		log("ERROR", "Heartbeat failed: %v", err)
+	if err := masterClient.Heartbeat(ctx); errors.Is(err, client.ErrCircuitOpen) {
+		slog.Debug("Heartbeat skipped", "error", err)
+	} else if err != nil {
+		slog.Error("Heartbeat failed", "error", err)
 
 		// Try to re-register if unauthorized
 		if strings.Contains(err.Error(), "unauthorized") {
-			log("WARN", "Token invalid, attempting re-registration...")
+			slog.Warn("Token invalid, attempting re-registration...")
 			if regErr := masterClient.Register(ctx); regErr != nil {
-				log("ERROR", "Re-registration failed: %v", regErr)
+				slog.Error("Re-registration failed", "error", regErr)
 			}
 		}
+	} else if healthy, reason := masterClient.Healthy(); !healthy {
+		slog.Warn("Heartbeat sent, node unhealthy", "reason", reason)
 	} else {
-		log("INFO", "Heartbeat sent")
+		slog.Debug("Heartbeat sent")
 	}
 }
 
-// processJobs fetches and executes pending jobs.
-func processJobs(ctx context.Context, masterClient *client.MasterClient, exec *executor.Executor) {
+// processJobs fetches pending jobs and executes them under jobCtx.
+func processJobs(ctx, jobCtx context.Context, masterClient *client.MasterClient, exec *executor.Executor, drain <-chan struct{}) {
+	// Don't take new jobs while a fatal resource is missing
+	if healthy, _ := masterClient.Healthy(); !healthy {
+		return
+	}
+
+	// Keep polling while jobs run, but only when a slot is free
+	if exec.FreeSlots() == 0 {
+		return
+	}
+
 	jobs, err := masterClient.FetchPendingJobs(ctx)
-	if err != nil {
-		log("ERROR", "Failed to fetch jobs: %v", err)
+	if errors.Is(err, client.ErrCircuitOpen) {
+		slog.Debug("Job poll skipped", "error", err)
+		return
+	} else if err != nil {
+		slog.Error("Failed to fetch jobs", "error", err)
 		return
 	}
 
@@ -199,48 +516,108 @@ func processJobs(ctx context.Context, masterClient *client.MasterClient, exec *e
 		select {
 		case <-ctx.Done():
 			return
+		case <-drain:
+			return
 		default:
 		}
 
-		log("INFO", "Executing job %d: %s", job.ID, job.Name)
-
-		result := exec.Execute(ctx, job)
+		// Still queued on the master until its running status lands
+		if exec.IsActive(job.ID) {
+			continue
+		}
 
-		if result.ExitCode == 0 {
-			exitCode := 0
-			if err := masterClient.UpdateJobStatus(ctx, job.ID, "completed", &exitCode, nil); err != nil {
-				log("ERROR", "Failed to update job status: %v", err)
+		// Jobs with unfinished dependencies stay queued on the master until the next poll
+		ready, err := exec.CheckDependencies(job)
+		if err != nil {
+			errMsg := err.Error()
+			exitCode := -1
+			if err := masterClient.UpdateJobStatus(ctx, job.ID, "failed", &exitCode, &errMsg); err != nil {
+				slog.Error("Failed to update job status", "job_id", job.ID, "error", err)
 			}
-			log("INFO", "Job %d completed successfully", job.ID)
-		} else {
-			if err := masterClient.UpdateJobStatus(ctx, job.ID, "failed", &result.ExitCode, &result.ErrorMessage); err != nil {
-				log("ERROR", "Failed to update job status: %v", err)
-			}
-			log("ERROR", "Job %d failed: %s", job.ID, result.ErrorMessage)
+			slog.Error("Job failed", "job_id", job.ID, "error", errMsg)
+			continue
+		}
+		if !ready {
+			slog.Info("Job waiting for dependencies", "job_id", job.ID)
+			continue
 		}
+
+		if !exec.Go(jobCtx, job, func(result executor.JobResult) {
+			reportJobResult(jobCtx, exec, job, result)
+		}) {
+			// Pool is full; the rest stay queued for the next poll
+			return
+		}
+		slog.Info("Executing job", "job_id", job.ID, "job_name", job.Name)
 	}
 }
 
-// scanDatasets scans and reports datasets to the master.
-func scanDatasets(ctx context.Context, cfg *config.Config, masterClient *client.MasterClient, scan *scanner.Scanner) {
-	log("INFO", "Scanning datasets...")
+// reportJobResult sends a finished job's final status to the master.
+func reportJobResult(ctx context.Context, exec *executor.Executor, job client.Job, result executor.JobResult) {
+	update := client.JobStatusUpdate{
+		ExitCode:    &result.ExitCode,
+		Attempt:     job.Attempt,
+		MaxAttempts: job.MaxAttempts,
+		Metrics:     result.Metrics,
+		Status:      result.Status(),
+
+		PeakMemoryMB:  result.PeakMemoryMB,
+		AvgCPUPercent: result.AvgCPUPercent,
+	}
+	if update.Status != "completed" {
+		update.ErrorMessage = &result.ErrorMessage
+	}
 
-	datasets := scan.Scan(cfg.DatasetsPath)
-	if len(datasets) == 0 {
-		log("INFO", "No datasets found")
-		return
+	if err := exec.ReportStatus(ctx, job.ID, update); err != nil {
+		slog.Error("Failed to update job status", "job_id", job.ID, "error", err)
 	}
 
-	if err := masterClient.ReportDatasets(ctx, datasets); err != nil {
-		log("ERROR", "Failed to report datasets: %v", err)
+	if result.ExitCode == 0 {
+		slog.Info("Job completed successfully", "job_id", job.ID)
+	} else if result.Cancelled {
+		slog.Info("Job cancelled", "job_id", job.ID)
+	} else if result.TimedOut {
+		slog.Error("Job timed out", "job_id", job.ID, "error", result.ErrorMessage)
 	} else {
-		log("INFO", "Reported %d datasets", len(datasets))
+		slog.Error("Job failed", "job_id", job.ID, "exit_code", result.ExitCode, "error", result.ErrorMessage)
+	}
+}
+
+// scanDatasets scans and reports datasets. It returns false when a dataset
+// path was unavailable or the report failed, so the scan should be retried
+// sooner.
+func scanDatasets(ctx context.Context, cfg *config.Config, masterClient *client.MasterClient, scan *scanner.Scanner) bool {
+	slog.Info("Scanning datasets...")
+
+	// A partial scan isn't reported at all, so the master keeps the datasets
+	// of unavailable paths as they were
+	datasets, err := scan.ScanAll(cfg.DatasetsPaths)
+	if err != nil {
+		slog.Warn("Dataset storage unavailable, skipping the dataset report", "error", err)
+		return false
+	}
+
+	if len(datasets) == 0 {
+		slog.Info("No datasets found")
+	}
+
+	err = masterClient.ReportDatasets(ctx, datasets)
+	switch {
+	case err == nil:
+		slog.Info("Reported datasets", "count", len(datasets))
+	case errors.Is(err, client.ErrCircuitOpen):
+		// The breaker logs the outage; the next scan reports again
+		slog.Debug("Dataset report skipped", "error", err)
+	default:
+		// Rescanned with backoff so a brief master outage doesn't lose the scan
+		slog.Warn("Failed to report datasets", "error", err)
+		return false
 	}
+	return true
 }
 
-// log prints a formatted log message.
-func log(level, format string, args ...any) {
-	timestamp := time.Now().Format(time.RFC3339)
-	message := fmt.Sprintf(format, args...)
-	fmt.Printf("[%s] [%s] %s\n", timestamp, level, message)
+// fatal logs an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+	"github.com/YangYuS8/mlsmanager-worker/internal/sysinfo"
+)
+
+// Preflight check outcomes. Only failures make the preflight exit nonzero;
+// warnings cover optional features the node may not need.
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+// preflight collects and prints check results.
+type preflight struct {
+	failed bool
+}
+
+func (p *preflight) report(outcome, name, format string, args ...any) {
+	if outcome == checkFail {
+		p.failed = true
+	}
+	fmt.Printf("[%s] %-22s %s\n", outcome, name, fmt.Sprintf(format, args...))
+}
+
+// runPreflight checks that this node can run the agent and prints a report.
+// It returns the process exit code.
+func runPreflight(cfg *config.Config) int {
+	p := &preflight{}
+	fmt.Printf("Preflight for node %s (master %s)\n", cfg.NodeName, cfg.MasterURL)
+
+	p.checkMaster(cfg)
+	p.checkTools()
+	p.checkPaths(cfg)
+	p.checkSystem(cfg)
+
+	if p.failed {
+		fmt.Println("Preflight failed")
+		return 1
+	}
+	fmt.Println("Preflight passed")
+	return 0
+}
+
+// checkMaster verifies the master answers and accepts the saved token.
+func (p *preflight) checkMaster(cfg *config.Config) {
+	mc, err := client.NewMasterClient(cfg)
+	if err != nil {
+		p.report(checkFail, "master client", "%v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := mc.Ping(ctx); err != nil {
+		p.report(checkFail, "master reachable", "%v", err)
+		return
+	}
+	p.report(checkPass, "master reachable", "%s", cfg.MasterURL)
+
+	if mc.Token() == "" {
+		p.report(checkWarn, "agent token", "none saved, the agent will register on start")
+		return
+	}
+	if _, err := mc.FetchPendingJobs(ctx); err != nil {
+		p.report(checkFail, "agent token", "%v", err)
+		return
+	}
+	p.report(checkPass, "agent token", "accepted by the master")
+}
+
+// checkTools looks for the programs jobs and project operations rely on.
+func (p *preflight) checkTools() {
+	tools := []struct {
+		name     string
+		required bool
+	}{
+		{"git", true},
+		{"docker", false},
+		{"apptainer", false},
+		{"conda", false},
+		{"nvidia-smi", false},
+	}
+
+	for _, t := range tools {
+		path, err := exec.LookPath(t.name)
+		switch {
+		case err == nil:
+			p.report(checkPass, t.name, "%s", path)
+		case t.required:
+			p.report(checkFail, t.name, "not found in PATH")
+		default:
+			p.report(checkWarn, t.name, "not found in PATH")
+		}
+	}
+}
+
+// checkPaths verifies every configured directory can be written.
+func (p *preflight) checkPaths(cfg *config.Config) {
+	if err := cfg.ValidatePaths(); err != nil {
+		// ValidatePaths joins one error per problem
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			for _, e := range joined.Unwrap() {
+				p.report(checkFail, "paths", "%v", e)
+			}
+		} else {
+			p.report(checkFail, "paths", "%v", err)
+		}
+	} else {
+		p.report(checkPass, "paths", "datasets, jobs and projects paths are writable")
+	}
+
+	dirs := []struct{ name, path string }{
+		{"AGENT_STORAGE_PATH", cfg.StoragePath},
+		{"AGENT_LOG_PATH", cfg.LogPath},
+		{"AGENT_ENV_CACHE_DIR", cfg.EnvCacheDir},
+		{"AGENT_PROJECT_TRASH_DIR", cfg.ProjectTrashDir},
+	}
+	for _, d := range dirs {
+		if d.path == "" {
+			continue
+		}
+		if err := config.CheckWritableDir(d.path); err != nil {
+			p.report(checkFail, d.name, "%v", err)
+		} else {
+			p.report(checkPass, d.name, "%s is writable", d.path)
+		}
+	}
+}
+
+// checkSystem probes storage mounts and GPUs the way heartbeats do.
+func (p *preflight) checkSystem(cfg *config.Config) {
+	info := sysinfo.Collect(cfg.StoragePath, sysinfo.DiskProbe{
+		Paths:       cfg.VolumePaths(),
+		Timeout:     time.Duration(cfg.DiskProbeTimeout) * time.Second,
+		Concurrency: cfg.DiskProbeConcurrency,
+	})
+
+	for _, vol := range info.Volumes {
+		switch {
+		case !vol.Available:
+			p.report(checkFail, "disk "+vol.Path, "%s", vol.Error)
+		case *vol.TotalGB > 0 && *vol.UsedGB*100 >= *vol.TotalGB*95:
+			p.report(checkWarn, "disk "+vol.Path, "%d of %d GB used", *vol.UsedGB, *vol.TotalGB)
+		default:
+			p.report(checkPass, "disk "+vol.Path, "%d of %d GB used", *vol.UsedGB, *vol.TotalGB)
+		}
+	}
+
+	if info.GPUCount == 0 {
+		p.report(checkWarn, "gpus", "no GPUs detected")
+	} else {
+		p.report(checkPass, "gpus", "%d: %s", info.GPUCount, *info.GPUInfo)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// ShutdownFunc stops the agent. With graceful set, running jobs finish first.
+type ShutdownFunc func(graceful bool)
+
+// SetShutdownHandler sets what POST /api/v1/shutdown triggers.
+func (s *Server) SetShutdownHandler(fn ShutdownFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onShutdown = fn
+}
+
+// handleShutdown handles POST /api/v1/shutdown?mode=graceful|immediate
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "graceful"
+	}
+	if mode != "graceful" && mode != "immediate" {
+		s.jsonError(w, http.StatusBadRequest, "mode must be graceful or immediate")
+		return
+	}
+
+	s.mu.Lock()
+	shutdown := s.onShutdown
+	s.mu.Unlock()
+
+	if shutdown == nil {
+		s.jsonError(w, http.StatusServiceUnavailable, "shutdown not available")
+		return
+	}
+
+	slog.Info("Shutdown requested over the API", "mode", mode, "remote_addr", r.RemoteAddr)
+
+	// Respond first; the main loop does the teardown
+	s.jsonResponse(w, http.StatusAccepted, map[string]interface{}{
+		"accepted": true,
+		"mode":     mode,
+	})
+	go shutdown(mode == "graceful")
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// handleExportProject handles GET /api/v1/projects/{id}/export?project_path=...&include_git=true
+func (s *Server) handleExportProject(w http.ResponseWriter, r *http.Request, projectID int64) {
+	projectPath := r.URL.Query().Get("project_path")
+	if projectPath == "" {
+		s.jsonError(w, http.StatusBadRequest, "project_path query parameter required")
+		return
+	}
+	includeGit := r.URL.Query().Get("include_git") == "true"
+
+	// Validate path
+	fullPath, err := fileops.ValidatePath(s.config.ProjectsPath, projectPath)
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !s.pathLocks.TryLock(fullPath) {
+		s.jsonError(w, http.StatusConflict, errPathBusy)
+		return
+	}
+	defer s.pathLocks.Unlock(fullPath)
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, "path not found")
+		return
+	}
+	if !info.IsDir() {
+		s.jsonError(w, http.StatusBadRequest, "path is not a directory")
+		return
+	}
+
+	// Enforce the size cap before any of the archive is sent
+	if s.config.ProjectExportMaxMB > 0 {
+		size, err := fileops.TreeSize(fullPath, includeGit)
+		if err != nil {
+			s.jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if size > int64(s.config.ProjectExportMaxMB)*1024*1024 {
+			s.jsonError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("project is %d MB, export limit is %d MB", size/(1024*1024), s.config.ProjectExportMaxMB))
+			return
+		}
+	}
+
+	// Large exports outlast the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	name := filepath.Base(fullPath) + ".tar.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are already sent, so a failure can only cut the stream short
+	if err := fileops.WriteTarGz(r.Context(), w, fullPath, includeGit); err != nil {
+		slog.Error("Project export failed", "project_id", projectID, "error", err)
+		return
+	}
+
+	slog.Info("Exported project path", "project_id", projectID, "path", fullPath)
+}
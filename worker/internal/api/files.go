@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// ChecksumResponse represents a file checksum response.
+type ChecksumResponse struct {
+	Path   string `json:"path"`
+	Algo   string `json:"algo"`
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+	Cached bool   `json:"cached"`
+}
+
+// checksumKey identifies a cached digest. Size and mtime are part of the key
+// so a modified file is rehashed.
+type checksumKey struct {
+	path    string
+	algo    string
+	size    int64
+	modTime time.Time
+}
+
+// checksumCache remembers digests of files that have already been hashed.
+type checksumCache struct {
+	mu      sync.Mutex
+	digests map[checksumKey]string
+}
+
+func (c *checksumCache) get(key checksumKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	digest, ok := c.digests[key]
+	return digest, ok
+}
+
+func (c *checksumCache) put(key checksumKey, digest string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.digests == nil {
+		c.digests = make(map[checksumKey]string)
+	}
+	// Drop entries for older versions of the same file
+	for k := range c.digests {
+		if k.path == key.path && k.algo == key.algo {
+			delete(c.digests, k)
+		}
+	}
+	c.digests[key] = digest
+}
+
+// handleFileChecksum handles GET /api/v1/files/checksum?path=...&algo=sha256
+func (s *Server) handleFileChecksum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		s.jsonError(w, http.StatusBadRequest, "path query parameter required")
+		return
+	}
+
+	algo := strings.ToLower(r.URL.Query().Get("algo"))
+	if algo == "" {
+		algo = "sha256"
+	}
+	if _, err := fileops.NewHash(algo); err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Validate path
+	fullPath, err := fileops.ValidatePath(s.config.StoragePath, path)
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	if !info.Mode().IsRegular() {
+		s.jsonError(w, http.StatusBadRequest, "path is not a regular file")
+		return
+	}
+
+	key := checksumKey{path: fullPath, algo: algo, size: info.Size(), modTime: info.ModTime()}
+	if digest, ok := s.checksums.get(key); ok {
+		etag := fmt.Sprintf("%q", digest)
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.jsonResponse(w, http.StatusOK, ChecksumResponse{
+			Path: fullPath, Algo: algo, Digest: digest, Size: info.Size(), Cached: true,
+		})
+		return
+	}
+
+	// Bound concurrent hashing so large files don't thrash the disk
+	select {
+	case s.checksumSem <- struct{}{}:
+		defer func() { <-s.checksumSem }()
+	case <-r.Context().Done():
+		return
+	}
+
+	// Hashing a large file can outlast the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	digest, size, err := fileops.Checksum(r.Context(), fullPath, algo)
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.checksums.put(key, digest)
+
+	w.Header().Set("ETag", fmt.Sprintf("%q", digest))
+	s.jsonResponse(w, http.StatusOK, ChecksumResponse{
+		Path: fullPath, Algo: algo, Digest: digest, Size: size,
+	})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+const (
+	defaultLogLimit = 20
+	maxLogLimit     = 500
+)
+
+// handleListBranches handles GET /api/v1/projects/{id}/branches?project_path=...
+func (s *Server) handleListBranches(w http.ResponseWriter, r *http.Request, projectID int64) {
+	fullPath, ok := s.projectRepo(w, r)
+	if !ok {
+		return
+	}
+
+	branches, err := fileops.ListBranches(r.Context(), fullPath)
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.jsonResponse(w, http.StatusOK, branches)
+}
+
+// handleGetLog handles GET /api/v1/projects/{id}/log?project_path=...&limit=20
+func (s *Server) handleGetLog(w http.ResponseWriter, r *http.Request, projectID int64) {
+	limit := defaultLogLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLogLimit {
+			s.jsonError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	fullPath, ok := s.projectRepo(w, r)
+	if !ok {
+		return
+	}
+
+	commits, err := fileops.GetLog(r.Context(), fullPath, limit)
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.jsonResponse(w, http.StatusOK, map[string]interface{}{"commits": commits})
+}
+
+// projectRepo resolves the project_path query parameter to a git repository
+// under the projects path, writing the error response if it isn't one.
+func (s *Server) projectRepo(w http.ResponseWriter, r *http.Request) (string, bool) {
+	projectPath := r.URL.Query().Get("project_path")
+	if projectPath == "" {
+		s.jsonError(w, http.StatusBadRequest, "project_path query parameter required")
+		return "", false
+	}
+
+	// Validate path
+	fullPath, err := fileops.ValidatePath(s.config.ProjectsPath, projectPath)
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+
+	if !fileops.PathExists(fullPath) {
+		s.jsonError(w, http.StatusNotFound, "project path not found")
+		return "", false
+	}
+	if !fileops.IsGitRepo(fullPath) {
+		s.jsonError(w, http.StatusBadRequest, "not a git repository")
+		return "", false
+	}
+	return fullPath, true
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// maxGitStatusEntries bounds the number of repositories with a cached status.
+const maxGitStatusEntries = 256
+
+// gitStatusEntry is a cached status and the repository mtime it was read at,
+// so a commit, checkout or pull made outside the agent invalidates it.
+type gitStatusEntry struct {
+	status  *fileops.GitStatus
+	headMod time.Time
+	fetched time.Time
+}
+
+// gitStatusCache briefly remembers git status results so rapid polling of
+// an unchanged repository doesn't rerun git each time.
+type gitStatusCache struct {
+	mu      sync.Mutex
+	entries map[string]gitStatusEntry
+}
+
+// headModTime returns the later mtime of a repository's .git/HEAD and
+// .git/index. HEAD changes on checkout; commits and merges rewrite the index
+// but leave HEAD alone while the branch stays the same.
+func headModTime(repoPath string) (time.Time, bool) {
+	head, err := os.Stat(filepath.Join(repoPath, ".git", "HEAD"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	mod := head.ModTime()
+	if index, err := os.Stat(filepath.Join(repoPath, ".git", "index")); err == nil && index.ModTime().After(mod) {
+		mod = index.ModTime()
+	}
+	return mod, true
+}
+
+func (c *gitStatusCache) get(path string, headMod time.Time, ttl time.Duration) (*fileops.GitStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[path]
+	if !ok || !entry.headMod.Equal(headMod) || time.Since(entry.fetched) >= ttl {
+		return nil, false
+	}
+	return entry.status, true
+}
+
+func (c *gitStatusCache) put(path string, headMod time.Time, status *fileops.GitStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]gitStatusEntry)
+	}
+
+	// Make room by dropping the oldest entry
+	if _, ok := c.entries[path]; !ok && len(c.entries) >= maxGitStatusEntries {
+		var oldest string
+		for p, e := range c.entries {
+			if oldest == "" || e.fetched.Before(c.entries[oldest].fetched) {
+				oldest = p
+			}
+		}
+		delete(c.entries, oldest)
+	}
+
+	c.entries[path] = gitStatusEntry{status: status, headMod: headMod, fetched: time.Now()}
+}
+
+// invalidate drops the cached status of a repository the agent changed.
+func (c *gitStatusCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, path)
+}
+
+// invalidateContaining drops the status of any repository holding path,
+// which a new or changed file leaves dirty.
+func (c *gitStatusCache) invalidateContaining(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for repo := range c.entries {
+		if strings.HasPrefix(path, repo+string(os.PathSeparator)) {
+			delete(c.entries, repo)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/executor"
+)
+
+// JobLogsResponse represents a job log tail response. Lines keep their
+// "[phase] " tags; Entries has the same lines split into phase and text.
+type JobLogsResponse struct {
+	JobID   int           `json:"job_id"`
+	Running bool          `json:"running"`
+	Lines   []string      `json:"lines"`
+	Entries []JobLogEntry `json:"entries"`
+}
+
+// JobLogEntry is one log line with its phase (setup, job or teardown; empty
+// for untagged lines).
+type JobLogEntry struct {
+	Phase string `json:"phase"`
+	Text  string `json:"text"`
+}
+
+// JobStatsResponse represents a running job's resource usage.
+type JobStatsResponse struct {
+	JobID           int                  `json:"job_id"`
+	Running         bool                 `json:"running"`
+	GPUMemoryUsedMB int                  `json:"gpu_memory_used_mb"`
+	GPUProcesses    []client.JobGPUUsage `json:"gpu_processes"`
+
+	executor.OutputStats
+}
+
+// JobCancelResponse represents the result of a job cancel request.
+type JobCancelResponse struct {
+	JobID     int  `json:"job_id"`
+	Cancelled bool `json:"cancelled"`
+}
+
+// handleJobRoutes handles /api/v1/jobs/{id}/... routes
+func (s *Server) handleJobRoutes(w http.ResponseWriter, r *http.Request) {
+	// Parse path: /api/v1/jobs/{id}/{action}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/jobs/")
+	parts := strings.Split(path, "/")
+
+	if len(parts) < 1 || parts[0] == "" {
+		s.jsonError(w, http.StatusBadRequest, "job id required")
+		return
+	}
+
+	jobID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, "invalid job id")
+		return
+	}
+
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	switch {
+	case r.Method == http.MethodGet && action == "logs":
+		s.handleJobLogs(w, r, jobID)
+	case r.Method == http.MethodGet && action == "stats":
+		s.handleJobStats(w, r, jobID)
+	case r.Method == http.MethodPost && action == "cancel":
+		s.handleCancelJob(w, r, jobID)
+	default:
+		s.jsonError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// handleJobLogs handles GET /api/v1/jobs/{id}/logs?tail=500&follow=true&phase=job
+// and GET /api/v1/jobs/{id}/logs?download=true for a finished job's full log
+func (s *Server) handleJobLogs(w http.ResponseWriter, r *http.Request, jobID int) {
+	if r.URL.Query().Get("download") == "true" {
+		s.downloadJobLog(w, r, jobID)
+		return
+	}
+
+	tail := 500
+	if v := r.URL.Query().Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			s.jsonError(w, http.StatusBadRequest, "invalid tail value")
+			return
+		}
+		tail = n
+	}
+
+	lines, ok := s.executor.TailLogs(jobID, tail)
+	if !ok {
+		s.jsonError(w, http.StatusNotFound, "no logs for job")
+		return
+	}
+
+	// Only the tail is filtered; followed output carries its tags
+	entries := make([]JobLogEntry, 0, len(lines))
+	if phase := r.URL.Query().Get("phase"); phase != "" {
+		filtered := lines[:0]
+		for _, line := range lines {
+			if p, _ := executor.SplitPhase(line); p == phase {
+				filtered = append(filtered, line)
+			}
+		}
+		lines = filtered
+	}
+	for _, line := range lines {
+		phase, text := executor.SplitPhase(line)
+		entries = append(entries, JobLogEntry{Phase: phase, Text: text})
+	}
+
+	if r.URL.Query().Get("follow") != "true" {
+		s.jsonResponse(w, http.StatusOK, JobLogsResponse{
+			JobID:   jobID,
+			Running: s.executor.IsRunning(jobID),
+			Lines:   lines,
+			Entries: entries,
+		})
+		return
+	}
+
+	s.streamJobLogs(w, r, jobID, lines)
+}
+
+// downloadJobLog sends a finished job's whole log, passing the stored gzip
+// through when the client accepts it.
+func (s *Server) downloadJobLog(w http.ResponseWriter, r *http.Request, jobID int) {
+	if s.executor.IsRunning(jobID) {
+		s.jsonError(w, http.StatusConflict, "job is still running, use follow=true")
+		return
+	}
+
+	f, gzipped, err := s.executor.OpenLog(jobID)
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, "no logs for job")
+		return
+	}
+	defer f.Close()
+
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	var body io.Reader = f
+	if gzipped {
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+		} else {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				s.jsonError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			body = zr
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"job_%d.log\"", jobID))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, body)
+}
+
+// streamJobLogs sends the tail followed by new output as server-sent events
+// until the job ends or the client disconnects.
+func (s *Server) streamJobLogs(w http.ResponseWriter, r *http.Request, jobID int, tail []string) {
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{}) // Streams outlive the server write timeout
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, line := range tail {
+		fmt.Fprintf(w, "data: %s\n\n", line)
+	}
+	rc.Flush()
+
+	ch, cancel, running := s.executor.FollowLogs(jobID)
+	if !running {
+		fmt.Fprint(w, "event: end\ndata: job not running\n\n")
+		rc.Flush()
+		return
+	}
+	defer cancel()
+
+	var pending []byte
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case chunk, ok := <-ch:
+			if !ok {
+				if len(pending) > 0 {
+					fmt.Fprintf(w, "data: %s\n\n", pending)
+				}
+				fmt.Fprint(w, "event: end\ndata: job finished\n\n")
+				rc.Flush()
+				return
+			}
+
+			// Only emit complete lines; keep the remainder for the next chunk
+			pending = append(pending, chunk...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				fmt.Fprintf(w, "data: %s\n\n", pending[:i])
+				pending = pending[i+1:]
+			}
+			rc.Flush()
+		}
+	}
+}
+
+// handleJobStats handles GET /api/v1/jobs/{id}/stats
+func (s *Server) handleJobStats(w http.ResponseWriter, r *http.Request, jobID int) {
+	if !s.executor.IsRunning(jobID) {
+		s.jsonError(w, http.StatusNotFound, "job not running")
+		return
+	}
+
+	resp := JobStatsResponse{
+		JobID:        jobID,
+		Running:      true,
+		GPUProcesses: s.executor.JobGPUUsage(jobID),
+	}
+	resp.OutputStats, _ = s.executor.OutputStats(jobID)
+	if resp.GPUProcesses == nil {
+		resp.GPUProcesses = []client.JobGPUUsage{}
+	}
+	for _, p := range resp.GPUProcesses {
+		resp.GPUMemoryUsedMB += p.UsedMemoryMB
+	}
+
+	s.jsonResponse(w, http.StatusOK, resp)
+}
+
+// handleCancelJob handles POST /api/v1/jobs/{id}/cancel
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request, jobID int) {
+	if !s.executor.IsActive(jobID) {
+		s.jsonError(w, http.StatusNotFound, "job not active on this agent")
+		return
+	}
+
+	slog.Info("Job cancel requested over the API", "job_id", jobID, "remote_addr", r.RemoteAddr)
+	s.jsonResponse(w, http.StatusOK, JobCancelResponse{
+		JobID:     jobID,
+		Cancelled: s.executor.CancelJob(jobID),
+	})
+}
@@ -2,34 +2,70 @@
 package api
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/YangYuS8/mlsmanager-worker/internal/audit"
 	"github.com/YangYuS8/mlsmanager-worker/internal/client"
 	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+	"github.com/YangYuS8/mlsmanager-worker/internal/executor"
 	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+	"github.com/YangYuS8/mlsmanager-worker/internal/metrics"
+	"github.com/YangYuS8/mlsmanager-worker/internal/syncutil"
+	"github.com/YangYuS8/mlsmanager-worker/internal/sysinfo"
+	"github.com/YangYuS8/mlsmanager-worker/internal/tlsutil"
+	"github.com/YangYuS8/mlsmanager-worker/internal/version"
 )
 
 // Server represents the HTTP API server.
 type Server struct {
 	config       *config.Config
 	masterClient *client.MasterClient
-	httpServer   *http.Server
+	executor     *executor.Executor
+	audit        *audit.Logger
 	mux          *http.ServeMux
+
+	mu         sync.Mutex
+	httpServer *http.Server
+
+	checksums   checksumCache
+	checksumSem chan struct{}
+
+	gitStatuses gitStatusCache
+
+	onShutdown ShutdownFunc
+
+	// Built on the first scrape
+	metricsHandler func() (http.Handler, error)
+
+	// Serializes clone, pull, export and delete on the same project path
+	pathLocks syncutil.KeyedMutex
 }
 
 // NewServer creates a new HTTP API server.
-func NewServer(cfg *config.Config, mc *client.MasterClient) *Server {
+func NewServer(cfg *config.Config, mc *client.MasterClient, exec *executor.Executor, auditLog *audit.Logger) *Server {
 	s := &Server{
 		config:       cfg,
 		masterClient: mc,
+		executor:     exec,
+		audit:        auditLog,
 		mux:          http.NewServeMux(),
+		checksumSem:  make(chan struct{}, max(cfg.ChecksumConcurrency, 1)),
+		metricsHandler: sync.OnceValues(func() (http.Handler, error) {
+			return metrics.PrometheusHandler(metrics.Default)
+		}),
 	}
 	s.setupRoutes()
 	return s
@@ -39,33 +75,106 @@ func NewServer(cfg *config.Config, mc *client.MasterClient) *Server {
 func (s *Server) setupRoutes() {
 	// Health check (no auth required)
 	s.mux.HandleFunc("/health", s.handleHealth)
+	s.mux.HandleFunc("/version", s.handleVersion)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
 
 	// API routes (with auth)
 	s.mux.HandleFunc("/api/v1/projects/clone", s.authMiddleware(s.handleCloneProject))
+	s.mux.HandleFunc("/api/v1/projects/verify", s.authMiddleware(s.handleVerifyRemote))
 	s.mux.HandleFunc("/api/v1/projects/", s.authMiddleware(s.handleProjectRoutes))
+	s.mux.HandleFunc("/api/v1/files/checksum", s.authMiddleware(s.handleFileChecksum))
+	s.mux.HandleFunc("/api/v1/jobs/", s.authMiddleware(s.handleJobRoutes))
+	s.mux.HandleFunc("/api/v1/shutdown", s.authMiddleware(s.handleShutdown))
 }
 
-// authMiddleware validates the X-Agent-Token header.
+// authMiddleware validates the X-Agent-Token header and records an audit entry.
 func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		entry := &audit.Entry{
+			Time:       time.Now().UTC(),
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+		}
+
 		token := r.Header.Get("X-Agent-Token")
-		expectedToken := s.config.LoadToken()
+		expectedToken, err := s.config.LoadToken()
+		if err != nil {
+			slog.Error("Failed to load agent token", "error", err)
+		}
 
 		if token == "" || token != expectedToken {
+			entry.Event = audit.EventAuthFailure
+			entry.Status = http.StatusUnauthorized
+			entry.Reason = "invalid token"
+			if token == "" {
+				entry.Reason = "missing token"
+			}
+			s.audit.Log(entry)
+			slog.Warn("Rejected unauthenticated request", "remote_addr", r.RemoteAddr, "method", r.Method, "path", r.URL.Path)
+
 			s.jsonError(w, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
-		next(w, r)
+		entry.Event = audit.EventAuthSuccess
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next(sw, r.WithContext(audit.WithEntry(r.Context(), entry)))
+
+		entry.Status = sw.status
+		s.audit.Log(entry)
 	}
 }
 
+// statusWriter captures the response status for auditing.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // handleHealth handles health check requests.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	zombies := s.executor.ZombieStats()
 	s.jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"status":    "healthy",
-		"node_name": s.config.NodeName,
-		"timestamp": time.Now().Unix(),
+		"status":           "healthy",
+		"node_name":        s.config.NodeName,
+		"timestamp":        time.Now().Unix(),
+		"zombie_processes": zombies.Current,
+		"zombies_reaped":   zombies.Reaped,
+	})
+}
+
+// handleMetrics handles GET /metrics for Prometheus scrapes
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	handler, err := s.metricsHandler()
+	if err != nil {
+		slog.Error("Failed to set up metrics", "error", err)
+		s.jsonError(w, http.StatusInternalServerError, "metrics unavailable")
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// handleVersion handles GET /version
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"version":    version.Version,
+		"commit":     version.Commit,
+		"go_version": runtime.Version(),
 	})
 }
 
@@ -74,15 +183,32 @@ type CloneRequest struct {
 	ProjectID  int64  `json:"project_id"`
 	GitURL     string `json:"git_url"`
 	Branch     string `json:"branch"`
+	Ref        string `json:"ref"` // Branch, tag or commit to pin; wins over branch
 	TargetPath string `json:"target_path"`
+	LFS        bool   `json:"lfs"`        // Fetch Git LFS objects after cloning
+	Submodules bool   `json:"submodules"` // Clone submodules recursively
+
+	// Directories to check out from a large repository; empty means all
+	SparsePaths []string `json:"sparse_paths,omitempty"`
+
+	// Credentials for a private repository, used for this clone only
+	Credentials *CloneCredentials `json:"credentials,omitempty"`
+}
+
+// CloneCredentials are HTTPS credentials for a clone: a username and an
+// access token.
+type CloneCredentials struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
 }
 
 // CloneResponse represents a project clone response.
 type CloneResponse struct {
-	ProjectID int64  `json:"project_id"`
-	Success   bool   `json:"success"`
-	Message   string `json:"message,omitempty"`
-	LocalPath string `json:"local_path,omitempty"`
+	ProjectID      int64  `json:"project_id"`
+	Success        bool   `json:"success"`
+	Message        string `json:"message,omitempty"`
+	LocalPath      string `json:"local_path,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
 }
 
 // handleCloneProject handles POST /api/v1/projects/clone
@@ -98,11 +224,17 @@ func (s *Server) handleCloneProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.SetProjectID(r.Context(), req.ProjectID)
+
 	// Validate required fields
 	if req.GitURL == "" || req.TargetPath == "" {
 		s.jsonError(w, http.StatusBadRequest, "git_url and target_path are required")
 		return
 	}
+	if req.Credentials != nil && req.Credentials.Token == "" {
+		s.jsonError(w, http.StatusBadRequest, "credentials.token is required")
+		return
+	}
 
 	// Validate and build full path
 	fullPath, err := fileops.ValidatePath(s.config.ProjectsPath, req.TargetPath)
@@ -111,21 +243,43 @@ func (s *Server) handleCloneProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The remote's default branch is unknown up front, so a policy requires an
+	// explicit branch or ref
+	if ref := cmp.Or(req.Ref, req.Branch); !fileops.BranchAllowed(s.config.GitAllowedBranches, ref) {
+		s.jsonError(w, http.StatusForbidden, branchPolicyError(ref))
+		return
+	}
+
+	// The repo size isn't known upfront, so only the free space floor is checked
+	if !s.checkFreeSpace(w, 0) {
+		return
+	}
+
+	if !s.pathLocks.TryLock(fullPath) {
+		s.jsonError(w, http.StatusConflict, errPathBusy)
+		return
+	}
+
 	// Check if path already exists
 	if fileops.PathExists(fullPath) {
+		s.pathLocks.Unlock(fullPath)
 		s.jsonError(w, http.StatusConflict, "target path already exists")
 		return
 	}
 
-	// Start async clone operation
-	go s.doClone(req, fullPath)
+	// Start async clone operation; it holds the path lock until done
+	go func() {
+		defer s.pathLocks.Unlock(fullPath)
+		s.doClone(req, fullPath)
+	}()
 
 	// Return accepted response
 	s.jsonResponse(w, http.StatusAccepted, CloneResponse{
-		ProjectID: req.ProjectID,
-		Success:   true,
-		Message:   "Clone started",
-		LocalPath: fullPath,
+		ProjectID:      req.ProjectID,
+		Success:        true,
+		Message:        "Clone started",
+		LocalPath:      fullPath,
+		TimeoutSeconds: int(gitTimeout(s.config.GitCloneTimeout, fileops.DefaultCloneTimeout).Seconds()),
 	})
 }
 
@@ -133,14 +287,26 @@ func (s *Server) handleCloneProject(w http.ResponseWriter, r *http.Request) {
 func (s *Server) doClone(req CloneRequest, fullPath string) {
 	ctx := context.Background()
 
-	log.Printf("[INFO] Starting clone: %s -> %s", req.GitURL, fullPath)
+	slog.Info("Starting clone", "project_id", req.ProjectID, "git_url", fileops.RedactURL(req.GitURL), "path", fullPath)
+
+	var creds *fileops.GitCredential
+	if req.Credentials != nil {
+		creds = &fileops.GitCredential{Username: req.Credentials.Username, Password: req.Credentials.Token}
+	}
 
 	result := fileops.Clone(ctx, fileops.CloneOptions{
-		URL:        req.GitURL,
-		Branch:     req.Branch,
-		TargetPath: fullPath,
-		Timeout:    10 * time.Minute,
+		URL:              req.GitURL,
+		Branch:           req.Branch,
+		Ref:              req.Ref,
+		TargetPath:       fullPath,
+		Timeout:          gitTimeout(s.config.GitCloneTimeout, fileops.DefaultCloneTimeout),
+		CredentialHelper: s.gitCredentialHelper(),
+		Credentials:      creds,
+		LFS:              req.LFS,
+		Submodules:       req.Submodules,
+		SparsePaths:      req.SparsePaths,
 	})
+	s.gitStatuses.invalidate(fullPath)
 
 	// Update master with result (status values must be lowercase to match backend enum)
 	status := "active"
@@ -149,16 +315,16 @@ func (s *Server) doClone(req CloneRequest, fullPath string) {
 		status = "error"
 		message = result.Error
 		if result.Message != "" {
-			message = result.Message
+			message = result.Error + ": " + result.Message
 		}
-		log.Printf("[ERROR] Clone failed for project %d: %s", req.ProjectID, message)
+		slog.Error("Clone failed", "project_id", req.ProjectID, "error", message)
 	} else {
-		log.Printf("[INFO] Clone completed for project %d: %s", req.ProjectID, fullPath)
+		slog.Info("Clone completed", "project_id", req.ProjectID, "path", fullPath)
 	}
 
 	// Callback to master
 	if err := s.masterClient.UpdateProjectStatus(ctx, req.ProjectID, status, message, fullPath); err != nil {
-		log.Printf("[ERROR] Failed to update project status: %v", err)
+		slog.Error("Failed to update project status", "project_id", req.ProjectID, "error", err)
 	}
 }
 
@@ -178,6 +344,7 @@ func (s *Server) handleProjectRoutes(w http.ResponseWriter, r *http.Request) {
 		s.jsonError(w, http.StatusBadRequest, "invalid project id")
 		return
 	}
+	audit.SetProjectID(r.Context(), projectID)
 
 	action := ""
 	if len(parts) > 1 {
@@ -187,8 +354,20 @@ func (s *Server) handleProjectRoutes(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case r.Method == http.MethodPost && action == "pull":
 		s.handlePullProject(w, r, projectID)
+	case r.Method == http.MethodPost && action == "checkout":
+		s.handleCheckoutProject(w, r, projectID)
+	case r.Method == http.MethodPost && action == "files":
+		s.handleUploadFile(w, r, projectID)
+	case r.Method == http.MethodGet && action == "files":
+		s.handleDownloadFile(w, r, projectID)
 	case r.Method == http.MethodGet && action == "status":
 		s.handleGetProjectStatus(w, r, projectID)
+	case r.Method == http.MethodGet && action == "branches":
+		s.handleListBranches(w, r, projectID)
+	case r.Method == http.MethodGet && action == "log":
+		s.handleGetLog(w, r, projectID)
+	case r.Method == http.MethodGet && action == "export":
+		s.handleExportProject(w, r, projectID)
 	case r.Method == http.MethodDelete && action == "":
 		s.handleDeleteProject(w, r, projectID)
 	default:
@@ -200,6 +379,9 @@ func (s *Server) handleProjectRoutes(w http.ResponseWriter, r *http.Request) {
 type PullRequest struct {
 	ProjectPath string `json:"project_path"`
 	Branch      string `json:"branch"`
+	Ref         string `json:"ref"`        // Fetch and check out this ref instead of pulling
+	LFS         bool   `json:"lfs"`        // Fetch Git LFS objects after pulling
+	Submodules  bool   `json:"submodules"` // Update submodules after pulling
 }
 
 // handlePullProject handles POST /api/v1/projects/{id}/pull
@@ -217,21 +399,155 @@ func (s *Server) handlePullProject(w http.ResponseWriter, r *http.Request, proje
 		return
 	}
 
+	if !s.pathLocks.TryLock(fullPath) {
+		s.jsonError(w, http.StatusConflict, errPathBusy)
+		return
+	}
+	defer s.pathLocks.Unlock(fullPath)
+
 	// Check if it's a git repo
 	if !fileops.IsGitRepo(fullPath) {
 		s.jsonError(w, http.StatusBadRequest, "not a git repository")
 		return
 	}
 
+	// Without a branch or ref, pull updates the checked-out branch
+	if len(s.config.GitAllowedBranches) > 0 {
+		branch := cmp.Or(req.Ref, req.Branch)
+		if branch == "" {
+			if branch, err = fileops.CurrentBranch(r.Context(), fullPath); err != nil {
+				s.jsonError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if !fileops.BranchAllowed(s.config.GitAllowedBranches, branch) {
+			s.jsonError(w, http.StatusForbidden, branchPolicyError(branch))
+			return
+		}
+	}
+
+	// The pull runs synchronously, so give the response as long as git gets
+	timeout := gitTimeout(s.config.GitPullTimeout, fileops.DefaultPullTimeout)
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 30*time.Second))
+
 	// Pull
 	result := fileops.Pull(context.Background(), fileops.PullOptions{
-		RepoPath: fullPath,
-		Branch:   req.Branch,
+		RepoPath:         fullPath,
+		Branch:           req.Branch,
+		Ref:              req.Ref,
+		Timeout:          timeout,
+		CredentialHelper: s.gitCredentialHelper(),
+		LFS:              req.LFS,
+		Submodules:       req.Submodules,
 	})
+	s.gitStatuses.invalidate(fullPath)
 
 	s.jsonResponse(w, http.StatusOK, result)
 }
 
+// gitTimeout returns the timeout a git operation configured with seconds
+// runs with, falling back to def like fileops does when it is unset.
+func gitTimeout(seconds int, def time.Duration) time.Duration {
+	if seconds <= 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// CheckoutRequest represents a project checkout request.
+type CheckoutRequest struct {
+	ProjectPath string `json:"project_path"`
+	Ref         string `json:"ref"`    // Branch, tag or commit
+	Create      bool   `json:"create"` // Create ref as a new branch at HEAD
+}
+
+// handleCheckoutProject handles POST /api/v1/projects/{id}/checkout
+func (s *Server) handleCheckoutProject(w http.ResponseWriter, r *http.Request, projectID int64) {
+	var req CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Ref == "" {
+		s.jsonError(w, http.StatusBadRequest, "ref is required")
+		return
+	}
+
+	// Validate path
+	fullPath, err := fileops.ValidatePath(s.config.ProjectsPath, req.ProjectPath)
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !s.pathLocks.TryLock(fullPath) {
+		s.jsonError(w, http.StatusConflict, errPathBusy)
+		return
+	}
+	defer s.pathLocks.Unlock(fullPath)
+
+	// Check if it's a git repo
+	if !fileops.IsGitRepo(fullPath) {
+		s.jsonError(w, http.StatusBadRequest, "not a git repository")
+		return
+	}
+
+	if !fileops.BranchAllowed(s.config.GitAllowedBranches, req.Ref) {
+		s.jsonError(w, http.StatusForbidden, branchPolicyError(req.Ref))
+		return
+	}
+
+	result, err := fileops.Checkout(r.Context(), fullPath, req.Ref, req.Create)
+	s.gitStatuses.invalidate(fullPath)
+	switch {
+	case errors.Is(err, fileops.ErrInvalidRef):
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, fileops.ErrUncommittedChanges):
+		s.jsonError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, fileops.ErrRefNotFound):
+		s.jsonError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		s.jsonResponse(w, http.StatusOK, result)
+	}
+}
+
+// errPathBusy is returned when another operation holds a project path.
+const errPathBusy = "another operation is in progress for this path"
+
+// branchPolicyError describes why a branch was rejected by the allowlist.
+func branchPolicyError(branch string) string {
+	if branch == "" {
+		return "branch or ref is required by the node's branch policy"
+	}
+	return fmt.Sprintf("branch %q is not allowed on this node", branch)
+}
+
+// checkFreeSpace responds 507 and returns false when writing size more bytes
+// would leave the projects volume below AGENT_MIN_FREE_DISK_GB. A volume that
+// can't be measured is let through; the write itself will fail if it must.
+func (s *Server) checkFreeSpace(w http.ResponseWriter, size int64) bool {
+	if s.config.MinFreeDiskGB <= 0 {
+		return true
+	}
+
+	free, err := sysinfo.FreeBytes(s.config.ProjectsPath)
+	if err != nil {
+		slog.Warn("Cannot read free space on the projects volume", "path", s.config.ProjectsPath, "error", err)
+		return true
+	}
+
+	floor := uint64(s.config.MinFreeDiskGB) * 1024 * 1024 * 1024
+	if free < floor+uint64(max(size, 0)) {
+		s.jsonError(w, http.StatusInsufficientStorage, fmt.Sprintf(
+			"not enough free space on the projects volume: %d MB free, %d GB must stay free",
+			free/(1024*1024), s.config.MinFreeDiskGB))
+		return false
+	}
+	return true
+}
+
 // StatusRequest represents a project status request.
 type StatusRequest struct {
 	ProjectPath string `json:"project_path"`
@@ -258,13 +574,26 @@ func (s *Server) handleGetProjectStatus(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	// Get git status if it's a repo
+	// Get git status if it's a repo, reusing a recent result while HEAD is unchanged
 	if fileops.IsGitRepo(fullPath) {
+		ttl := time.Duration(s.config.GitStatusCacheTTL) * time.Second
+		headMod, cacheable := headModTime(fullPath)
+		cacheable = cacheable && ttl > 0
+		if cacheable {
+			if status, ok := s.gitStatuses.get(fullPath, headMod, ttl); ok {
+				s.jsonResponse(w, http.StatusOK, status)
+				return
+			}
+		}
+
 		status, err := fileops.GetStatus(context.Background(), fullPath)
 		if err != nil {
 			s.jsonError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		if cacheable {
+			s.gitStatuses.put(fullPath, headMod, status)
+		}
 		s.jsonResponse(w, http.StatusOK, status)
 		return
 	}
@@ -283,7 +612,7 @@ type DeleteRequest struct {
 	ProjectPath string `json:"project_path"`
 }
 
-// handleDeleteProject handles DELETE /api/v1/projects/{id}
+// handleDeleteProject handles DELETE /api/v1/projects/{id}?purge=true
 func (s *Server) handleDeleteProject(w http.ResponseWriter, r *http.Request, projectID int64) {
 	var req DeleteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -298,9 +627,18 @@ func (s *Server) handleDeleteProject(w http.ResponseWriter, r *http.Request, pro
 		return
 	}
 
+	if !s.pathLocks.TryLock(fullPath) {
+		s.jsonError(w, http.StatusConflict, errPathBusy)
+		return
+	}
+	defer s.pathLocks.Unlock(fullPath)
+
+	s.gitStatuses.invalidate(fullPath)
+
 	// Check if path exists
 	if !fileops.PathExists(fullPath) {
 		// Already deleted, return success
+		s.notifyDeleted(projectID, "path already deleted")
 		s.jsonResponse(w, http.StatusOK, map[string]interface{}{
 			"success": true,
 			"message": "path already deleted",
@@ -308,13 +646,33 @@ func (s *Server) handleDeleteProject(w http.ResponseWriter, r *http.Request, pro
 		return
 	}
 
+	// With a trash directory, deletes are recoverable until the sweeper purges them
+	if s.config.ProjectTrashDir != "" && r.URL.Query().Get("purge") != "true" {
+		trashPath, err := fileops.MoveToTrash(s.config.ProjectTrashDir, fullPath, fmt.Sprintf("project%d", projectID))
+		if err != nil {
+			s.jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		slog.Info("Moved project path to trash", "project_id", projectID, "path", fullPath, "trash_path", trashPath)
+		s.notifyDeleted(projectID, "moved to trash")
+
+		s.jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"success":    true,
+			"message":    "moved to trash",
+			"trash_path": trashPath,
+		})
+		return
+	}
+
 	// Delete
 	if err := fileops.RemoveAll(fullPath); err != nil {
 		s.jsonError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	log.Printf("[INFO] Deleted project %d path: %s", projectID, fullPath)
+	slog.Info("Deleted project path", "project_id", projectID, "path", fullPath)
+	s.notifyDeleted(projectID, "deleted successfully")
 
 	s.jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -322,6 +680,35 @@ func (s *Server) handleDeleteProject(w http.ResponseWriter, r *http.Request, pro
 	})
 }
 
+// notifyDeleted tells the master a project's files are gone. It runs in the
+// background and only logs failures; the delete itself has already succeeded.
+func (s *Server) notifyDeleted(projectID int64, message string) {
+	if !s.config.ProjectDeleteCallback {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.masterClient.UpdateProjectStatus(ctx, projectID, "deleted", message, ""); err != nil {
+			slog.Error("Failed to report project deleted", "project_id", projectID, "error", err)
+		}
+	}()
+}
+
+// gitCredentialHelper returns the credential.helper value that points git at
+// this agent binary, or "" when no credentials file is configured.
+func (s *Server) gitCredentialHelper() string {
+	if s.config.GitCredentialsFile == "" {
+		return ""
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		slog.Warn("Cannot locate agent binary for git credential helper", "error", err)
+		return ""
+	}
+	return fmt.Sprintf("!'%s' git-credential", exe)
+}
+
 // jsonResponse sends a JSON response.
 func (s *Server) jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -334,23 +721,48 @@ func (s *Server) jsonError(w http.ResponseWriter, status int, message string) {
 	s.jsonResponse(w, status, map[string]string{"error": message})
 }
 
-// Start starts the HTTP server.
+// Start starts the HTTP server. It returns immediately if the address cannot
+// be bound, otherwise it blocks until the server is shut down.
 func (s *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := tlsutil.New(s.config)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	s.mu.Lock()
 	s.httpServer = &http.Server{
 		Addr:         addr,
 		Handler:      s.mux,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
+		TLSConfig:    tlsConfig,
+	}
+	srv := s.httpServer
+	s.mu.Unlock()
+
+	if s.config.APITLSCertFile != "" && s.config.APITLSKeyFile != "" {
+		slog.Info("Starting API server", "addr", addr, "tls", true)
+		return srv.ServeTLS(ln, s.config.APITLSCertFile, s.config.APITLSKeyFile)
 	}
 
-	log.Printf("[INFO] Starting API server on %s", addr)
-	return s.httpServer.ListenAndServe()
+	slog.Info("Starting API server", "addr", addr)
+	return srv.Serve(ln)
 }
 
 // Shutdown gracefully shuts down the HTTP server.
 func (s *Server) Shutdown(ctx context.Context) error {
-	if s.httpServer != nil {
-		return s.httpServer.Shutdown(ctx)
+	s.mu.Lock()
+	srv := s.httpServer
+	s.mu.Unlock()
+
+	if srv != nil {
+		return srv.Shutdown(ctx)
 	}
 	return nil
 }
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// A clone or pull on a path another operation holds is refused with 409
+// rather than running git on top of it.
+func TestOverlappingProjectOperationsConflict(t *testing.T) {
+	projects := t.TempDir()
+	fullPath := filepath.Join(projects, "proj")
+	if err := os.Mkdir(fullPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{config: &config.Config{ProjectsPath: projects}}
+
+	pull := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/projects/1/pull", strings.NewReader(`{"project_path": "proj"}`))
+		w := httptest.NewRecorder()
+		s.handlePullProject(w, r, 1)
+		return w
+	}
+	clone := func() *httptest.ResponseRecorder {
+		body := `{"project_id": 1, "git_url": "https://example.com/repo.git", "target_path": "proj"}`
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/projects/clone", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.handleCloneProject(w, r)
+		return w
+	}
+
+	// An operation in flight on the path, as an async clone holds it
+	if !s.pathLocks.TryLock(fullPath) {
+		t.Fatal("path already locked")
+	}
+	for name, call := range map[string]func() *httptest.ResponseRecorder{"pull": pull, "clone": clone} {
+		w := call()
+		if w.Code != http.StatusConflict {
+			t.Fatalf("%s while busy: status %d, want 409", name, w.Code)
+		}
+		var resp map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp["error"] != errPathBusy {
+			t.Errorf("%s while busy: body %s, want error %q", name, w.Body, errPathBusy)
+		}
+	}
+	s.pathLocks.Unlock(fullPath)
+
+	// Released, the pull gets past the lock to its own checks
+	if w := pull(); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "not a git repository") {
+		t.Errorf("pull after release: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+// Without AGENT_GIT_PULL_TIMEOUT the pull reports the timeout it runs with.
+func TestPullReportsEffectiveTimeout(t *testing.T) {
+	projects := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", filepath.Join(projects, "proj")).CombinedOutput(); err != nil {
+		t.Skipf("git unavailable: %v: %s", err, out)
+	}
+	s := &Server{config: &config.Config{ProjectsPath: projects}}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/projects/1/pull", strings.NewReader(`{"project_path": "proj"}`))
+	w := httptest.NewRecorder()
+	s.handlePullProject(w, r, 1)
+
+	var result fileops.PullResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("status %d, body %s: %v", w.Code, w.Body, err)
+	}
+	if want := int(fileops.DefaultPullTimeout.Seconds()); result.TimeoutSeconds != want {
+		t.Errorf("timeout_seconds = %d, want %d", result.TimeoutSeconds, want)
+	}
+}
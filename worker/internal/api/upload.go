@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// uploadFormMemory is how much of an upload is buffered in memory; the rest
+// spills to a temporary file.
+const uploadFormMemory = 8 << 20
+
+// UploadResponse represents a project file upload response.
+type UploadResponse struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// handleUploadFile handles POST /api/v1/projects/{id}/files?overwrite=true, a
+// multipart form with the target "path" (relative to the projects path) and
+// the "file" itself.
+func (s *Server) handleUploadFile(w http.ResponseWriter, r *http.Request, projectID int64) {
+	maxBytes := int64(s.config.ProjectUploadMaxMB) * 1024 * 1024
+	overwrite := r.URL.Query().Get("overwrite") == "true"
+
+	// Large uploads outlast the server's read timeout; the size cap bounds them
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+	if maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes+uploadFormMemory)
+	}
+
+	if err := r.ParseMultipartForm(uploadFormMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.jsonError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload limit is %d MB", s.config.ProjectUploadMaxMB))
+			return
+		}
+		s.jsonError(w, http.StatusBadRequest, "invalid multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	target := r.FormValue("path")
+	if target == "" {
+		s.jsonError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+	if maxBytes > 0 && header.Size > maxBytes {
+		s.jsonError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload limit is %d MB", s.config.ProjectUploadMaxMB))
+		return
+	}
+
+	// Validate path
+	fullPath, err := fileops.ValidatePath(s.config.ProjectsPath, target)
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !s.checkFreeSpace(w, header.Size) {
+		return
+	}
+
+	if !s.pathLocks.TryLock(fullPath) {
+		s.jsonError(w, http.StatusConflict, errPathBusy)
+		return
+	}
+	defer s.pathLocks.Unlock(fullPath)
+
+	if info, err := os.Stat(fullPath); err == nil {
+		if info.IsDir() {
+			s.jsonError(w, http.StatusConflict, "path is a directory")
+			return
+		}
+		if !overwrite {
+			s.jsonError(w, http.StatusConflict, "file already exists, set overwrite=true to replace it")
+			return
+		}
+	}
+
+	if err := fileops.EnsureDir(filepath.Dir(fullPath)); err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Written beside the target and renamed, so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+".upload-*")
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fullPath)
+	}
+	if err != nil {
+		s.jsonError(w, http.StatusInternalServerError, fmt.Sprintf("failed to write file: %v", err))
+		return
+	}
+	s.gitStatuses.invalidateContaining(fullPath)
+
+	slog.Info("Uploaded project file", "project_id", projectID, "path", fullPath, "bytes", size)
+	s.jsonResponse(w, http.StatusCreated, UploadResponse{Path: fullPath, Size: size})
+}
+
+// handleDownloadFile handles GET /api/v1/projects/{id}/files?path=...
+func (s *Server) handleDownloadFile(w http.ResponseWriter, r *http.Request, projectID int64) {
+	target := r.URL.Query().Get("path")
+	if target == "" {
+		s.jsonError(w, http.StatusBadRequest, "path query parameter required")
+		return
+	}
+
+	// Validate path
+	fullPath, err := fileops.ValidatePath(s.config.ProjectsPath, target)
+	if err != nil {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info, err := fileops.GetInfo(fullPath)
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	if info.IsDir {
+		s.jsonError(w, http.StatusBadRequest, "path is a directory; download whole projects with GET /api/v1/projects/{id}/export")
+		return
+	}
+	// Opening a FIFO or device would block or never end
+	if !strings.HasPrefix(info.Mode, "-") {
+		s.jsonError(w, http.StatusBadRequest, "path is not a regular file")
+		return
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		s.jsonError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	defer f.Close()
+
+	// Large files outlast the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// ServeContent sets Content-Type (by extension, else sniffed) and
+	// Content-Length, and answers range requests
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(fullPath)))
+	http.ServeContent(w, r, filepath.Base(fullPath), time.Unix(info.ModTime, 0), f)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+func TestHandleDownloadFile(t *testing.T) {
+	projects := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projects, "proj", "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("epoch,loss\n1,0.5\n")
+	if err := os.WriteFile(filepath.Join(projects, "proj", "metrics.csv"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{config: &config.Config{ProjectsPath: projects}}
+	download := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/projects/1/files?path="+url.QueryEscape(path), nil)
+		w := httptest.NewRecorder()
+		s.handleDownloadFile(w, r, 1)
+		return w
+	}
+
+	w := download("proj/metrics.csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("regular file: status %d, body %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(content)) {
+		t.Errorf("Content-Length = %q, want %d", got, len(content))
+	}
+	if w.Body.String() != string(content) {
+		t.Errorf("body = %q, want %q", w.Body, content)
+	}
+
+	for path, want := range map[string]int{
+		"proj/data":        http.StatusBadRequest,
+		"../../etc/passwd": http.StatusBadRequest,
+		"/etc/passwd":      http.StatusBadRequest,
+		"proj/missing.csv": http.StatusNotFound,
+		"":                 http.StatusBadRequest,
+	} {
+		if w := download(path); w.Code != want {
+			t.Errorf("path %q: status %d, want %d", path, w.Code, want)
+		}
+	}
+}
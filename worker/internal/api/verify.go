@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// VerifyRequest represents a git remote check before a clone.
+type VerifyRequest struct {
+	GitURL      string            `json:"git_url"`
+	Credentials *CloneCredentials `json:"credentials,omitempty"`
+}
+
+// VerifyResponse represents the result of a git remote check. Error never
+// contains the credentials.
+type VerifyResponse struct {
+	Reachable     bool   `json:"reachable"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+	Commit        string `json:"commit,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// handleVerifyRemote handles POST /api/v1/projects/verify
+func (s *Server) handleVerifyRemote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req VerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.GitURL == "" {
+		s.jsonError(w, http.StatusBadRequest, "git_url is required")
+		return
+	}
+	if req.Credentials != nil && req.Credentials.Token == "" {
+		s.jsonError(w, http.StatusBadRequest, "credentials.token is required")
+		return
+	}
+
+	var creds *fileops.GitCredential
+	if req.Credentials != nil {
+		creds = &fileops.GitCredential{Username: req.Credentials.Username, Password: req.Credentials.Token}
+	}
+
+	// Its own short timeout: this answers the UI while the user waits
+	timeout := time.Duration(s.config.GitVerifyTimeout) * time.Second
+	info, err := fileops.VerifyRemote(r.Context(), req.GitURL, s.gitCredentialHelper(), creds, timeout)
+	if errors.Is(err, fileops.ErrInvalidRemote) {
+		s.jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		s.jsonResponse(w, http.StatusOK, VerifyResponse{Error: err.Error()})
+		return
+	}
+
+	s.jsonResponse(w, http.StatusOK, VerifyResponse{
+		Reachable:     true,
+		DefaultBranch: info.DefaultBranch,
+		Commit:        info.Commit,
+	})
+}
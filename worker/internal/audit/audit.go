@@ -0,0 +1,96 @@
+// Package audit records security-relevant events on the agent's API surface.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Audit event types.
+const (
+	EventAuthSuccess = "auth_success"
+	EventAuthFailure = "auth_failure"
+)
+
+// Entry is a single audit record.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	ProjectID  *int64    `json:"project_id,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// Logger appends audit entries as JSON lines to a file.
+// A nil Logger discards entries.
+type Logger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewLogger opens (or creates) the audit log at path. An empty path disables auditing.
+func NewLogger(path string) (*Logger, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &Logger{file: f}, nil
+}
+
+// Log writes an entry.
+func (l *Logger) Log(entry *Entry) {
+	if l == nil {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		slog.Error("Failed to write audit log", "error", err)
+	}
+}
+
+// Close closes the underlying file.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+type entryKey struct{}
+
+// WithEntry attaches an in-progress entry to a request context so handlers can annotate it.
+func WithEntry(ctx context.Context, entry *Entry) context.Context {
+	return context.WithValue(ctx, entryKey{}, entry)
+}
+
+// SetProjectID records the project a request acted on.
+func SetProjectID(ctx context.Context, projectID int64) {
+	if entry, ok := ctx.Value(entryKey{}).(*Entry); ok {
+		entry.ProjectID = &projectID
+	}
+}
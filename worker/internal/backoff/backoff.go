@@ -0,0 +1,58 @@
+// Package backoff provides retry delays for the worker agent.
+package backoff
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff produces exponentially growing delays between retries: Base, then
+// twice that per attempt, capped at Max. Each delay is jittered to between
+// half and all of its value, so agents restarted together don't retry in
+// lockstep. The zero value is not usable; set Base and Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+
+	attempt int
+}
+
+// Next returns the delay before the next retry.
+func (b *Backoff) Next() time.Duration {
+	d := b.Max
+	if b.attempt < 62 && b.Base<<b.attempt > 0 && b.Base<<b.attempt < b.Max {
+		d = b.Base << b.attempt
+	}
+	b.attempt++
+
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(d-half+1)
+}
+
+// Reset starts the delays over from Base, e.g. after a success.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// Wait sleeps for the next delay. It returns ctx.Err() as soon as ctx is
+// done, without waiting out the delay.
+func (b *Backoff) Wait(ctx context.Context) error {
+	return Sleep(ctx, b.Next())
+}
+
+// Sleep pauses for d or until ctx is done, returning ctx.Err() in that case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/version"
+)
+
+// ArtifactUploadResponse is the master's answer to an artifact upload.
+type ArtifactUploadResponse struct {
+	FileCount int   `json:"file_count"`
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// UploadArtifacts sends a job's artifacts as a gzip-compressed tar of size
+// bytes. It is not retried, as the archive is streamed.
+func (c *MasterClient) UploadArtifacts(ctx context.Context, jobID int, archive io.Reader, size int64) (*ArtifactUploadResponse, error) {
+	url := fmt.Sprintf("%s/api/v1/jobs/%d/artifacts", c.cfg.MasterURL, jobID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("User-Agent", version.UserAgent(c.cfg.NodeName))
+	req.Header.Set("X-Node-ID", c.cfg.NodeName)
+	if token := c.Token(); token != "" {
+		req.Header.Set("X-Agent-Token", token)
+	}
+
+	// Uploads can take longer than the client timeout for API calls
+	client := *c.httpClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("unauthorized: token invalid")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{Code: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	var result ArtifactUploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the master while recent
+// requests to it have kept failing.
+var ErrCircuitOpen = errors.New("master unreachable, not sending requests until cooldown ends")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// breaker stops requests to the master after threshold consecutive failures.
+// Once open, requests fail immediately for the cooldown, then one request is
+// let through to probe whether the master is back.
+type breaker struct {
+	threshold int // 0 disables the breaker
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool // half-open probe in flight
+}
+
+// allow reports whether a request may be sent, returning ErrCircuitOpen if not.
+func (b *breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = circuitHalfOpen
+		slog.Info("Probing master after cooldown", "circuit", "half-open")
+	case circuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+	default:
+		return nil
+	}
+
+	b.probing = true
+	return nil
+}
+
+// record updates the breaker with the outcome of a request let through by allow.
+func (b *breaker) record(ctx context.Context, err error) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.probing
+	b.probing = false
+
+	switch {
+	case ctx.Err() != nil:
+		// Abandoned by the caller; says nothing about the master
+	case !masterDown(err):
+		if b.state != circuitClosed {
+			slog.Info("Master reachable again", "circuit", "closed")
+		}
+		b.state = circuitClosed
+		b.failures = 0
+	case b.state == circuitHalfOpen && probe:
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+		slog.Warn("Master still unreachable", "circuit", "open", "cooldown", b.cooldown.String(), "error", err)
+	case b.state == circuitClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state = circuitOpen
+			b.openedAt = time.Now()
+			slog.Warn("Master unreachable, pausing requests",
+				"circuit", "open", "failures", b.failures, "cooldown", b.cooldown.String(), "error", err)
+		}
+	}
+}
+
+// masterDown reports whether a failed request means the master is down: it
+// could not be reached or answered 5xx. Any other answer shows it is up.
+func masterDown(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= http.StatusInternalServerError
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
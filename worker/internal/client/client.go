@@ -5,48 +5,110 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/YangYuS8/mlsmanager-worker/internal/backoff"
 	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+	"github.com/YangYuS8/mlsmanager-worker/internal/health"
+	"github.com/YangYuS8/mlsmanager-worker/internal/metrics"
 	"github.com/YangYuS8/mlsmanager-worker/internal/sysinfo"
+	"github.com/YangYuS8/mlsmanager-worker/internal/tlsutil"
+	"github.com/YangYuS8/mlsmanager-worker/internal/version"
 )
 
 // MasterClient communicates with the master node.
 type MasterClient struct {
 	cfg        *config.Config
 	httpClient *http.Client
-	token      string
-	nodeID     string // node_id string, not database id
+	health     *health.Monitor
+	gpuUsage   func() []JobGPUUsage
+	runtimes   func() map[string]string
+	datasets   datasetState
+	breaker    breaker
+	statuses   *statusQueue
+
+	// Register replaces these while the heartbeat, job and API goroutines read them
+	mu     sync.RWMutex
+	token  string
+	nodeID string // node_id string, not database id
 }
 
 // NewMasterClient creates a new master client.
-func NewMasterClient(cfg *config.Config) *MasterClient {
-	token := cfg.LoadToken()
+func NewMasterClient(cfg *config.Config) (*MasterClient, error) {
+	tlsConfig, err := tlsutil.NewMasterClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := cfg.LoadToken()
+	if errors.Is(err, config.ErrInvalidToken) {
+		slog.Warn("Ignoring corrupt token file, will re-register", "error", err)
+	} else if err != nil {
+		slog.Warn("Failed to read token file, will re-register", "error", err)
+	}
 	c := &MasterClient{
 		cfg: cfg,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
 		},
 		token: token,
+		breaker: breaker{
+			threshold: cfg.MasterBreakerThreshold,
+			cooldown:  time.Duration(cfg.MasterBreakerCooldown) * time.Second,
+		},
+		statuses: newStatusQueue(filepath.Join(cfg.JobsWorkspace, ".status_queue.jsonl")),
+		health: health.NewMonitor(health.Options{
+			CheckGPU:     cfg.HealthCheckGPU,
+			CheckStorage: cfg.HealthCheckStorage,
+			StoragePath:  cfg.StoragePath,
+		}),
 	}
 	// If we have a saved token, we're already registered with this node_id
 	if token != "" {
 		c.nodeID = cfg.NodeName
 	}
-	return c
+	return c, nil
+}
+
+// credentials returns the current agent token and registered node ID.
+func (c *MasterClient) credentials() (token, nodeID string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token, c.nodeID
 }
 
 // NodeID returns the registered node ID.
 func (c *MasterClient) NodeID() string {
-	return c.nodeID
+	_, nodeID := c.credentials()
+	return nodeID
 }
 
 // Token returns the current agent token.
 func (c *MasterClient) Token() string {
-	return c.token
+	token, _ := c.credentials()
+	return token
+}
+
+// Healthy reports whether the last heartbeat self-check passed, and the reason if not.
+func (c *MasterClient) Healthy() (bool, string) {
+	return c.health.Healthy()
+}
+
+// Ping checks that the master is reachable, without retrying.
+func (c *MasterClient) Ping(ctx context.Context) error {
+	return c.send(ctx, "GET", "/health", nil, nil, false, false)
 }
 
 // RegisterRequest is the payload for node registration.
@@ -64,6 +126,10 @@ type RegisterRequest struct {
 	GPUInfo        *string `json:"gpu_info"`
 	StorageTotalGB *int    `json:"storage_total_gb"`
 	StorageUsedGB  *int    `json:"storage_used_gb"`
+
+	// NVIDIA driver and CUDA versions, omitted on nodes without NVIDIA GPUs
+	DriverVersion *string `json:"driver_version,omitempty"`
+	CUDAVersion   *string `json:"cuda_version,omitempty"`
 }
 
 // RegisterResponse is the response from node registration.
@@ -75,7 +141,7 @@ type RegisterResponse struct {
 
 // Register registers this agent with the master node.
 func (c *MasterClient) Register(ctx context.Context) error {
-	sysInfo := sysinfo.Collect(c.cfg.StoragePath)
+	sysInfo := sysinfo.Collect(c.cfg.StoragePath, c.diskProbe())
 
 	// Determine the hostname for backend to reach this worker
 	// In dev mode, use localhost; otherwise use actual hostname
@@ -99,7 +165,15 @@ func (c *MasterClient) Register(ctx context.Context) error {
 		GPUInfo:        sysInfo.GPUInfo,
 		StorageTotalGB: sysInfo.StorageTotalGB,
 		StorageUsedGB:  sysInfo.StorageUsedGB,
+		DriverVersion:  sysInfo.DriverVersion,
+		CUDAVersion:    sysInfo.CUDAVersion,
+	}
+
+	// Fail here with a clear reason rather than on an opaque master rejection
+	if err := req.validate(); err != nil {
+		return fmt.Errorf("registration failed: %w", err)
 	}
+	slog.Info("Registering", "node", req.summary())
 
 	var resp RegisterResponse
 	err := c.doRequest(ctx, "POST", "/api/v1/nodes/register", req, &resp, false)
@@ -107,40 +181,71 @@ func (c *MasterClient) Register(ctx context.Context) error {
 		return fmt.Errorf("registration failed: %w", err)
 	}
 
+	c.mu.Lock()
 	c.token = resp.Token
 	// Use the node_id we sent (string), not database id
 	c.nodeID = c.cfg.NodeName
+	c.mu.Unlock()
 
 	// Save token to file
-	if err := c.cfg.SaveToken(c.token); err != nil {
+	if err := c.cfg.SaveToken(resp.Token); err != nil {
 		// Log warning but don't fail registration
-		fmt.Printf("[WARN] Failed to save token: %v\n", err)
+		slog.Warn("Failed to save token", "error", err)
 	}
 
 	return nil
 }
 
+// JobGPUUsage is GPU memory used by a process belonging to a job.
+type JobGPUUsage struct {
+	JobID        int    `json:"job_id"`
+	PID          int32  `json:"pid"`
+	GPUUUID      string `json:"gpu_uuid"`
+	UsedMemoryMB int    `json:"used_memory_mb"`
+}
+
+// SetGPUUsageProvider sets the function used to attribute GPU usage to jobs in heartbeats.
+func (c *MasterClient) SetGPUUsageProvider(fn func() []JobGPUUsage) {
+	c.gpuUsage = fn
+}
+
+// SetRuntimeProvider sets the function reporting unavailable job runtimes in heartbeats.
+func (c *MasterClient) SetRuntimeProvider(fn func() map[string]string) {
+	c.runtimes = fn
+}
+
 // HeartbeatRequest is the payload for heartbeat.
 type HeartbeatRequest struct {
 	Status         string  `json:"status"`
+	StatusReason   string  `json:"status_reason,omitempty"`
 	CPUCount       int     `json:"cpu_count"`
 	MemoryTotalGB  *int    `json:"memory_total_gb"`
 	GPUCount       int     `json:"gpu_count"`
 	GPUInfo        *string `json:"gpu_info"`
 	StorageTotalGB *int    `json:"storage_total_gb"`
 	StorageUsedGB  *int    `json:"storage_used_gb"`
+
+	JobGPUUsage []JobGPUUsage        `json:"job_gpu_usage,omitempty"`
+	Volumes     []sysinfo.VolumeInfo `json:"volumes,omitempty"`
+	GPUs        []sysinfo.GPUStat    `json:"gpus"`
+
+	// Job runtimes (docker, conda, venv, system) that can't run jobs, with the reason
+	UnavailableRuntimes map[string]string `json:"unavailable_runtimes,omitempty"`
 }
 
 // Heartbeat sends a heartbeat to the master node.
 func (c *MasterClient) Heartbeat(ctx context.Context) error {
-	if c.nodeID == "" {
+	nodeID := c.NodeID()
+	if nodeID == "" {
 		return fmt.Errorf("not registered")
 	}
 
-	sysInfo := sysinfo.Collect(c.cfg.StoragePath)
+	sysInfo := sysinfo.Collect(c.cfg.StoragePath, c.diskProbe())
+	status, reason := c.health.Check(sysInfo)
 
 	req := HeartbeatRequest{
-		Status:         "online",
+		Status:         status,
+		StatusReason:   reason,
 		CPUCount:       sysInfo.CPUCount,
 		MemoryTotalGB:  sysInfo.MemoryTotalGB,
 		GPUCount:       sysInfo.GPUCount,
@@ -148,9 +253,58 @@ func (c *MasterClient) Heartbeat(ctx context.Context) error {
 		StorageTotalGB: sysInfo.StorageTotalGB,
 		StorageUsedGB:  sysInfo.StorageUsedGB,
 	}
+	req.Volumes = sysInfo.Volumes
+	req.GPUs = sysInfo.GPUs
+	if c.runtimes != nil {
+		req.UnavailableRuntimes = c.runtimes()
+	}
+	if c.gpuUsage != nil && sysInfo.GPUCount > 0 {
+		req.JobGPUUsage = c.gpuUsage()
+	}
 
-	url := fmt.Sprintf("/api/v1/nodes/%s/heartbeat", c.nodeID)
-	return c.doRequest(ctx, "POST", url, req, nil, true)
+	recordSystemMetrics(sysInfo)
+
+	// Not retried: a missed heartbeat is superseded by the next one
+	url := fmt.Sprintf("/api/v1/nodes/%s/heartbeat", nodeID)
+	err := c.doRequest(ctx, "POST", url, req, nil, true)
+	if err != nil {
+		metrics.HeartbeatUp.Set(0)
+	} else {
+		metrics.HeartbeatUp.Set(1)
+	}
+	return err
+}
+
+// diskProbe returns which mounts to probe and how.
+func (c *MasterClient) diskProbe() sysinfo.DiskProbe {
+	return sysinfo.DiskProbe{
+		Paths:       c.cfg.VolumePaths(),
+		Timeout:     time.Duration(c.cfg.DiskProbeTimeout) * time.Second,
+		Concurrency: c.cfg.DiskProbeConcurrency,
+	}
+}
+
+// recordSystemMetrics publishes the latest resource readings.
+func recordSystemMetrics(info *sysinfo.SystemInfo) {
+	if usage, err := sysinfo.GetCPUUsage(); err == nil {
+		metrics.CPUUsagePercent.Set(usage)
+	}
+	metrics.GPUCount.Set(float64(info.GPUCount))
+	if len(info.GPUs) > 0 {
+		var util, used int
+		for _, g := range info.GPUs {
+			util += g.UtilizationPercent
+			used += g.MemoryUsedMB
+		}
+		metrics.GPUUtilization.Set(float64(util) / float64(len(info.GPUs)))
+		metrics.GPUMemoryUsedMB.Set(float64(used))
+	}
+	if info.StorageTotalGB != nil {
+		metrics.StorageTotalGB.Set(float64(*info.StorageTotalGB))
+	}
+	if info.StorageUsedGB != nil {
+		metrics.StorageUsedGB.Set(float64(*info.StorageUsedGB))
+	}
 }
 
 // Job represents a job from the master.
@@ -163,12 +317,17 @@ type Job struct {
 	EnvironmentVars  map[string]string `json:"environment_vars"`
 	WorkingDirectory string            `json:"working_directory"`
 	TimeoutSeconds   int               `json:"timeout_seconds"`
+
+	// Numbering of a job the master retries after a failure. The master
+	// doesn't retry jobs yet, so both are zero for now.
+	Attempt     int `json:"attempt,omitempty"`
+	MaxAttempts int `json:"max_attempts,omitempty"`
 }
 
 // FetchPendingJobs fetches pending jobs from the master.
 func (c *MasterClient) FetchPendingJobs(ctx context.Context) ([]Job, error) {
 	var jobs []Job
-	url := fmt.Sprintf("/api/v1/jobs/queue/%s", c.nodeID)
+	url := fmt.Sprintf("/api/v1/jobs/queue/%s", c.NodeID())
 	err := c.doRequest(ctx, "GET", url, nil, &jobs, true)
 	if err != nil {
 		return nil, err
@@ -181,28 +340,56 @@ type JobStatusUpdate struct {
 	Status       string  `json:"status"`
 	ExitCode     *int    `json:"exit_code,omitempty"`
 	ErrorMessage *string `json:"error_message,omitempty"`
+	Attempt      int     `json:"attempt,omitempty"`
+	MaxAttempts  int     `json:"max_attempts,omitempty"`
+
+	Metrics map[string]any `json:"metrics,omitempty"`
+
+	// Resource profile of a finished job
+	PeakMemoryMB  int     `json:"peak_memory_mb,omitempty"`
+	AvgCPUPercent float64 `json:"avg_cpu_percent,omitempty"`
 }
 
 // UpdateJobStatus updates the status of a job.
 func (c *MasterClient) UpdateJobStatus(ctx context.Context, jobID int, status string, exitCode *int, errorMsg *string) error {
-	req := JobStatusUpdate{
+	return c.SendJobStatus(ctx, jobID, JobStatusUpdate{
 		Status:       status,
 		ExitCode:     exitCode,
 		ErrorMessage: errorMsg,
+	})
+}
+
+// SendJobStatus sends a full job status update. A final status the master
+// can't be reached for is queued on disk and delivered by RunStatusQueue once
+// it is back; while any are queued, new final statuses go behind them.
+func (c *MasterClient) SendJobStatus(ctx context.Context, jobID int, update JobStatusUpdate) error {
+	if finalStatus(update.Status) && c.statuses.Len() > 0 {
+		return c.queueJobStatus(jobID, update, errors.New("earlier job statuses are still queued"))
 	}
 
 	url := fmt.Sprintf("/api/v1/jobs/%d/status", jobID)
-	return c.doRequest(ctx, "POST", url, req, nil, true)
+	err := c.doRetriedRequest(ctx, "POST", url, update, nil, true)
+	if err != nil && finalStatus(update.Status) && unreachable(err) {
+		return c.queueJobStatus(jobID, update, err)
+	}
+	return err
 }
 
 // DatasetInfo represents a scanned dataset.
 type DatasetInfo struct {
-	Name        string  `json:"name"`
-	LocalPath   string  `json:"local_path"`
-	SizeBytes   *int64  `json:"size_bytes,omitempty"`
-	FileCount   *int    `json:"file_count,omitempty"`
-	Format      *string `json:"format,omitempty"`
-	Description *string `json:"description,omitempty"`
+	Name         string  `json:"name"`
+	LocalPath    string  `json:"local_path"`
+	AbsolutePath string  `json:"absolute_path,omitempty"`
+	SourceMount  string  `json:"source_mount,omitempty"`
+	SizeBytes    *int64  `json:"size_bytes,omitempty"`
+	FileCount    *int    `json:"file_count,omitempty"`
+	Format       *string `json:"format,omitempty"`
+	Description  *string `json:"description,omitempty"`
+
+	// Checksum is "meta-sha256:<hex>" over the sorted relative path, size and
+	// mtime of every file, or "sha256:<hex>" over paths and contents when
+	// content checksums are enabled
+	Checksum string `json:"checksum,omitempty"`
 }
 
 // ReportDatasetsRequest is the payload for reporting datasets.
@@ -210,14 +397,20 @@ type ReportDatasetsRequest struct {
 	Datasets []DatasetInfo `json:"datasets"`
 }
 
-// ReportDatasets reports scanned datasets to the master.
-func (c *MasterClient) ReportDatasets(ctx context.Context, datasets []DatasetInfo) error {
-	if len(datasets) == 0 {
-		return nil
-	}
+// JobLogUpload is the payload for adding output to a job's log on the master.
+type JobLogUpload struct {
+	Content string `json:"content"`
+	Append  bool   `json:"append"`
+}
 
-	req := ReportDatasetsRequest{Datasets: datasets}
-	return c.doRequest(ctx, "POST", "/api/v1/datasets/batch", req, nil, true)
+// AppendJobLog adds lines of output to a job's log on the master.
+func (c *MasterClient) AppendJobLog(ctx context.Context, jobID int, lines []string) error {
+	req := JobLogUpload{
+		Content: strings.Join(lines, "\n") + "\n",
+		Append:  true,
+	}
+	url := fmt.Sprintf("/api/v1/jobs/%d/logs", jobID)
+	return c.doRequest(ctx, "POST", url, req, nil, true)
 }
 
 // ProjectStatusUpdate represents a project status update request.
@@ -235,20 +428,86 @@ func (c *MasterClient) UpdateProjectStatus(ctx context.Context, projectID int64,
 		LocalPath: localPath,
 	}
 	path := fmt.Sprintf("/api/v1/internal/projects/%d/status", projectID)
-	return c.doRequest(ctx, "POST", path, req, nil, true)
+	return c.doRetriedRequest(ctx, "POST", path, req, nil, true)
+}
+
+// StatusError is returned when the master answers with a non-2xx status.
+type StatusError struct {
+	Code int
+	Body string
+
+	// RetryAfter is the delay asked for by a Retry-After header, if any.
+	RetryAfter time.Duration
 }
 
-// doRequest performs an HTTP request.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.Code, e.Body)
+}
+
+// doRequest performs an HTTP request, retrying idempotent methods on
+// transient failures.
 func (c *MasterClient) doRequest(ctx context.Context, method, path string, body any, result any, useToken bool) error {
-	url := c.cfg.MasterURL + path
+	return c.send(ctx, method, path, body, result, useToken, idempotent(method))
+}
 
-	var bodyReader io.Reader
+// doRetriedRequest is doRequest for requests that are safe to repeat
+// whatever their method, such as status updates.
+func (c *MasterClient) doRetriedRequest(ctx context.Context, method, path string, body any, result any, useToken bool) error {
+	return c.send(ctx, method, path, body, result, useToken, true)
+}
+
+// send performs an HTTP request, making up to AGENT_REQUEST_MAX_ATTEMPTS
+// attempts when retry is set. Authenticated requests go through the circuit
+// breaker; registration and pings have their own retry policy and bypass it.
+func (c *MasterClient) send(ctx context.Context, method, path string, body any, result any, useToken, retry bool) error {
+	var data []byte
 	if body != nil {
 		jsonData, err := json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("failed to marshal request: %w", err)
 		}
-		bodyReader = bytes.NewReader(jsonData)
+		data = jsonData
+	}
+
+	attempts := 1
+	if retry {
+		attempts = max(c.cfg.RequestMaxAttempts, 1)
+	}
+	delays := backoff.Backoff{
+		Base: time.Second,
+		Max:  time.Duration(max(c.cfg.RequestBackoffMax, 1)) * time.Second,
+	}
+
+	for attempt := 1; ; attempt++ {
+		if useToken {
+			if err := c.breaker.allow(); err != nil {
+				return err
+			}
+		}
+		err := c.attempt(ctx, method, path, data, result, useToken)
+		if useToken {
+			c.breaker.record(ctx, err)
+		}
+		if err == nil || attempt >= attempts || !retryable(ctx, err) {
+			return err
+		}
+
+		delay := max(delays.Next(), min(retryAfter(err), delays.Max))
+		slog.Warn("Request failed, retrying",
+			"method", method, "path", path, "attempt", attempt, "max_attempts", attempts, "in", delay.String(), "error", err)
+		if backoff.Sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// attempt performs one HTTP request with an already encoded body.
+func (c *MasterClient) attempt(ctx context.Context, method, path string, data []byte, result any, useToken bool) error {
+	url := c.cfg.MasterURL + path
+
+	var bodyReader io.Reader
+	if data != nil {
+		bodyReader = bytes.NewReader(data)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
@@ -257,8 +516,10 @@ func (c *MasterClient) doRequest(ctx context.Context, method, path string, body
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if useToken && c.token != "" {
-		req.Header.Set("X-Agent-Token", c.token)
+	req.Header.Set("User-Agent", version.UserAgent(c.cfg.NodeName))
+	req.Header.Set("X-Node-ID", c.cfg.NodeName)
+	if token := c.Token(); useToken && token != "" {
+		req.Header.Set("X-Agent-Token", token)
 	}
 
 	resp, err := c.httpClient.Do(req)
@@ -273,7 +534,11 @@ func (c *MasterClient) doRequest(ctx context.Context, method, path string, body
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+		return &StatusError{
+			Code:       resp.StatusCode,
+			Body:       string(bodyBytes),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	if result != nil {
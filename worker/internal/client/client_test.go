@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+func TestRegisterWhileReadingCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(RegisterResponse{Token: "tok"})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	c, err := NewMasterClient(&config.Config{
+		MasterURL:     srv.URL,
+		NodeName:      "node-1",
+		APIPort:       8002,
+		TokenFile:     filepath.Join(dir, "token"),
+		StoragePath:   dir,
+		JobsWorkspace: dir,
+		TLSMinVersion: "1.2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				c.Token()
+				c.NodeID()
+			}
+		}()
+	}
+	if err := c.Register(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if c.Token() != "tok" || c.NodeID() != "node-1" {
+		t.Errorf("credentials = %q, %q, want tok, node-1", c.Token(), c.NodeID())
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DatasetDeltaRequest is the payload for reporting dataset changes since the
+// last report. Removed holds dataset names.
+type DatasetDeltaRequest struct {
+	Added   []DatasetInfo `json:"added"`
+	Changed []DatasetInfo `json:"changed"`
+	Removed []string      `json:"removed"`
+}
+
+// DatasetRemovalRequest is the payload signalling datasets that are gone,
+// sent with batch reports when the master has no delta endpoint.
+type DatasetRemovalRequest struct {
+	Names      []string `json:"names"`
+	LocalPaths []string `json:"local_paths"`
+}
+
+// reportedDataset is what the master was last told about a dataset.
+type reportedDataset struct {
+	fingerprint string
+	localPath   string
+}
+
+// datasetState remembers what the master was last told about each dataset.
+type datasetState struct {
+	mu                 sync.Mutex
+	reported           map[string]reportedDataset // by name
+	lastFull           time.Time
+	unsupported        bool // the master has no delta endpoint
+	removalUnsupported bool // nor a removal endpoint
+}
+
+// fingerprint identifies a dataset's reported contents, so any change in
+// size, file count or metadata makes it "changed".
+func fingerprint(d DatasetInfo) string {
+	data, _ := json.Marshal(d)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ReportDatasets reports scanned datasets to the master. With delta reports
+// enabled only the datasets that changed since the last report are sent,
+// with a full report every AGENT_DATASET_FULL_SYNC_INTERVAL. Masters without
+// the delta endpoint get the added and changed datasets through the batch
+// endpoint and removals as a separate signal. datasets must be a complete
+// scan: reported datasets missing from it are reported as removed.
+func (c *MasterClient) ReportDatasets(ctx context.Context, datasets []DatasetInfo) error {
+	s := &c.datasets
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fullSync := time.Duration(c.cfg.DatasetFullSyncInterval) * time.Second
+	if !c.cfg.DatasetDeltaReports || s.lastFull.IsZero() || time.Since(s.lastFull) >= fullSync {
+		return c.reportAllDatasets(ctx, s, datasets)
+	}
+
+	delta, current := s.diff(datasets)
+	if len(delta.Added)+len(delta.Changed)+len(delta.Removed) == 0 {
+		return nil
+	}
+
+	if !s.unsupported {
+		err := c.doRequest(ctx, "POST", "/api/v1/datasets/delta", delta, nil, true)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && deltaUnsupported(statusErr.Code) {
+			slog.Warn("Master does not support dataset deltas, sending changes as batch reports")
+			s.unsupported = true
+		} else if err != nil {
+			return err
+		} else {
+			s.reported = current
+			return nil
+		}
+	}
+
+	if err := c.reportDatasetChanges(ctx, s, delta); err != nil {
+		return err
+	}
+	s.reported = current
+	return nil
+}
+
+// reportDatasetChanges sends a delta without the delta endpoint: added and
+// changed datasets through the batch endpoint, which creates or updates
+// them, and removals to the removal endpoint. A master without the latter
+// keeps removed datasets until they are deleted there.
+func (c *MasterClient) reportDatasetChanges(ctx context.Context, s *datasetState, delta DatasetDeltaRequest) error {
+	if changed := append(delta.Added, delta.Changed...); len(changed) > 0 {
+		req := ReportDatasetsRequest{Datasets: changed}
+		if err := c.doRequest(ctx, "POST", "/api/v1/datasets/batch", req, nil, true); err != nil {
+			return err
+		}
+	}
+
+	if len(delta.Removed) == 0 || s.removalUnsupported {
+		return nil
+	}
+	removal := DatasetRemovalRequest{Names: delta.Removed}
+	for _, name := range delta.Removed {
+		removal.LocalPaths = append(removal.LocalPaths, s.reported[name].localPath)
+	}
+	err := c.doRequest(ctx, "POST", "/api/v1/datasets/removed", removal, nil, true)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && deltaUnsupported(statusErr.Code) {
+		slog.Warn("Master does not accept dataset removals, removed datasets stay listed")
+		s.removalUnsupported = true
+		return nil
+	}
+	return err
+}
+
+// reportAllDatasets sends every dataset and records them as reported. The
+// batch endpoint never removes datasets, so previously reported ones stay
+// known until a delta reports them removed.
+func (c *MasterClient) reportAllDatasets(ctx context.Context, s *datasetState, datasets []DatasetInfo) error {
+	if len(datasets) > 0 {
+		req := ReportDatasetsRequest{Datasets: datasets}
+		if err := c.doRequest(ctx, "POST", "/api/v1/datasets/batch", req, nil, true); err != nil {
+			return err
+		}
+	}
+
+	if s.reported == nil {
+		s.reported = make(map[string]reportedDataset)
+	}
+	for _, d := range datasets {
+		s.reported[d.Name] = reportedDataset{fingerprint: fingerprint(d), localPath: d.LocalPath}
+	}
+	s.lastFull = time.Now()
+	return nil
+}
+
+// diff compares a scan with the reported state and returns the changes and
+// the state after they are applied.
+func (s *datasetState) diff(datasets []DatasetInfo) (DatasetDeltaRequest, map[string]reportedDataset) {
+	delta := DatasetDeltaRequest{
+		Added:   []DatasetInfo{},
+		Changed: []DatasetInfo{},
+		Removed: []string{},
+	}
+	current := make(map[string]reportedDataset, len(datasets))
+
+	for _, d := range datasets {
+		fp := fingerprint(d)
+		current[d.Name] = reportedDataset{fingerprint: fp, localPath: d.LocalPath}
+		prev, ok := s.reported[d.Name]
+		switch {
+		case !ok:
+			delta.Added = append(delta.Added, d)
+		case prev.fingerprint != fp:
+			delta.Changed = append(delta.Changed, d)
+		}
+	}
+
+	for name := range s.reported {
+		if _, ok := current[name]; !ok {
+			delta.Removed = append(delta.Removed, name)
+		}
+	}
+
+	return delta, current
+}
+
+// deltaUnsupported reports whether a status code means the master lacks the
+// delta endpoint.
+func deltaUnsupported(code int) bool {
+	return code == http.StatusNotFound || code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented
+}
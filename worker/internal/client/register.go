@@ -0,0 +1,84 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Limits for registration fields, matching the master's schema where it has
+// one and otherwise chosen well above any real hardware.
+const (
+	maxNodeIDLen  = 50
+	maxNameLen    = 100
+	maxHostLen    = 255
+	maxGPUInfoLen = 4096
+	maxCPUCount   = 4096
+	maxGPUCount   = 1024
+	maxSizeGB     = 1 << 20 // 1 PB
+)
+
+// validate rejects registration payloads the master would refuse and
+// normalizes odd sysinfo values instead of sending them.
+func (r *RegisterRequest) validate() error {
+	switch {
+	case r.NodeID == "" || r.Name == "":
+		return fmt.Errorf("node name is empty, set AGENT_NODE_NAME")
+	case len(r.NodeID) > maxNodeIDLen:
+		return fmt.Errorf("node name %q is longer than %d characters", r.NodeID, maxNodeIDLen)
+	case len(r.Name) > maxNameLen:
+		return fmt.Errorf("node name is longer than %d characters", maxNameLen)
+	case len(r.Host) > maxHostLen || len(r.Hostname) > maxHostLen:
+		return fmt.Errorf("node hostname is longer than %d characters", maxHostLen)
+	case r.AgentPort < 1 || r.AgentPort > 65535:
+		return fmt.Errorf("invalid agent port %d", r.AgentPort)
+	}
+
+	r.CPUCount = clamp(r.CPUCount, 0, maxCPUCount)
+	r.GPUCount = clamp(r.GPUCount, 0, maxGPUCount)
+	r.MemoryTotalGB = clampGB(r.MemoryTotalGB)
+	r.StorageTotalGB = clampGB(r.StorageTotalGB)
+	r.StorageUsedGB = clampGB(r.StorageUsedGB)
+	if r.StorageUsedGB != nil && r.StorageTotalGB != nil && *r.StorageUsedGB > *r.StorageTotalGB {
+		used := *r.StorageTotalGB
+		r.StorageUsedGB = &used
+	}
+	if r.GPUInfo != nil && len(*r.GPUInfo) > maxGPUInfoLen {
+		info := (*r.GPUInfo)[:maxGPUInfoLen]
+		r.GPUInfo = &info
+	}
+
+	return nil
+}
+
+// summary describes the payload for the registration log line.
+func (r *RegisterRequest) summary() string {
+	gb := func(v *int) string {
+		if v == nil {
+			return "unknown"
+		}
+		return strconv.Itoa(*v)
+	}
+	s := fmt.Sprintf("node_id=%s host=%q agent_port=%d cpus=%d memory_gb=%s gpus=%d storage_gb=%s/%s",
+		r.NodeID, r.Host, r.AgentPort, r.CPUCount, gb(r.MemoryTotalGB), r.GPUCount,
+		gb(r.StorageUsedGB), gb(r.StorageTotalGB))
+	if r.DriverVersion != nil {
+		s += " driver=" + *r.DriverVersion
+	}
+	if r.CUDAVersion != nil {
+		s += " cuda=" + *r.CUDAVersion
+	}
+	return s
+}
+
+func clamp(v, lo, hi int) int {
+	return min(max(v, lo), hi)
+}
+
+// clampGB drops negative sizes and caps absurd ones.
+func clampGB(v *int) *int {
+	if v == nil || *v < 0 {
+		return nil
+	}
+	c := min(*v, maxSizeGB)
+	return &c
+}
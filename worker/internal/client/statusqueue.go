@@ -0,0 +1,240 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// statusQueueRetryInterval is how often delivery of queued job statuses is retried.
+const statusQueueRetryInterval = 15 * time.Second
+
+// queuedStatus is one line of the status queue file: a final job status
+// waiting for delivery, or a marker that it was delivered.
+type queuedStatus struct {
+	JobID    int              `json:"job_id"`
+	Update   *JobStatusUpdate `json:"update,omitempty"`
+	QueuedAt time.Time        `json:"queued_at"`
+	Sent     bool             `json:"sent,omitempty"`
+}
+
+// statusQueue keeps final job statuses the master couldn't be told about in
+// an append-only file, so they survive outages and agent restarts. A job has
+// at most one queued status; a newer one replaces it in place.
+type statusQueue struct {
+	path string
+	wake chan struct{}
+
+	mu      sync.Mutex
+	pending []queuedStatus // oldest first
+}
+
+// newStatusQueue loads the undelivered statuses stored at path, if any.
+func newStatusQueue(path string) *statusQueue {
+	return &statusQueue{
+		path:    path,
+		wake:    make(chan struct{}, 1),
+		pending: loadStatusQueue(path),
+	}
+}
+
+// loadStatusQueue replays the queue file into the statuses not yet delivered.
+func loadStatusQueue(path string) []queuedStatus {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var pending []queuedStatus
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // Error messages can be long
+	for scanner.Scan() {
+		var entry queuedStatus
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // A line torn by a crash mid-write
+		}
+		i := slices.IndexFunc(pending, func(p queuedStatus) bool { return p.JobID == entry.JobID })
+		switch {
+		case entry.Sent:
+			if i >= 0 && pending[i].QueuedAt.Equal(entry.QueuedAt) {
+				pending = slices.Delete(pending, i, i+1)
+			}
+		case entry.Update == nil:
+		case i >= 0:
+			pending[i] = entry
+		default:
+			pending = append(pending, entry)
+		}
+	}
+	return pending
+}
+
+// Len returns the number of statuses waiting for delivery.
+func (q *statusQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// push queues a job's status, replacing any already queued for the job.
+func (q *statusQueue) push(jobID int, update JobStatusUpdate) error {
+	entry := queuedStatus{JobID: jobID, Update: &update, QueuedAt: time.Now().Round(0)}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.append(entry); err != nil {
+		return err
+	}
+	if i := slices.IndexFunc(q.pending, func(p queuedStatus) bool { return p.JobID == jobID }); i >= 0 {
+		q.pending[i] = entry
+	} else {
+		q.pending = append(q.pending, entry)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// next returns the oldest queued status.
+func (q *statusQueue) next() (queuedStatus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return queuedStatus{}, false
+	}
+	return q.pending[0], true
+}
+
+// done drops a status once it was delivered or rejected, unless a newer one
+// for the same job was queued meanwhile. The file is removed once empty.
+func (q *statusQueue) done(entry queuedStatus) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := slices.IndexFunc(q.pending, func(p queuedStatus) bool { return p.JobID == entry.JobID })
+	if i < 0 || !q.pending[i].QueuedAt.Equal(entry.QueuedAt) {
+		return
+	}
+	q.pending = slices.Delete(q.pending, i, i+1)
+
+	if len(q.pending) == 0 {
+		if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove job status queue", "path", q.path, "error", err)
+		}
+		return
+	}
+	if err := q.append(queuedStatus{JobID: entry.JobID, QueuedAt: entry.QueuedAt, Sent: true}); err != nil {
+		slog.Warn("Failed to write job status queue", "path", q.path, "error", err)
+	}
+}
+
+// append writes a line to the queue file; the caller holds q.mu.
+func (q *statusQueue) append(entry queuedStatus) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(q.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// finalStatus reports whether a job status ends the job.
+func finalStatus(status string) bool {
+	switch status {
+	case "completed", "failed", "timeout", "cancelled":
+		return true
+	default:
+		return false
+	}
+}
+
+// unreachable reports whether a request failed because the master is down,
+// rather than because it refused the request.
+func unreachable(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || masterDown(err)
+}
+
+// queueJobStatus stores a final status for RunStatusQueue to deliver.
+func (c *MasterClient) queueJobStatus(jobID int, update JobStatusUpdate, cause error) error {
+	if err := c.statuses.push(jobID, update); err != nil {
+		return fmt.Errorf("%w (and failed to queue it: %v)", cause, err)
+	}
+	slog.Warn("Job status queued until the master is reachable",
+		"job_id", jobID, "status", update.Status, "queued", c.statuses.Len(), "error", cause)
+	return nil
+}
+
+// RunStatusQueue delivers queued job statuses, oldest first, until ctx is
+// done. Delivery stops at the first status the master can't be reached for
+// and is retried periodically or when another status is queued.
+func (c *MasterClient) RunStatusQueue(ctx context.Context) {
+	if n := c.statuses.Len(); n > 0 {
+		slog.Info("Delivering job statuses queued during a master outage", "count", n)
+	}
+
+	ticker := time.NewTicker(statusQueueRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		c.flushStatusQueue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.statuses.wake:
+		}
+	}
+}
+
+// flushStatusQueue sends queued statuses until the queue is empty or the
+// master can't be reached. Statuses the master refuses are dropped.
+func (c *MasterClient) flushStatusQueue(ctx context.Context) {
+	for {
+		entry, ok := c.statuses.next()
+		if !ok {
+			return
+		}
+
+		url := fmt.Sprintf("/api/v1/jobs/%d/status", entry.JobID)
+		err := c.send(ctx, "POST", url, entry.Update, nil, true, false)
+		switch {
+		case ctx.Err() != nil || unreachable(err):
+			return
+		case err != nil:
+			slog.Error("Master refused queued job status, dropping it",
+				"job_id", entry.JobID, "status", entry.Update.Status, "error", err)
+		default:
+			slog.Info("Delivered queued job status",
+				"job_id", entry.JobID, "status", entry.Update.Status, "queued_for", time.Since(entry.QueuedAt).Round(time.Second).String())
+		}
+		c.statuses.done(entry)
+	}
+}
@@ -2,6 +2,10 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +18,21 @@ type Config struct {
 	// Master node connection
 	MasterURL string `env:"AGENT_MASTER_URL" envDefault:"http://localhost:8000"`
 
+	// Registration retries back off exponentially from 2s up to the cap (in seconds)
+	RegisterMaxAttempts int `env:"AGENT_REGISTER_MAX_ATTEMPTS" envDefault:"5"`
+	RegisterBackoffMax  int `env:"AGENT_REGISTER_BACKOFF_MAX" envDefault:"60"`
+
+	// Requests that are safe to repeat are retried on connection errors and
+	// 5xx/429 responses, backing off from 1s up to the cap (in seconds)
+	RequestMaxAttempts int `env:"AGENT_REQUEST_MAX_ATTEMPTS" envDefault:"6"`
+	RequestBackoffMax  int `env:"AGENT_REQUEST_BACKOFF_MAX" envDefault:"30"`
+
+	// After this many consecutive failed requests the master is treated as down:
+	// requests fail without being sent for the cooldown (in seconds), then one is
+	// let through to probe (0 disables)
+	MasterBreakerThreshold int `env:"AGENT_MASTER_BREAKER_THRESHOLD" envDefault:"5"`
+	MasterBreakerCooldown  int `env:"AGENT_MASTER_BREAKER_COOLDOWN" envDefault:"30"`
+
 	// Node identification
 	NodeName     string `env:"AGENT_NODE_NAME" envDefault:"worker-001"`
 	NodeHostname string `env:"AGENT_NODE_HOSTNAME"`
@@ -23,13 +42,189 @@ type Config struct {
 	JobPollInterval     int `env:"AGENT_JOB_POLL_INTERVAL" envDefault:"10"`
 	DatasetScanInterval int `env:"AGENT_DATASET_SCAN_INTERVAL" envDefault:"300"`
 
+	// Report only added, changed and removed datasets after the first scan, with
+	// a full report every full sync interval (in seconds). Falls back to full
+	// reports when the master has no delta endpoint.
+	DatasetDeltaReports     bool `env:"AGENT_DATASET_DELTA_REPORTS" envDefault:"true"`
+	DatasetFullSyncInterval int  `env:"AGENT_DATASET_FULL_SYNC_INTERVAL" envDefault:"3600"`
+
+	// New datasets with files modified within this window (in seconds) are treated
+	// as still being copied and not reported until they settle; known datasets
+	// keep their last report meanwhile (0 disables)
+	DatasetSettleSeconds int `env:"AGENT_DATASET_SETTLE_SECONDS" envDefault:"0"`
+
+	// Directory levels below each dataset path that may hold separate datasets,
+	// e.g. 2 for datasets/vision/imagenet (1 treats each top-level directory as one)
+	DatasetScanMaxDepth int `env:"AGENT_DATASET_SCAN_MAX_DEPTH" envDefault:"1"`
+
+	// Top-level dataset directories walked at once (0 uses GOMAXPROCS)
+	DatasetScanParallelism int `env:"AGENT_DATASET_SCAN_PARALLELISM" envDefault:"0"`
+
+	// Dataset checksums cover each file's path, size and mtime; content checksums
+	// hash every byte instead, which rereads the whole dataset on each walk
+	DatasetContentChecksum bool `env:"AGENT_DATASET_CONTENT_CHECKSUM" envDefault:"false"`
+
+	// Reuse a dataset's last scan while its directory's mtime is unchanged; files
+	// changed deeper down are picked up by the full rescan every interval (in
+	// seconds, 0 never). Disable the cache to walk every dataset on every scan.
+	DatasetScanCache          bool `env:"AGENT_DATASET_SCAN_CACHE" envDefault:"true"`
+	DatasetFullRescanInterval int  `env:"AGENT_DATASET_FULL_RESCAN_INTERVAL" envDefault:"86400"`
+
+	// Extra dataset formats as a JSON object of extension to label, merged over
+	// the built-in ones, e.g. {".zarr": "zarr", ".gif": ""} (an empty label drops one)
+	DatasetFormatMap string `env:"AGENT_DATASET_FORMAT_MAP"`
+
+	// Report dataset local_path relative to its AGENT_DATASETS_PATHS entry (absolute_path is always sent)
+	DatasetRelativePaths bool `env:"AGENT_DATASET_RELATIVE_PATHS" envDefault:"false"`
+
 	// Paths
 	StoragePath   string `env:"AGENT_STORAGE_PATH" envDefault:"/data"`
-	DatasetsPath  string `env:"AGENT_DATASETS_PATH" envDefault:"/data/datasets"`
 	ProjectsPath  string `env:"AGENT_PROJECTS_PATH" envDefault:"/data/projects"`
 	JobsWorkspace string `env:"AGENT_JOBS_WORKSPACE" envDefault:"/data/jobs"`
 	LogPath       string `env:"AGENT_LOG_PATH" envDefault:"/var/log/ml-agent"`
 
+	// Agent log output: level (debug, info, warn, error) and format (json, text)
+	LogLevel  string `env:"AGENT_LOG_LEVEL" envDefault:"info"`
+	LogFormat string `env:"AGENT_LOG_FORMAT" envDefault:"json"`
+
+	// Dataset roots, comma-separated for datasets spread across several mounts
+	// (AGENT_DATASETS_PATH, the older name, is still read)
+	DatasetsPaths []string `env:"AGENT_DATASETS_PATHS" envDefault:"/data/datasets"`
+
+	// Job timeouts (in seconds). With adaptive timeouts enabled, jobs without an
+	// explicit timeout get a multiple of their historical median runtime.
+	JobDefaultTimeout         int     `env:"AGENT_JOB_DEFAULT_TIMEOUT" envDefault:"3600"`
+	AdaptiveTimeout           bool    `env:"AGENT_ADAPTIVE_TIMEOUT" envDefault:"false"`
+	AdaptiveTimeoutMultiplier float64 `env:"AGENT_ADAPTIVE_TIMEOUT_MULTIPLIER" envDefault:"2"`
+	AdaptiveTimeoutMin        int     `env:"AGENT_ADAPTIVE_TIMEOUT_MIN" envDefault:"300"`
+	AdaptiveTimeoutMax        int     `env:"AGENT_ADAPTIVE_TIMEOUT_MAX" envDefault:"86400"`
+
+	// Environment variables injected into every job, e.g. "HTTP_PROXY=http://proxy:3128,HF_HOME=/data/hf".
+	// Per-job environment variables take precedence.
+	JobGlobalEnv map[string]string `env:"AGENT_JOB_GLOBAL_ENV" envKeyValSeparator:"="`
+
+	// Directory of secrets for job variables set to "secret://<name>", one file
+	// per secret named by it and readable by the agent's user only (0600)
+	SecretsDir string `env:"AGENT_SECRETS_DIR"`
+
+	// Time limit (in seconds) for each command resolving a job's dynamic_env variable
+	JobDynamicEnvTimeout int `env:"AGENT_JOB_DYNAMIC_ENV_TIMEOUT" envDefault:"30"`
+
+	// Allow dynamic_env for docker and apptainer jobs. Its commands run on the
+	// host as the agent's user, not inside the job's container.
+	JobDynamicEnvContainers bool `env:"AGENT_JOB_DYNAMIC_ENV_CONTAINERS" envDefault:"false"`
+
+	// Shells used to run job commands; conda and venv need one that supports "source"
+	JobShellSystem string `env:"AGENT_JOB_SHELL_SYSTEM" envDefault:"sh"`
+	JobShellConda  string `env:"AGENT_JOB_SHELL_CONDA" envDefault:"bash"`
+	JobShellVenv   string `env:"AGENT_JOB_SHELL_VENV" envDefault:"bash"`
+
+	// PATH for jobs: AGENT_JOB_PATH replaces the agent's PATH, AGENT_JOB_PATH_PREFIX is prepended
+	JobPath       string `env:"AGENT_JOB_PATH"`
+	JobPathPrefix string `env:"AGENT_JOB_PATH_PREFIX"`
+
+	// Number of jobs run at the same time
+	MaxConcurrentJobs int `env:"AGENT_MAX_CONCURRENT_JOBS" envDefault:"1"`
+
+	// On SIGTERM, how long running jobs may finish on their own before they
+	// are cancelled (in seconds, 0 cancels them right away)
+	ShutdownDrainSeconds int `env:"AGENT_SHUTDOWN_DRAIN_SECONDS" envDefault:"0"`
+
+	// Minimum delay between job starts (in milliseconds, 0 disables)
+	JobStartStaggerMS int `env:"AGENT_JOB_START_STAGGER_MS" envDefault:"0"`
+
+	// Jobs listing EnvConfig["depends_on"] wait for those jobs to finish on this
+	// node, up to the timeout (seconds); a failed dependency fails the job unless fail-fast is off
+	JobDependencyTimeout  int  `env:"AGENT_JOB_DEPENDENCY_TIMEOUT" envDefault:"3600"`
+	JobDependencyFailFast bool `env:"AGENT_JOB_DEPENDENCY_FAIL_FAST" envDefault:"true"`
+
+	// How often defunct child processes are reaped (in seconds, 0 disables)
+	ZombieReapInterval int `env:"AGENT_ZOMBIE_REAP_INTERVAL" envDefault:"60"`
+
+	// Docker network for jobs without EnvConfig["network_mode"], and the modes
+	// jobs may request (none, host, bridge or a custom network name)
+	DockerDefaultNetwork  string   `env:"AGENT_DOCKER_DEFAULT_NETWORK" envDefault:"bridge"`
+	DockerAllowedNetworks []string `env:"AGENT_DOCKER_ALLOWED_NETWORKS" envDefault:"none,bridge"`
+
+	// Time allowed for pulling a Docker job's image, apart from the job's own
+	// timeout (in seconds, 0 for no limit)
+	DockerPullTimeout int `env:"AGENT_DOCKER_PULL_TIMEOUT" envDefault:"1800"`
+
+	// Refuse Docker jobs whose image isn't pinned by digest (repo@sha256:...)
+	RequireImageDigest bool `env:"AGENT_REQUIRE_IMAGE_DIGEST" envDefault:"false"`
+
+	// Recent output kept in memory per running job (in KB)
+	JobLogBufferKB int `env:"AGENT_JOB_LOG_BUFFER_KB" envDefault:"256"`
+
+	// Largest total size of a job's artifacts (EnvConfig["artifacts"]); files
+	// beyond it are left out of the upload (in MB, 0 for no limit)
+	ArtifactMaxMB int `env:"AGENT_ARTIFACT_MAX_MB" envDefault:"1024"`
+
+	// Finished job logs are stored gzipped and deleted after this many days (0 keeps them)
+	JobLogRetentionDays int `env:"AGENT_JOB_LOG_RETENTION_DAYS" envDefault:"30"`
+
+	// Output rate guard: jobs printing more than the limit (KB/s, 0 disables)
+	// for the window (seconds) get throttled, or killed if configured
+	JobOutputRateLimitKB int  `env:"AGENT_JOB_OUTPUT_RATE_LIMIT_KB" envDefault:"0"`
+	JobOutputRateWindow  int  `env:"AGENT_JOB_OUTPUT_RATE_WINDOW" envDefault:"10"`
+	JobOutputKillRunaway bool `env:"AGENT_JOB_OUTPUT_KILL_RUNAWAY" envDefault:"false"`
+
+	// Where job output goes besides the job log, comma-separated:
+	// master, file, stdout, discard, s3
+	JobOutputSinks []string `env:"AGENT_JOB_OUTPUT_SINKS" envDefault:"master"`
+	JobOutputDir   string   `env:"AGENT_JOB_OUTPUT_DIR"` // file sink, default <log path>/output
+
+	// The master sink sends job output every flush interval (in seconds), or
+	// sooner once this many lines are waiting
+	JobLogFlushInterval int `env:"AGENT_JOB_LOG_FLUSH_INTERVAL" envDefault:"2"`
+	JobLogFlushLines    int `env:"AGENT_JOB_LOG_FLUSH_LINES" envDefault:"200"`
+
+	// S3-compatible object store for the s3 sink
+	JobOutputS3Endpoint  string `env:"AGENT_JOB_OUTPUT_S3_ENDPOINT"` // e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000
+	JobOutputS3Bucket    string `env:"AGENT_JOB_OUTPUT_S3_BUCKET"`
+	JobOutputS3Region    string `env:"AGENT_JOB_OUTPUT_S3_REGION" envDefault:"us-east-1"`
+	JobOutputS3AccessKey string `env:"AGENT_JOB_OUTPUT_S3_ACCESS_KEY"`
+	JobOutputS3SecretKey string `env:"AGENT_JOB_OUTPUT_S3_SECRET_KEY"`
+	JobOutputS3Prefix    string `env:"AGENT_JOB_OUTPUT_S3_PREFIX"`
+
+	// Environment cache (unpacked conda-pack archives)
+	EnvCacheDir   string `env:"AGENT_ENV_CACHE_DIR" envDefault:"/data/.env-cache"`
+	EnvCacheMaxGB int    `env:"AGENT_ENV_CACHE_MAX_GB" envDefault:"50"`
+
+	// Per-host git credentials (JSON, mode 0600) served to git through the
+	// agent's credential helper, keeping secrets out of URLs and argv
+	GitCredentialsFile string `env:"AGENT_GIT_CREDENTIALS_FILE"`
+
+	// Git operation timeouts (in seconds), independent of the API server timeouts
+	GitCloneTimeout  int `env:"AGENT_GIT_CLONE_TIMEOUT" envDefault:"600"`
+	GitPullTimeout   int `env:"AGENT_GIT_PULL_TIMEOUT" envDefault:"300"`
+	GitVerifyTimeout int `env:"AGENT_GIT_VERIFY_TIMEOUT" envDefault:"15"`
+
+	// How long (in seconds) a project's git status is reused while its HEAD is unchanged (0 disables)
+	GitStatusCacheTTL int `env:"AGENT_GIT_STATUS_CACHE_TTL" envDefault:"5"`
+
+	// Deleted projects are moved here (same filesystem as the projects path) and
+	// purged after the retention (in hours); empty deletes immediately
+	ProjectTrashDir       string `env:"AGENT_PROJECT_TRASH_DIR"`
+	ProjectTrashRetention int    `env:"AGENT_PROJECT_TRASH_RETENTION" envDefault:"168"`
+
+	// Report deleted projects back to the master so its record follows the disk
+	ProjectDeleteCallback bool `env:"AGENT_PROJECT_DELETE_CALLBACK" envDefault:"true"`
+
+	// Largest project directory that can be exported as a tarball (in MB, 0 for no limit)
+	ProjectExportMaxMB int `env:"AGENT_PROJECT_EXPORT_MAX_MB" envDefault:"0"`
+
+	// Largest file that can be uploaded into a project directory (in MB, 0 for no limit)
+	ProjectUploadMaxMB int `env:"AGENT_PROJECT_UPLOAD_MAX_MB" envDefault:"100"`
+
+	// Clones and uploads are refused while the projects volume has less free
+	// space than this (in GB, 0 disables)
+	MinFreeDiskGB int `env:"AGENT_MIN_FREE_DISK_GB" envDefault:"5"`
+
+	// Branches that may be cloned or pulled, as glob patterns (e.g. "main,release/*,v*").
+	// Empty allows any branch.
+	GitAllowedBranches []string `env:"AGENT_GIT_ALLOWED_BRANCHES"`
+
 	// Token management
 	AgentToken string `env:"AGENT_TOKEN"`
 	TokenFile  string `env:"AGENT_TOKEN_FILE" envDefault:"/etc/ml-agent/token"`
@@ -37,14 +232,56 @@ type Config struct {
 	// API server
 	APIPort int `env:"AGENT_API_PORT" envDefault:"8081"`
 
+	// Serve the API over HTTPS when both are set
+	APITLSCertFile string `env:"AGENT_API_TLS_CERT_FILE"`
+	APITLSKeyFile  string `env:"AGENT_API_TLS_KEY_FILE"`
+
+	// TLS policy for both the API server and the master connection
+	TLSMinVersion   string   `env:"AGENT_TLS_MIN_VERSION" envDefault:"1.2"`
+	TLSCipherSuites []string `env:"AGENT_TLS_CIPHER_SUITES"` // Go cipher suite names, empty means Go defaults
+
+	// Verify an HTTPS master against this CA bundle (PEM) instead of the
+	// system roots; skipping verification is for testing only
+	MasterCAFile             string `env:"AGENT_MASTER_CA_FILE"`
+	MasterInsecureSkipVerify bool   `env:"AGENT_MASTER_INSECURE_SKIP_VERIFY" envDefault:"false"`
+
+	// Audit log of authenticated API calls (JSON lines), empty disables auditing
+	AuditLogFile string `env:"AGENT_AUDIT_LOG_FILE"`
+
+	// Maximum number of file checksums computed at once
+	ChecksumConcurrency int `env:"AGENT_CHECKSUM_CONCURRENCY" envDefault:"2"`
+
+	// Command printing {"gpu_count": N, "gpu_info": "..."} for accelerators
+	// without built-in detection (tried before nvidia-smi and rocm-smi)
+	GPUDetectCommand string `env:"AGENT_GPU_DETECT_COMMAND"`
+
+	// Push metrics to a collector: statsd://host:8125 or otlp://host:4318 (otlps:// for https)
+	MetricsSink         string `env:"AGENT_METRICS_SINK"`
+	MetricsPushInterval int    `env:"AGENT_METRICS_PUSH_INTERVAL" envDefault:"15"`
+
+	// Storage mounts (storage and dataset paths) are probed concurrently; a
+	// mount slower than the timeout (in seconds) is reported unavailable
+	DiskProbeTimeout     int `env:"AGENT_DISK_PROBE_TIMEOUT" envDefault:"5"`
+	DiskProbeConcurrency int `env:"AGENT_DISK_PROBE_CONCURRENCY" envDefault:"4"`
+
+	// Health self-checks (node reports unhealthy and stops taking jobs on failure)
+	HealthCheckGPU     bool `env:"AGENT_HEALTH_CHECK_GPU" envDefault:"true"`
+	HealthCheckStorage bool `env:"AGENT_HEALTH_CHECK_STORAGE" envDefault:"true"`
+
 	// Development mode
 	DevMode bool `env:"AGENT_DEV_MODE" envDefault:"false"`
 }
 
-// Load loads configuration from environment variables.
+// Load loads configuration from environment variables and, when
+// AGENT_CONFIG_FILE is set, the settings file it names.
 func Load() (*Config, error) {
+	vars, err := environment()
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := &Config{}
-	if err := env.Parse(cfg); err != nil {
+	if err := env.ParseWithOptions(cfg, env.Options{Environment: vars}); err != nil {
 		return nil, err
 	}
 
@@ -59,26 +296,70 @@ func Load() (*Config, error) {
 		}
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
+// DatasetFormats parses AGENT_DATASET_FORMAT_MAP; it is nil when unset.
+func (c *Config) DatasetFormats() (map[string]string, error) {
+	if strings.TrimSpace(c.DatasetFormatMap) == "" {
+		return nil, nil
+	}
+	var formats map[string]string
+	if err := json.Unmarshal([]byte(c.DatasetFormatMap), &formats); err != nil {
+		return nil, fmt.Errorf("invalid AGENT_DATASET_FORMAT_MAP: %w", err)
+	}
+	return formats, nil
+}
+
+// ErrInvalidToken is returned when the token file exists but its contents are
+// empty or malformed, e.g. after a crash during a non-atomic write.
+var ErrInvalidToken = errors.New("token file is empty or malformed")
+
 // LoadToken loads the agent token from file or environment.
-func (c *Config) LoadToken() string {
+// A missing token file yields an empty token and no error.
+func (c *Config) LoadToken() (string, error) {
 	// First check environment variable
 	if c.AgentToken != "" {
-		return c.AgentToken
+		return c.AgentToken, nil
 	}
 
 	// Then check token file
 	data, err := os.ReadFile(c.TokenFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
 	if err != nil {
-		return ""
+		return "", err
 	}
 
-	return strings.TrimSpace(string(data))
+	token := strings.TrimSpace(string(data))
+	if !validToken(token) {
+		return "", fmt.Errorf("%s: %w", c.TokenFile, ErrInvalidToken)
+	}
+
+	return token, nil
+}
+
+// validToken reports whether a token is non-empty printable ASCII without spaces.
+func validToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, r := range token {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
 }
 
 // SaveToken saves the agent token to file.
+// The token is written to a temp file, synced and renamed into place so a
+// crash never leaves a truncated token file behind.
 func (c *Config) SaveToken(token string) error {
 	// Create directory if not exists
 	dir := filepath.Dir(c.TokenFile)
@@ -87,9 +368,37 @@ func (c *Config) SaveToken(token string) error {
 	}
 
 	// Write token with restricted permissions
-	if err := os.WriteFile(c.TokenFile, []byte(token), 0600); err != nil {
+	tmp, err := os.CreateTemp(dir, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
 		return err
 	}
+	if _, err := tmp.WriteString(token); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), c.TokenFile); err != nil {
+		return err
+	}
+
+	// Persist the rename itself
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
 
 	return nil
 }
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/caarlos0/env/v11"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileEnv names an optional YAML or JSON settings file. Environment
+// variables override its values, which override the built-in defaults.
+const configFileEnv = "AGENT_CONFIG_FILE"
+
+// legacyNames maps renamed variables to their current names. The current
+// name wins when both are set.
+var legacyNames = map[string]string{
+	"AGENT_DATASETS_PATH": "AGENT_DATASETS_PATHS",
+}
+
+// jsonSettings are string settings that hold a JSON object, so an object
+// given for them in the settings file is encoded as JSON.
+var jsonSettings = map[string]bool{
+	"AGENT_DATASET_FORMAT_MAP": true,
+}
+
+// renameLegacy moves values set under legacy names to their current names.
+func renameLegacy(vars map[string]string) {
+	for old, name := range legacyNames {
+		if value, ok := vars[old]; ok {
+			if _, set := vars[name]; !set {
+				vars[name] = value
+			}
+			delete(vars, old)
+		}
+	}
+}
+
+// environment returns the values to parse the configuration from: the
+// settings file, if any, overlaid with the process environment.
+func environment() (map[string]string, error) {
+	vars := env.ToMap(os.Environ())
+	renameLegacy(vars)
+
+	path := vars[configFileEnv]
+	if path == "" {
+		return vars, nil
+	}
+
+	values, err := readConfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configFileEnv, err)
+	}
+	for key, value := range vars {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// readConfigFile loads a settings file as environment values. Keys are the
+// variable names, with or without the AGENT_ prefix and in any case (e.g.
+// master_url); lists are joined with commas. Objects are written as k=v,k2=v2
+// for map settings and as JSON for the settings in jsonSettings; other
+// settings don't take objects. Unknown keys are rejected so typos don't go
+// unnoticed.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML, so one decoder reads both
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	params, err := env.GetFieldParams(&Config{})
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(params))
+	for _, p := range params {
+		known[p.Key] = true
+	}
+	maps := mapSettings()
+
+	values := make(map[string]string, len(raw))
+	var unknown []string
+	for key, value := range raw {
+		name := strings.ToUpper(key)
+		if !strings.HasPrefix(name, "AGENT_") {
+			name = "AGENT_" + name
+		}
+		if !known[name] && legacyNames[name] == "" {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		var s string
+		if obj, ok := value.(map[string]any); ok {
+			s, err = objectValue(name, obj, maps)
+		} else {
+			s, err = fileValue(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+		values[name] = s
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%s: unknown settings: %s", path, strings.Join(unknown, ", "))
+	}
+
+	renameLegacy(values)
+	return values, nil
+}
+
+// fileValue formats a settings file value the way it would be written in
+// an environment variable.
+func fileValue(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		return "", fmt.Errorf("nested objects are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// mapSeparators are how a map setting's entries are written in its variable.
+type mapSeparators struct {
+	entry, keyVal string
+}
+
+// mapSettings returns the map-typed settings by variable name.
+func mapSettings() map[string]mapSeparators {
+	settings := make(map[string]mapSeparators)
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+		if field.Type.Kind() != reflect.Map || name == "" {
+			continue
+		}
+		seps := mapSeparators{entry: ",", keyVal: ":"} // caarlos0/env defaults
+		if sep, ok := field.Tag.Lookup("envSeparator"); ok {
+			seps.entry = sep
+		}
+		if sep, ok := field.Tag.Lookup("envKeyValSeparator"); ok {
+			seps.keyVal = sep
+		}
+		settings[name] = seps
+	}
+	return settings
+}
+
+// objectValue formats a settings file object for the setting name.
+func objectValue(name string, obj map[string]any, maps map[string]mapSeparators) (string, error) {
+	if jsonSettings[name] {
+		data, err := json.Marshal(obj)
+		return string(data), err
+	}
+
+	seps, ok := maps[name]
+	if !ok {
+		return "", fmt.Errorf("expected a single value or a list, got an object")
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, len(keys))
+	for i, k := range keys {
+		if _, nested := obj[k].(map[string]any); nested {
+			return "", fmt.Errorf("%s: nested objects are not supported", k)
+		}
+		if _, list := obj[k].([]any); list {
+			return "", fmt.Errorf("%s: lists are not supported as values", k)
+		}
+		v, err := fileValue(obj[k])
+		if err != nil {
+			return "", err
+		}
+		// The variable format has no escaping
+		if strings.Contains(k, seps.entry) || strings.Contains(k, seps.keyVal) {
+			return "", fmt.Errorf("key %q can't contain %q or %q", k, seps.entry, seps.keyVal)
+		}
+		if strings.Contains(v, seps.entry) {
+			return "", fmt.Errorf("%s: value can't contain %q", k, seps.entry)
+		}
+		entries[i] = k + seps.keyVal + v
+	}
+	return strings.Join(entries, seps.entry), nil
+}
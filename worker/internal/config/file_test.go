@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSettings(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfigFileObjects(t *testing.T) {
+	path := writeSettings(t, `
+job_global_env:
+  HTTP_PROXY: http://proxy:3128
+  PIP_INDEX_URL: https://pypi.example.com/simple?a=b
+dataset_format_map: {".zarr": zarr}
+`)
+	values, err := readConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"AGENT_JOB_GLOBAL_ENV":     "HTTP_PROXY=http://proxy:3128,PIP_INDEX_URL=https://pypi.example.com/simple?a=b",
+		"AGENT_DATASET_FORMAT_MAP": `{".zarr":"zarr"}`,
+	}
+	for name, v := range want {
+		if values[name] != v {
+			t.Errorf("%s = %q, want %q", name, values[name], v)
+		}
+	}
+
+	t.Setenv(configFileEnv, path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load with a job_global_env object: %v", err)
+	}
+	if got := cfg.JobGlobalEnv["HTTP_PROXY"]; got != "http://proxy:3128" {
+		t.Errorf("JobGlobalEnv[HTTP_PROXY] = %q", got)
+	}
+}
+
+func TestReadConfigFileRejectsObjects(t *testing.T) {
+	tests := map[string]string{
+		"scalar setting":      "master_url: {host: master}",
+		"separator in value":  "job_global_env: {NO_PROXY: 'localhost,127.0.0.1'}",
+		"separator in key":    "job_global_env: {'A=B': c}",
+		"nested object value": "job_global_env: {A: {b: c}}",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := readConfigFile(writeSettings(t, content))
+			if err == nil {
+				t.Fatal("object accepted")
+			}
+			if !strings.Contains(err.Error(), "agent.yaml") {
+				t.Errorf("error %q doesn't name the file", err)
+			}
+		})
+	}
+}
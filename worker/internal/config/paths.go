@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VolumePaths returns the paths whose mounts are reported besides the
+// storage path: the datasets paths, then the jobs and projects paths.
+func (c *Config) VolumePaths() []string {
+	paths := append([]string(nil), c.DatasetsPaths...)
+	return append(paths, c.JobsWorkspace, c.ProjectsPath)
+}
+
+// ValidatePaths checks that the datasets, jobs and projects paths exist (or can
+// be created), are writable and don't nest inside each other. All problems are
+// reported together.
+func (c *Config) ValidatePaths() error {
+	type namedPath struct {
+		name string
+		path string
+	}
+	var paths []namedPath
+	for _, p := range c.DatasetsPaths {
+		paths = append(paths, namedPath{"AGENT_DATASETS_PATHS", p})
+	}
+	paths = append(paths,
+		namedPath{"AGENT_JOBS_WORKSPACE", c.JobsWorkspace},
+		namedPath{"AGENT_PROJECTS_PATH", c.ProjectsPath},
+	)
+
+	var problems []error
+	abs := make([]string, len(paths))
+
+	for i, p := range paths {
+		path, err := filepath.Abs(p.path)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: invalid path %q: %w", p.name, p.path, err))
+			continue
+		}
+		abs[i] = path
+
+		if err := CheckWritableDir(path); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", p.name, err))
+		}
+	}
+
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if abs[i] == "" || abs[j] == "" {
+				continue
+			}
+			if nested(abs[i], abs[j]) {
+				problems = append(problems, fmt.Errorf("%s (%s) and %s (%s) must not overlap",
+					paths[i].name, abs[i], paths[j].name, abs[j]))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid path configuration:\n%w", errors.Join(problems...))
+	}
+	return nil
+}
+
+// CheckWritableDir creates path if needed and verifies a file can be written in it.
+func CheckWritableDir(path string) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", path, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	f, err := os.CreateTemp(path, ".agent-write-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", path, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return nil
+}
+
+// nested reports whether a and b are the same path or one contains the other.
+func nested(a, b string) bool {
+	if a == b {
+		return true
+	}
+	sep := string(os.PathSeparator)
+	return strings.HasPrefix(a, b+sep) || strings.HasPrefix(b, a+sep)
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable are the settings a running agent applies when it reloads its
+// configuration; everything else needs a restart.
+var reloadable = map[string]bool{
+	"HeartbeatInterval":   true,
+	"JobPollInterval":     true,
+	"DatasetScanInterval": true,
+}
+
+// Changes compares c with a freshly loaded configuration and returns the
+// environment variable names of the settings that differ, split into those
+// applied on reload and those ignored until a restart.
+func (c *Config) Changes(next *Config) (applied, ignored []string) {
+	cur, nxt := reflect.ValueOf(c).Elem(), reflect.ValueOf(next).Elem()
+	t := cur.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if reflect.DeepEqual(cur.Field(i).Interface(), nxt.Field(i).Interface()) {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if reloadable[field.Name] {
+			applied = append(applied, name)
+		} else {
+			ignored = append(ignored, name)
+		}
+	}
+
+	return applied, ignored
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+)
+
+// Validate checks that intervals are positive, the master URL is an http or
+// https URL, the storage paths are absolute and the API port is in range.
+// All problems are reported together.
+func (c *Config) Validate() error {
+	var problems []error
+
+	intervals := []struct {
+		name  string
+		value int
+	}{
+		{"AGENT_HEARTBEAT_INTERVAL", c.HeartbeatInterval},
+		{"AGENT_JOB_POLL_INTERVAL", c.JobPollInterval},
+		{"AGENT_DATASET_SCAN_INTERVAL", c.DatasetScanInterval},
+		{"AGENT_ZOMBIE_REAP_INTERVAL", c.ZombieReapInterval},
+		{"AGENT_METRICS_PUSH_INTERVAL", c.MetricsPushInterval},
+	}
+	for _, i := range intervals {
+		if i.value <= 0 {
+			problems = append(problems, fmt.Errorf("%s: must be positive, got %d", i.name, i.value))
+		}
+	}
+
+	if c.MasterBreakerThreshold > 0 && c.MasterBreakerCooldown <= 0 {
+		problems = append(problems, fmt.Errorf("AGENT_MASTER_BREAKER_COOLDOWN: must be positive, got %d", c.MasterBreakerCooldown))
+	}
+
+	if c.MasterURL == "" {
+		problems = append(problems, errors.New("AGENT_MASTER_URL: required"))
+	} else if u, err := url.Parse(c.MasterURL); err != nil {
+		problems = append(problems, fmt.Errorf("AGENT_MASTER_URL: %w", err))
+	} else if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		problems = append(problems, fmt.Errorf("AGENT_MASTER_URL: %q must be an http:// or https:// URL", c.MasterURL))
+	}
+
+	type namedPath struct {
+		name string
+		path string
+	}
+	paths := []namedPath{
+		{"AGENT_STORAGE_PATH", c.StoragePath},
+		{"AGENT_PROJECTS_PATH", c.ProjectsPath},
+		{"AGENT_JOBS_WORKSPACE", c.JobsWorkspace},
+		{"AGENT_LOG_PATH", c.LogPath},
+		{"AGENT_ENV_CACHE_DIR", c.EnvCacheDir},
+	}
+	for _, p := range c.DatasetsPaths {
+		paths = append(paths, namedPath{"AGENT_DATASETS_PATHS", p})
+	}
+	// Optional paths are only checked when set
+	for _, p := range []namedPath{
+		{"AGENT_SECRETS_DIR", c.SecretsDir},
+		{"AGENT_JOB_OUTPUT_DIR", c.JobOutputDir},
+		{"AGENT_PROJECT_TRASH_DIR", c.ProjectTrashDir},
+	} {
+		if p.path != "" {
+			paths = append(paths, p)
+		}
+	}
+	for _, p := range paths {
+		if !filepath.IsAbs(p.path) {
+			problems = append(problems, fmt.Errorf("%s: %q must be an absolute path", p.name, p.path))
+		}
+	}
+
+	if c.APIPort < 1 || c.APIPort > 65535 {
+		problems = append(problems, fmt.Errorf("AGENT_API_PORT: %d is not a valid port", c.APIPort))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+	}
+	return nil
+}
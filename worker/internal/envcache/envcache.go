@@ -0,0 +1,94 @@
+// Package envcache coordinates access to the shared environment and package
+// caches used by jobs.
+package envcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/syncutil"
+)
+
+// lockPollInterval is how often a blocked Lock retries the file lock.
+const lockPollInterval = 100 * time.Millisecond
+
+// CacheManager owns the lock discipline for a cache root. Mutations of a
+// cache entry (creating, updating or evicting it) must hold the entry's lock;
+// reading a complete entry needs no lock. Locks are flock(2) based, so they
+// also coordinate with other agent processes sharing the cache directory.
+type CacheManager struct {
+	root  string
+	locks syncutil.KeyedMutex // flock doesn't block other goroutines reusing a descriptor
+}
+
+// NewCacheManager creates a manager for the cache rooted at root.
+func NewCacheManager(root string) *CacheManager {
+	return &CacheManager{root: root}
+}
+
+// Path returns the location of a cache entry, e.g. Path("conda-pack", sha).
+func (m *CacheManager) Path(elem ...string) string {
+	return filepath.Join(append([]string{m.root}, elem...)...)
+}
+
+// lockPath returns the lock file for key, kept apart from the entries themselves.
+func (m *CacheManager) lockPath(key string) string {
+	name := strings.NewReplacer("/", "_", string(os.PathSeparator), "_").Replace(key)
+	return filepath.Join(m.root, ".locks", name+".lock")
+}
+
+// Lock takes the exclusive lock for key, waiting until it is free or ctx is
+// done. The returned function releases it.
+func (m *CacheManager) Lock(ctx context.Context, key string) (func(), error) {
+	for {
+		unlock, ok, err := m.TryLock(key)
+		if err != nil || ok {
+			return unlock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for cache lock %s: %w", key, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// TryLock takes the exclusive lock for key if it is free.
+func (m *CacheManager) TryLock(key string) (func(), bool, error) {
+	if !m.locks.TryLock(key) {
+		return nil, false, nil
+	}
+
+	path := m.lockPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		m.locks.Unlock(key)
+		return nil, false, fmt.Errorf("failed to create cache lock dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		m.locks.Unlock(key)
+		return nil, false, fmt.Errorf("failed to open cache lock: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		m.locks.Unlock(key)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to lock cache %s: %w", key, err)
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+		m.locks.Unlock(key)
+	}, true, nil
+}
@@ -0,0 +1,89 @@
+package envcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockSameKeyConcurrently(t *testing.T) {
+	m := NewCacheManager(t.TempDir())
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := m.Lock(context.Background(), "conda-pack/abc")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			holders++
+			maxHolders = max(maxHolders, holders)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Fatalf("%d goroutines held the same key at once", maxHolders)
+	}
+}
+
+// Two managers on one root stand in for two agent processes: only the file
+// lock keeps them apart.
+func TestLockAcrossManagers(t *testing.T) {
+	root := t.TempDir()
+	a, b := NewCacheManager(root), NewCacheManager(root)
+
+	unlock, ok, err := a.TryLock("conda-pack/abc")
+	if err != nil || !ok {
+		t.Fatalf("TryLock of a free key: ok=%v err=%v", ok, err)
+	}
+
+	if _, ok, err := b.TryLock("conda-pack/abc"); err != nil || ok {
+		t.Fatalf("TryLock of a key held by another manager: ok=%v err=%v", ok, err)
+	}
+	if unlockOther, ok, err := b.TryLock("conda-pack/def"); err != nil || !ok {
+		t.Fatalf("TryLock of a different key: ok=%v err=%v", ok, err)
+	} else {
+		unlockOther()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockPollInterval)
+	defer cancel()
+	if _, err := b.Lock(ctx, "conda-pack/abc"); err == nil {
+		t.Fatal("Lock of a held key returned before its context ended")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		unlock, err := b.Lock(context.Background(), "conda-pack/abc")
+		if err == nil {
+			unlock()
+		}
+		done <- err
+	}()
+	unlock()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lock did not return after the other manager released the key")
+	}
+}
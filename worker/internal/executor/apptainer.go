@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// runApptainer executes a job in an Apptainer (formerly Singularity)
+// container, for nodes that don't allow Docker. EnvConfig["image"] is a .sif
+// path or a URI apptainer can pull, such as docker://python:3.12.
+func (e *Executor) runApptainer(ctx context.Context, job client.Job, workDir string) JobResult {
+	timeout := e.jobTimeout(job)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	envConfig := job.EnvConfig
+	image, _ := envConfig["image"].(string)
+	if image == "" {
+		return JobResult{ExitCode: -1, ErrorMessage: "apptainer jobs need an image (a .sif path or docker:// URI)"}
+	}
+	if !strings.Contains(image, "://") {
+		if _, err := os.Stat(image); err != nil {
+			return JobResult{ExitCode: -1, ErrorMessage: fmt.Sprintf("apptainer image not found: %s", image)}
+		}
+	}
+
+	// Bind the working directory like the Docker runtime mounts it
+	args := []string{"exec", "--bind", fmt.Sprintf("%s:/workspace", workDir), "--pwd", "/workspace"}
+	if volumes, ok := envConfig["volumes"].([]any); ok {
+		for _, v := range volumes {
+			if vol, ok := v.(string); ok {
+				args = append(args, "--bind", vol)
+			}
+		}
+	}
+
+	// --nv exposes the host's NVIDIA devices; a reserved GPU is selected
+	// through CUDA_VISIBLE_DEVICES in the job's variables
+	_, reserved := e.jobGPU(job.ID)
+	if gpu, ok := envConfig["gpu"].(bool); reserved || (ok && gpu) {
+		args = append(args, "--nv")
+	}
+
+	args = append(args, image, "sh", "-c", job.Command)
+
+	// Job variables reach the container through APPTAINERENV_ prefixes
+	cmd := exec.CommandContext(ctx, "apptainer", args...)
+	cmd.Dir = workDir
+	cmd.Env = e.buildEnv(nil)
+	for k, v := range e.jobEnv(job.EnvironmentVars) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("APPTAINERENV_%s=%s", k, v))
+	}
+
+	e.mu.Lock()
+	e.runningJobs[job.ID] = cmd
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		delete(e.runningJobs, job.ID)
+		e.mu.Unlock()
+	}()
+
+	return e.runCommand(ctx, job, cmd)
+}
@@ -0,0 +1,152 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// artifactPatterns returns the glob patterns in EnvConfig["artifacts"],
+// either one string or a list, relative to the job's working directory.
+func artifactPatterns(job client.Job) []string {
+	switch v := job.EnvConfig["artifacts"].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []any:
+		var patterns []string
+		for _, p := range v {
+			if s, ok := p.(string); ok && s != "" {
+				patterns = append(patterns, s)
+			}
+		}
+		return patterns
+	}
+	return nil
+}
+
+// collectArtifacts lists the regular files under workDir matching any of the
+// patterns, as slash-separated relative paths. A file that would take the
+// total over maxBytes (0 for no limit) is skipped and counted in skipped.
+func collectArtifacts(ctx context.Context, workDir string, patterns []string, maxBytes int64) (files []string, total int64, skipped int, err error) {
+	for i, p := range patterns {
+		p = filepath.ToSlash(filepath.Clean(p))
+		if p == ".." || strings.HasPrefix(p, "../") || filepath.IsAbs(p) {
+			return nil, 0, 0, fmt.Errorf("artifact pattern %q is outside the working directory", patterns[i])
+		}
+		patterns[i] = strings.TrimPrefix(p, "./")
+	}
+
+	err = filepath.WalkDir(workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil // Symlinks aren't followed out of the working directory
+		}
+
+		rel, err := filepath.Rel(workDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !matchesAny(patterns, rel) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if maxBytes > 0 && total+info.Size() > maxBytes {
+			skipped++
+			return nil
+		}
+		files = append(files, rel)
+		total += info.Size()
+		return nil
+	})
+	return files, total, skipped, err
+}
+
+// matchesAny reports whether rel, or a directory containing it, matches one
+// of the patterns.
+func matchesAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if fileops.MatchGlob(p, rel) || fileops.MatchGlob(p+"/**", rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadArtifacts archives a successful job's artifacts and sends them to
+// the master ahead of its final status. Failures are logged; they don't fail
+// the job.
+func (e *Executor) uploadArtifacts(ctx context.Context, job client.Job, workDir string, log *jobLog) {
+	patterns := artifactPatterns(job)
+	if len(patterns) == 0 {
+		return
+	}
+	failed := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		slog.Warn("Artifact upload failed", "job_id", job.ID, "error", msg)
+		log.Printf(PhaseTeardown, "artifact upload failed: %s", msg)
+	}
+
+	maxBytes := int64(e.cfg.ArtifactMaxMB) * 1024 * 1024
+	files, total, skipped, err := collectArtifacts(ctx, workDir, patterns, maxBytes)
+	if err != nil {
+		failed("%v", err)
+		return
+	}
+	if skipped > 0 {
+		log.Printf(PhaseTeardown, "skipped %d artifacts over the %d MB limit", skipped, e.cfg.ArtifactMaxMB)
+	}
+	if len(files) == 0 {
+		log.Printf(PhaseTeardown, "no artifacts matched %s", strings.Join(patterns, ", "))
+		return
+	}
+
+	// The archive is built on disk first so the upload has a known size
+	tmp, err := os.CreateTemp(e.cfg.JobsWorkspace, fmt.Sprintf(".artifacts_%d_*.tar.gz", job.ID))
+	if err != nil {
+		failed("%v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := fileops.WriteFilesTarGz(ctx, tmp, workDir, files); err != nil {
+		failed("%v", err)
+		return
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		failed("%v", err)
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		failed("%v", err)
+		return
+	}
+
+	if _, err := e.masterClient.UploadArtifacts(ctx, job.ID, tmp, size); err != nil {
+		failed("%v", err)
+		return
+	}
+	log.Printf(PhaseTeardown, "uploaded %d artifacts (%d bytes, %d compressed)", len(files), total, size)
+	slog.Info("Uploaded artifacts", "job_id", job.ID, "count", len(files), "bytes", total)
+}
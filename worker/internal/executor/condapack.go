@@ -0,0 +1,302 @@
+package executor
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// readyMarker marks a fully unpacked environment in the cache.
+const readyMarker = ".ready"
+
+// condaPackClient downloads conda-pack archives. Packs run to gigabytes, so
+// the timeout is generous, but a stalled server can't hold a job forever.
+var condaPackClient = &http.Client{Timeout: 30 * time.Minute}
+
+// prepareCondaPack makes sure the conda-pack archive is unpacked in the env
+// cache and returns the path of the environment root.
+func (e *Executor) prepareCondaPack(ctx context.Context, packURL, checksum string) (string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if checksum == "" {
+		return "", fmt.Errorf("conda_pack_sha256 is required when conda_pack_url is set")
+	}
+	if b, err := hex.DecodeString(checksum); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("conda_pack_sha256 is not a valid sha256 digest")
+	}
+
+	// Jobs needing the same env wait for each other; different envs unpack in parallel
+	unlock, err := e.cache.Lock(ctx, condaPackKey(checksum))
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	cacheRoot := e.cache.Path("conda-pack")
+	envPath := e.cache.Path("conda-pack", checksum)
+	marker := filepath.Join(envPath, readyMarker)
+
+	// Reuse a previously unpacked environment
+	if _, err := os.Stat(marker); err == nil {
+		now := time.Now()
+		os.Chtimes(marker, now, now)
+		return envPath, nil
+	}
+
+	if err := os.MkdirAll(cacheRoot, 0755); err != nil {
+		return "", fmt.Errorf("failed to create env cache: %w", err)
+	}
+
+	// Download into a temp file, hashing as we go
+	archive, err := os.CreateTemp(cacheRoot, "download-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	if err := download(ctx, packURL, archive, checksum); err != nil {
+		return "", err
+	}
+
+	// Unpack into a staging directory and move into place once complete
+	staging, err := os.MkdirTemp(cacheRoot, "unpack-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging dir: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	name := packURL
+	if u, err := url.Parse(packURL); err == nil {
+		name = u.Path
+	}
+	if err := untar(archive, name, staging); err != nil {
+		return "", fmt.Errorf("failed to unpack conda-pack archive: %w", err)
+	}
+
+	// conda-unpack rewrites prefixes for the new location, so run it in place
+	os.RemoveAll(envPath)
+	if err := os.Rename(staging, envPath); err != nil {
+		return "", fmt.Errorf("failed to move env into cache: %w", err)
+	}
+
+	unpack := filepath.Join(envPath, "bin", "conda-unpack")
+	if _, err := os.Stat(unpack); err == nil {
+		cmd := exec.CommandContext(ctx, unpack)
+		cmd.Dir = envPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			os.RemoveAll(envPath)
+			return "", fmt.Errorf("conda-unpack failed: %v: %s", err, truncate(string(output), 500))
+		}
+	}
+
+	if err := os.WriteFile(marker, []byte(packURL), 0644); err != nil {
+		os.RemoveAll(envPath)
+		return "", err
+	}
+
+	e.pruneEnvCache(cacheRoot, envPath)
+	return envPath, nil
+}
+
+// condaPackKey is the cache lock key of an unpacked environment.
+func condaPackKey(checksum string) string {
+	return "conda-pack/" + checksum
+}
+
+// download fetches packURL into dst and verifies its sha256 checksum.
+func download(ctx context.Context, packURL string, dst io.Writer, checksum string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, packURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid conda_pack_url: %w", err)
+	}
+
+	resp, err := condaPackClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download conda-pack archive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download conda-pack archive: status %d", resp.StatusCode)
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, hasher), resp.Body); err != nil {
+		return fmt.Errorf("failed to download conda-pack archive: %w", err)
+	}
+
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != checksum {
+		return fmt.Errorf("conda-pack checksum mismatch: expected %s, got %s", checksum, got)
+	}
+	return nil
+}
+
+// untar extracts a tar archive (optionally gzip or bzip2 compressed) into dir.
+func untar(r io.Reader, name, dir string) error {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(lower, ".tar.bz2"):
+		r = bzip2.NewReader(r)
+	case strings.HasSuffix(lower, ".tar"):
+	default:
+		return fmt.Errorf("unsupported archive format: %s", filepath.Base(name))
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Checked against symlinks unpacked so far, so an entry can't be
+		// written through a link to outside dir
+		target, err := fileops.ValidatePath(dir, hdr.Name)
+		if err != nil || target == filepath.Clean(dir) {
+			return fmt.Errorf("archive entry escapes target: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0777)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			f.Close()
+		case tar.TypeSymlink:
+			// Links may only point within the environment
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("archive symlink %s has absolute target %s", hdr.Name, hdr.Linkname)
+			}
+			if _, err := fileops.ValidatePath(dir, filepath.Join(filepath.Dir(target), hdr.Linkname)); err != nil {
+				return fmt.Errorf("archive symlink %s points outside the environment: %s", hdr.Name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+			// ".." after an earlier link can still lead out once resolved
+			if _, err := fileops.ValidatePath(dir, hdr.Name); err != nil {
+				os.Remove(target)
+				return fmt.Errorf("archive symlink %s points outside the environment: %s", hdr.Name, hdr.Linkname)
+			}
+		}
+	}
+}
+
+// pruneEnvCache removes least recently used environments until the cache
+// fits within the configured size limit. The env at keep is never removed.
+func (e *Executor) pruneEnvCache(cacheRoot, keep string) {
+	if e.cfg.EnvCacheMaxGB <= 0 {
+		return
+	}
+	limit := int64(e.cfg.EnvCacheMaxGB) * 1024 * 1024 * 1024
+
+	type cachedEnv struct {
+		path     string
+		size     int64
+		lastUsed time.Time
+	}
+
+	entries, err := os.ReadDir(cacheRoot)
+	if err != nil {
+		return
+	}
+
+	var envs []cachedEnv
+	var total int64
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(cacheRoot, entry.Name())
+		info, err := os.Stat(filepath.Join(path, readyMarker))
+		if err != nil {
+			continue // Staging dir or incomplete env
+		}
+		size := dirSize(path)
+		total += size
+		envs = append(envs, cachedEnv{path: path, size: size, lastUsed: info.ModTime()})
+	}
+
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].lastUsed.Before(envs[j].lastUsed)
+	})
+
+	for _, env := range envs {
+		if total <= limit {
+			break
+		}
+		if env.path == keep {
+			continue
+		}
+
+		// Skip envs another job is preparing right now
+		unlock, ok, err := e.cache.TryLock(condaPackKey(filepath.Base(env.path)))
+		if err != nil || !ok {
+			continue
+		}
+		err = os.RemoveAll(env.path)
+		unlock()
+		if err != nil {
+			slog.Warn("Failed to evict cached env", "path", env.path, "error", err)
+			continue
+		}
+		slog.Info("Evicted cached env", "path", env.path, "bytes", env.size)
+		total -= env.size
+	}
+}
+
+// dirSize returns the total size of regular files under path.
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+	"github.com/YangYuS8/mlsmanager-worker/internal/envcache"
+)
+
+// addCachedEnv creates a ready env of the given apparent size, last used at lastUsed.
+func addCachedEnv(t *testing.T, cacheRoot, name string, size int64, lastUsed time.Time) string {
+	t.Helper()
+	path := filepath.Join(cacheRoot, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Sparse, so the cache can exceed a 1 GB limit without using the disk
+	if err := os.Truncate(createFile(t, filepath.Join(path, "lib.so")), size); err != nil {
+		t.Fatal(err)
+	}
+	marker := createFile(t, filepath.Join(path, readyMarker))
+	if err := os.Chtimes(marker, lastUsed, lastUsed); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func createFile(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return path
+}
+
+func TestPruneEnvCacheSparesEnvsInUse(t *testing.T) {
+	const gb = 1 << 30
+	root := t.TempDir()
+	e := &Executor{
+		cfg:   &config.Config{EnvCacheMaxGB: 1},
+		cache: envcache.NewCacheManager(root),
+	}
+	cacheRoot := e.cache.Path("conda-pack")
+	now := time.Now()
+
+	// Oldest first: the env just prepared, one a job is preparing, then an idle one
+	kept := addCachedEnv(t, cacheRoot, "aaaa", gb/2, now.Add(-3*time.Hour))
+	locked := addCachedEnv(t, cacheRoot, "bbbb", gb/2, now.Add(-2*time.Hour))
+	idle := addCachedEnv(t, cacheRoot, "cccc", gb/2, now.Add(-time.Hour))
+
+	unlock, ok, err := e.cache.TryLock(condaPackKey("bbbb"))
+	if err != nil || !ok {
+		t.Fatalf("TryLock: ok=%v err=%v", ok, err)
+	}
+	e.pruneEnvCache(cacheRoot, kept)
+	unlock()
+
+	for path, want := range map[string]bool{kept: true, locked: true, idle: false} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(path), err == nil, want)
+		}
+	}
+}
+
+type tarEntry struct {
+	name, link, body string
+	dir              bool
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		case e.dir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(e.body))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestUntarRejectsEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{"absolute symlink", []tarEntry{{name: "lib", link: "/etc"}}},
+		{"write through absolute symlink", []tarEntry{{name: "lib", link: "/etc"}, {name: "lib/passwd", body: "x"}}},
+		{"relative escaping symlink", []tarEntry{{name: "bin/up", link: "../../outside"}}},
+		{"chained symlinks", []tarEntry{
+			{name: "a/b", dir: true},
+			{name: "a/b/deep", link: ".."},
+			{name: "a/up", link: "b/deep/../.."},
+		}},
+		{"parent traversal", []tarEntry{{name: "../evil", body: "x"}}},
+		{"absolute name", []tarEntry{{name: "/tmp/evil", body: "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "env")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := untar(buildTar(t, tt.entries), "env.tar", dir); err == nil {
+				t.Fatal("untar accepted an escaping archive")
+			}
+			for _, name := range []string{"outside", "passwd", "evil"} {
+				if _, err := os.Lstat(filepath.Join(root, name)); err == nil {
+					t.Errorf("untar wrote %s outside the env dir", name)
+				}
+			}
+		})
+	}
+}
+
+func TestUntarKeepsInternalSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	archive := buildTar(t, []tarEntry{
+		{name: "lib/libpython3.11.so", body: "elf"},
+		{name: "lib/libpython3.so", link: "libpython3.11.so"},
+		{name: "bin/python", link: "../lib/libpython3.so"},
+		{name: "lib64", link: "lib"},
+		{name: "lib64/extra.so", body: "elf"},
+	})
+	if err := untar(archive, "env.tar", dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "bin", "python"))
+	if err != nil || string(data) != "elf" {
+		t.Fatalf("bin/python: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lib", "extra.so")); err != nil {
+		t.Errorf("write through internal symlink: %v", err)
+	}
+}
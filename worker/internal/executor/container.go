@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// containerPrefix starts the name of every container the agent runs a job in.
+const containerPrefix = "mlsmanager-job-"
+
+// containerName returns a Docker container name for one run of a job. The
+// random suffix keeps a retry from colliding with a container the previous
+// run left behind.
+func containerName(jobID int) string {
+	return fmt.Sprintf("%s%d-%08x", containerPrefix, jobID, rand.Uint32())
+}
+
+// containerJobID returns the job ID in a container name, if the agent named it.
+func containerJobID(name string) (int, bool) {
+	rest, ok := strings.CutPrefix(name, containerPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, _, _ := strings.Cut(rest, "-")
+	jobID, err := strconv.Atoi(id)
+	return jobID, err == nil
+}
+
+// containerOf returns the container name of a running docker job.
+func (e *Executor) containerOf(jobID int) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dockerJobs[jobID]
+}
+
+// removeContainer force-removes a job's container. Killing the docker CLI on
+// timeout or cancellation leaves the container running, and --rm only
+// applies once it exits by itself.
+func removeContainer(jobID int, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "docker", "rm", "-f", name).CombinedOutput()
+	if err != nil && !strings.Contains(string(output), "No such container") {
+		slog.Warn("Failed to remove job container", "job_id", jobID, "container", name, "error", err, "output", truncate(strings.TrimSpace(string(output)), 300))
+	}
+}
+
+// removeStaleContainers removes job containers a previous agent process left
+// behind, except those of jobs in keep, which are still being watched.
+func removeStaleContainers(ctx context.Context, keep map[int]bool) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "docker", "ps", "-a", "--filter", "name=^"+containerPrefix, "--format", "{{.Names}}").Output()
+	if err != nil {
+		slog.Debug("Failed to list job containers", "error", err)
+		return
+	}
+	for _, name := range strings.Fields(string(output)) {
+		jobID, ok := containerJobID(name)
+		if !ok || keep[jobID] {
+			continue
+		}
+		slog.Warn("Removing container left by a previous agent", "job_id", jobID, "container", name)
+		removeContainer(jobID, name)
+	}
+}
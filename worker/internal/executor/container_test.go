@@ -0,0 +1,20 @@
+package executor
+
+import "testing"
+
+func TestContainerName(t *testing.T) {
+	a, b := containerName(42), containerName(42)
+	if a == b {
+		t.Errorf("containerName(42) returned %q twice, want a unique name per run", a)
+	}
+	for _, name := range []string{a, b} {
+		if id, ok := containerJobID(name); !ok || id != 42 {
+			t.Errorf("containerJobID(%q) = %d, %v, want 42, true", name, id, ok)
+		}
+	}
+	for _, name := range []string{"mlsmanager-job-", "other-42", "mlsmanager-job-x-1"} {
+		if _, ok := containerJobID(name); ok {
+			t.Errorf("containerJobID(%q) ok, want not an agent container", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package executor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// maxFinishedJobs bounds how many finished job outcomes are remembered for
+// dependency checks.
+const maxFinishedJobs = 1000
+
+// recordFinished remembers whether a job succeeded so dependents can start.
+func (e *Executor) recordFinished(jobID int, succeeded bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, seen := e.finished[jobID]; !seen {
+		e.finishedOrder = append(e.finishedOrder, jobID)
+	}
+	e.finished[jobID] = succeeded
+
+	if len(e.finishedOrder) > maxFinishedJobs {
+		delete(e.finished, e.finishedOrder[0])
+		e.finishedOrder = e.finishedOrder[1:]
+	}
+}
+
+// dependsOn returns the job IDs listed in EnvConfig["depends_on"].
+func dependsOn(job client.Job) []int {
+	var ids []int
+	switch v := job.EnvConfig["depends_on"].(type) {
+	case float64:
+		ids = append(ids, int(v))
+	case []any:
+		for _, item := range v {
+			if id, ok := item.(float64); ok {
+				ids = append(ids, int(id))
+			}
+		}
+	}
+	return ids
+}
+
+// CheckDependencies reports whether all jobs this job depends on have
+// completed on this node. It returns an error when the job can never run: a
+// dependency failed (unless failures are ignored) or the dependencies did not
+// complete within the configured wait.
+func (e *Executor) CheckDependencies(job client.Job) (bool, error) {
+	deps := dependsOn(job)
+	if len(deps) == 0 {
+		return true, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pending := 0
+	for _, dep := range deps {
+		succeeded, done := e.finished[dep]
+		switch {
+		case !done:
+			pending++
+		case !succeeded && e.cfg.JobDependencyFailFast:
+			delete(e.waitingSince, job.ID)
+			return false, fmt.Errorf("dependency job %d failed", dep)
+		}
+	}
+
+	if pending == 0 {
+		delete(e.waitingSince, job.ID)
+		return true, nil
+	}
+
+	since, ok := e.waitingSince[job.ID]
+	if !ok {
+		since = time.Now()
+		e.waitingSince[job.ID] = since
+	}
+
+	timeout := time.Duration(e.cfg.JobDependencyTimeout) * time.Second
+	if time.Since(since) > timeout {
+		delete(e.waitingSince, job.ID)
+		return false, fmt.Errorf("%d dependencies did not complete within %v", pending, timeout)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// resolveDynamicEnv runs the commands in EnvConfig["dynamic_env"] (variable
+// name to shell command) on the host and returns the job's variables with
+// their trimmed stdout added. Commands see the job's static environment, with
+// secret references resolved, and run in its working directory. The returned
+// variables keep the references, so secret values never reach the job log. Container jobs would run them outside their
+// container, so they are refused unless AGENT_JOB_DYNAMIC_ENV_CONTAINERS is set.
+func (e *Executor) resolveDynamicEnv(ctx context.Context, job client.Job, workDir string) (map[string]string, error) {
+	spec, ok := job.EnvConfig["dynamic_env"].(map[string]any)
+	if !ok || len(spec) == 0 {
+		return job.EnvironmentVars, nil
+	}
+
+	if runtime := jobRuntime(job); (runtime == "docker" || runtime == "apptainer") && !e.cfg.JobDynamicEnvContainers {
+		return nil, fmt.Errorf("dynamic_env is disabled for %s jobs since its commands would run on the host, outside the container (AGENT_JOB_DYNAMIC_ENV_CONTAINERS)", runtime)
+	}
+
+	static, err := e.resolveSecrets(e.jobEnv(job.EnvironmentVars))
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(e.cfg.JobDynamicEnvTimeout) * time.Second
+	env := e.buildEnv(static)
+
+	resolved := make(map[string]string, len(job.EnvironmentVars)+len(spec))
+	maps.Copy(resolved, job.EnvironmentVars)
+
+	for _, name := range slices.Sorted(maps.Keys(spec)) {
+		command, ok := spec[name].(string)
+		if !ok || command == "" {
+			return nil, fmt.Errorf("dynamic env %s: command must be a non-empty string", name)
+		}
+
+		cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+		cmd := exec.CommandContext(cmdCtx, "sh", "-c", command)
+		cmd.Dir = workDir
+		cmd.Env = env
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		timedOut := cmdCtx.Err() == context.DeadlineExceeded
+		cancel()
+
+		switch {
+		case timedOut:
+			return nil, fmt.Errorf("dynamic env %s: command timed out after %v", name, timeout)
+		case err != nil:
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = err.Error()
+			}
+			return nil, fmt.Errorf("dynamic env %s: %s", name, truncate(msg, 500))
+		}
+
+		resolved[name] = strings.TrimRight(stdout.String(), "\r\n")
+	}
+
+	return resolved, nil
+}
+
+// dynamicEnvNames returns the variables a job resolves with dynamic_env.
+// Their values are often short-lived credentials, so they are never logged.
+func dynamicEnvNames(job client.Job) []string {
+	spec, _ := job.EnvConfig["dynamic_env"].(map[string]any)
+	return slices.Collect(maps.Keys(spec))
+}
@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+func dynamicEnvJob(environment string) client.Job {
+	return client.Job{
+		ID:              1,
+		Environment:     environment,
+		EnvironmentVars: map[string]string{"MODE": "train"},
+		EnvConfig: map[string]any{
+			"dynamic_env": map[string]any{"SESSION": "echo s3cr3t-value"},
+		},
+	}
+}
+
+func TestResolveDynamicEnv(t *testing.T) {
+	e := &Executor{cfg: &config.Config{JobDynamicEnvTimeout: 10}}
+
+	env, err := e.resolveDynamicEnv(context.Background(), dynamicEnvJob(""), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env["SESSION"] != "s3cr3t-value" || env["MODE"] != "train" {
+		t.Errorf("resolved env = %v", env)
+	}
+}
+
+// dynamic_env runs on the host, so container jobs only get it when allowed.
+func TestResolveDynamicEnvContainers(t *testing.T) {
+	for _, environment := range []string{"docker", "apptainer", "singularity"} {
+		t.Run(environment, func(t *testing.T) {
+			job := dynamicEnvJob(environment)
+
+			e := &Executor{cfg: &config.Config{JobDynamicEnvTimeout: 10}}
+			if _, err := e.resolveDynamicEnv(context.Background(), job, t.TempDir()); err == nil ||
+				!strings.Contains(err.Error(), "AGENT_JOB_DYNAMIC_ENV_CONTAINERS") {
+				t.Errorf("resolveDynamicEnv() error = %v, want it refused", err)
+			}
+
+			e.cfg.JobDynamicEnvContainers = true
+			env, err := e.resolveDynamicEnv(context.Background(), job, t.TempDir())
+			if err != nil || env["SESSION"] != "s3cr3t-value" {
+				t.Errorf("resolveDynamicEnv() = %v, %v with AGENT_JOB_DYNAMIC_ENV_CONTAINERS set", env, err)
+			}
+		})
+	}
+}
+
+func TestMaskEnvHidesDynamicValues(t *testing.T) {
+	job := dynamicEnvJob("")
+	masked := maskEnv(map[string]string{"MODE": "train", "SESSION": "s3cr3t-value"}, dynamicEnvNames(job)...)
+	if strings.Contains(masked, "s3cr3t-value") {
+		t.Errorf("maskEnv() = %q, leaks a dynamic_env value", masked)
+	}
+	if !strings.Contains(masked, "MODE=train") {
+		t.Errorf("maskEnv() = %q, hides a static value", masked)
+	}
+}
+
+func TestResolveDynamicEnvSeesSecrets(t *testing.T) {
+	secrets := t.TempDir()
+	if err := os.WriteFile(filepath.Join(secrets, "api_key"), []byte("k3y\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	e := &Executor{cfg: &config.Config{JobDynamicEnvTimeout: 10, SecretsDir: secrets}}
+
+	job := dynamicEnvJob("")
+	job.EnvironmentVars = map[string]string{"API_KEY": secretScheme + "api_key"}
+	job.EnvConfig["dynamic_env"] = map[string]any{"SESSION": `echo "session-for-$API_KEY"`}
+
+	env, err := e.resolveDynamicEnv(context.Background(), job, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env["SESSION"] != "session-for-k3y" {
+		t.Errorf("SESSION = %q, want the command to see the secret value", env["SESSION"])
+	}
+	if env["API_KEY"] != secretScheme+"api_key" {
+		t.Errorf("API_KEY = %q, want the reference kept for logging", env["API_KEY"])
+	}
+}
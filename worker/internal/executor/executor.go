@@ -4,21 +4,57 @@ package executor
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/YangYuS8/mlsmanager-worker/internal/client"
 	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+	"github.com/YangYuS8/mlsmanager-worker/internal/envcache"
+	"github.com/YangYuS8/mlsmanager-worker/internal/metrics"
 )
 
 // JobResult represents the result of a job execution.
 type JobResult struct {
 	ExitCode     int
 	ErrorMessage string
+
+	// TimedOut is set when the job was killed for exceeding its timeout
+	TimedOut bool
+
+	// Cancelled is set when the job was stopped on request (CancelJob)
+	Cancelled bool
+
+	// Metrics extracted from the output by the job's result_parser
+	Metrics map[string]any
+
+	// Resource use of the command's process tree, sampled while it ran
+	PeakMemoryMB  int
+	AvgCPUPercent float64
+
+	output []byte
+	ran    bool // the command was started; otherwise setup failed
+}
+
+// Status returns the job status to report for the result: completed,
+// failed, timeout or cancelled.
+func (r JobResult) Status() string {
+	switch {
+	case r.Cancelled:
+		return "cancelled"
+	case r.TimedOut:
+		return "timeout"
+	case r.ExitCode == 0:
+		return "completed"
+	default:
+		return "failed"
+	}
 }
 
 // Executor executes jobs in various environments.
@@ -28,22 +64,118 @@ type Executor struct {
 
 	mu          sync.Mutex
 	runningJobs map[int]*exec.Cmd
+	jobLogs     map[int]*jobLog
+	jobOutput   map[int]*outputGuard
+	dockerJobs  map[int]string        // container name of each running docker job
+	jobDone     map[int]chan struct{} // closed once a job's command has been waited on
+	cancelled   map[int]struct{}      // jobs stopped on request, reported cancelled
+
+	pendingStatus map[int]chan struct{} // closed once intermediate status updates have been sent
+
+	// Worker pool: a slot per running job, and jobs dispatched but not yet
+	// reported with the func cancelling each one's context
+	slots  chan struct{}
+	active map[int]context.CancelFunc
+	wg     sync.WaitGroup
+
+	cache *envcache.CacheManager
+
+	history *History
+
+	// Processes of running jobs, kept on disk across agent restarts
+	journal *Journal
+
+	// Outcomes of finished jobs and wait start times, for job dependencies
+	finished      map[int]bool
+	finishedOrder []int
+	waitingSince  map[int]time.Time
+
+	// When the most recent job start was scheduled, for staggering
+	lastStart time.Time
+
+	// GPU memory reserved by running jobs; gpuMu serializes allocation
+	gpuMu           sync.Mutex
+	gpuReservations map[int]gpuReservation
+
+	// Destinations for job output besides the job log
+	sinks []JobOutputSink
+
+	// Runtimes (docker, conda, ...) whose last probe failed, with the reason
+	runtimesDown map[string]string
+
+	// Defunct children seen at the last reaper scan
+	zombies       map[int]string
+	zombiesReaped int64
 }
 
 // NewExecutor creates a new job executor.
 func NewExecutor(cfg *config.Config, masterClient *client.MasterClient) *Executor {
 	return &Executor{
-		cfg:          cfg,
-		masterClient: masterClient,
-		runningJobs:  make(map[int]*exec.Cmd),
+		cfg:             cfg,
+		masterClient:    masterClient,
+		runningJobs:     make(map[int]*exec.Cmd),
+		jobLogs:         make(map[int]*jobLog),
+		jobOutput:       make(map[int]*outputGuard),
+		dockerJobs:      make(map[int]string),
+		jobDone:         make(map[int]chan struct{}),
+		cancelled:       make(map[int]struct{}),
+		pendingStatus:   make(map[int]chan struct{}),
+		slots:           make(chan struct{}, max(cfg.MaxConcurrentJobs, 1)),
+		active:          make(map[int]context.CancelFunc),
+		cache:           envcache.NewCacheManager(cfg.EnvCacheDir),
+		history:         NewHistory(filepath.Join(cfg.JobsWorkspace, ".job_history.json")),
+		journal:         NewJournal(filepath.Join(cfg.JobsWorkspace, ".job_journal.json")),
+		finished:        make(map[int]bool),
+		waitingSince:    make(map[int]time.Time),
+		gpuReservations: make(map[int]gpuReservation),
+		runtimesDown:    make(map[string]string),
 	}
 }
 
 // Execute runs a job and returns the result.
 func (e *Executor) Execute(ctx context.Context, job client.Job) JobResult {
-	// Notify master that job is running
-	if err := e.masterClient.UpdateJobStatus(ctx, job.ID, "running", nil, nil); err != nil {
-		fmt.Printf("[WARN] Failed to update job status to running: %v\n", err)
+	if err := e.waitStartSlot(ctx); err != nil {
+		if e.takeCancelled(job.ID) {
+			return cancelledResult()
+		}
+		return JobResult{ExitCode: -1, ErrorMessage: fmt.Sprintf("job not started: %v", err)}
+	}
+
+	metrics.JobsExecuted.Inc()
+	metrics.JobsRunning.Add(1)
+	defer metrics.JobsRunning.Add(-1)
+
+	result := e.execute(ctx, job)
+	e.recordFinished(job.ID, result.ExitCode == 0)
+
+	if result.ExitCode == 0 {
+		metrics.JobsSucceeded.Inc()
+	} else {
+		metrics.JobsFailed.Inc()
+	}
+	return result
+}
+
+// execute runs a job once. Retrying a failed job is up to the master, which
+// numbers the attempts in job.Attempt and job.MaxAttempts.
+func (e *Executor) execute(ctx context.Context, job client.Job) JobResult {
+	// Notify master that job is running, without waiting on it
+	e.notifyStatus(job.ID, client.JobStatusUpdate{Status: "running", Attempt: job.Attempt, MaxAttempts: job.MaxAttempts})
+
+	// Setup steps and their failures are logged ahead of the job's own output
+	log := e.openJobLog(job, job.Attempt)
+	defer e.closeJobLog(job.ID, log)
+	if job.Attempt > 0 {
+		log.Printf(PhaseSetup, "attempt %d/%d", job.Attempt, job.MaxAttempts)
+	}
+	log.Printf(PhaseSetup, "runtime: %s", jobRuntime(job))
+	setupFailed := func(msg string) JobResult {
+		if e.takeCancelled(job.ID) {
+			log.Printf(PhaseSetup, "cancelled on request")
+			return cancelledResult()
+		}
+		log.Printf(PhaseSetup, "failed: %s", msg)
+		return JobResult{ExitCode: -1, ErrorMessage: msg}
 	}
 
 	// Prepare working directory
@@ -52,15 +184,59 @@ func (e *Executor) Execute(ctx context.Context, job client.Job) JobResult {
 		workDir = filepath.Join(e.cfg.JobsWorkspace, fmt.Sprintf("job_%d", job.ID))
 	}
 	if err := os.MkdirAll(workDir, 0755); err != nil {
-		errMsg := fmt.Sprintf("failed to create work directory: %v", err)
-		return JobResult{ExitCode: -1, ErrorMessage: errMsg}
+		return setupFailed(fmt.Sprintf("failed to create work directory: %v", err))
+	}
+
+	// Values computed at start (short-lived credentials, ports) join the job's
+	// variables. Their commands see secret values; the variables keep the
+	// references until after logging
+	envVars, err := e.resolveDynamicEnv(ctx, job, workDir)
+	if err != nil {
+		return setupFailed(err.Error())
+	}
+	job.EnvironmentVars = envVars
+
+	// Jobs asking for GPU memory are pinned to a GPU that has room for it
+	gpu, reserved, err := e.reserveGPU(job)
+	if err != nil {
+		return setupFailed(err.Error())
+	}
+	if reserved {
+		defer e.releaseGPU(job.ID)
+		log.Printf(PhaseSetup, "assigned GPU %d with %d MB reserved", gpu.index, gpu.memoryMB)
+		if job.Environment != "docker" {
+			job.EnvironmentVars = maps.Clone(job.EnvironmentVars)
+			if job.EnvironmentVars == nil {
+				job.EnvironmentVars = make(map[string]string)
+			}
+			job.EnvironmentVars["CUDA_VISIBLE_DEVICES"] = gpu.device()
+		}
+	}
+
+	if env := e.jobEnv(job.EnvironmentVars); len(env) > 0 {
+		masked := maskEnv(env, dynamicEnvNames(job)...)
+		slog.Info("Job environment", "job_id", job.ID, "env", masked)
+		log.Printf(PhaseSetup, "environment: %s", masked)
+	}
+
+	// Secret references are resolved after logging, so only their names show
+	if job.EnvironmentVars, err = e.resolveSecrets(e.jobEnv(job.EnvironmentVars)); err != nil {
+		return setupFailed(err.Error())
+	}
+
+	// A missing docker daemon or shell gets a clear error instead of an exec failure
+	if err := e.checkRuntime(ctx, job); err != nil {
+		return setupFailed(err.Error())
 	}
 
 	// Execute based on environment
+	start := time.Now()
 	var result JobResult
 	switch job.Environment {
 	case "docker":
 		result = e.runDocker(ctx, job, workDir)
+	case "apptainer", "singularity":
+		result = e.runApptainer(ctx, job, workDir)
 	case "conda":
 		result = e.runConda(ctx, job, workDir)
 	case "venv":
@@ -69,42 +245,145 @@ func (e *Executor) Execute(ctx context.Context, job client.Job) JobResult {
 		result = e.runSystem(ctx, job, workDir)
 	}
 
+	// A job that finished on its own as the cancel arrived keeps its result
+	if e.takeCancelled(job.ID) && result.ExitCode != 0 {
+		result.Cancelled = true
+		result.ErrorMessage = cancelledResult().ErrorMessage
+		log.Printf(PhaseTeardown, "cancelled on request")
+	}
+
+	if !result.ran && result.ExitCode != 0 && !result.Cancelled {
+		log.Printf(PhaseSetup, "failed: %s", result.ErrorMessage)
+	}
+
+	// Successful runtimes feed the adaptive timeout, and outputs go to the
+	// master before the job is reported completed
+	if result.ExitCode == 0 {
+		e.history.Record(job, time.Since(start))
+		e.uploadArtifacts(ctx, job, workDir, log)
+	}
+
+	// A parser that finds nothing doesn't change the job's outcome
+	parsed, err := parseResults(job, result.output)
+	if err != nil {
+		slog.Warn("Result parsing failed", "job_id", job.ID, "error", err)
+		log.Printf(PhaseTeardown, "result parsing failed: %v", err)
+	} else if len(parsed) > 0 {
+		log.Printf(PhaseTeardown, "parsed %d result metrics", len(parsed))
+	}
+	result.Metrics = parsed
+
 	return result
 }
 
+// jobTimeout returns the execution deadline for a job. An explicit timeout
+// wins; otherwise the adaptive mode derives one from prior runtimes of the
+// same job shape, falling back to the configured default.
+func (e *Executor) jobTimeout(job client.Job) time.Duration {
+	if job.TimeoutSeconds > 0 {
+		return time.Duration(job.TimeoutSeconds) * time.Second
+	}
+
+	fallback := time.Duration(e.cfg.JobDefaultTimeout) * time.Second
+	if !e.cfg.AdaptiveTimeout {
+		return fallback
+	}
+
+	median, runs, ok := e.history.Median(job)
+	if !ok {
+		slog.Info("Job timeout set to the default, no runtime history", "job_id", job.ID, "timeout", fallback.String())
+		return fallback
+	}
+
+	timeout := time.Duration(float64(median) * e.cfg.AdaptiveTimeoutMultiplier)
+	timeout = max(timeout, time.Duration(e.cfg.AdaptiveTimeoutMin)*time.Second)
+	timeout = min(timeout, time.Duration(e.cfg.AdaptiveTimeoutMax)*time.Second)
+
+	slog.Info("Job timeout set from runtime history",
+		"job_id", job.ID, "timeout", timeout.Round(time.Second).String(), "multiplier", e.cfg.AdaptiveTimeoutMultiplier,
+		"median", median.Round(time.Second).String(), "runs", runs)
+	return timeout
+}
+
+// IsRunning reports whether a job is currently executing.
+func (e *Executor) IsRunning(jobID int) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, exists := e.runningJobs[jobID]
+	return exists
+}
+
+// CancelJob stops a job dispatched to this agent on request: its command if
+// it is running, or otherwise whatever it is waiting on, such as its start
+// slot, an image pull or dynamic_env. Unlike a shutdown, the job is reported
+// cancelled, which tells the master not to retry it. It returns false when
+// the job isn't active here.
+func (e *Executor) CancelJob(jobID int) bool {
+	e.mu.Lock()
+	cancel, active := e.active[jobID]
+	if active {
+		e.cancelled[jobID] = struct{}{}
+	}
+	e.mu.Unlock()
+
+	if !active {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// cancelledResult is the result of a job stopped by CancelJob.
+func cancelledResult() JobResult {
+	return JobResult{ExitCode: -1, ErrorMessage: "job cancelled on request", Cancelled: true}
+}
+
+// takeCancelled reports whether a job was stopped by CancelJob, clearing
+// the mark.
+func (e *Executor) takeCancelled(jobID int) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.cancelled[jobID]
+	delete(e.cancelled, jobID)
+	return ok
+}
+
 // Cancel cancels a running job.
 func (e *Executor) Cancel(jobID int) bool {
 	e.mu.Lock()
 	cmd, exists := e.runningJobs[jobID]
+	done := e.jobDone[jobID]
 	e.mu.Unlock()
 
 	if !exists || cmd.Process == nil {
 		return false
 	}
 
-	// Send SIGTERM first
-	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+	// Send SIGTERM to the job's whole process group first
+	if err := signalGroup(cmd, syscall.SIGTERM); err != nil {
 		// If SIGTERM fails, force kill
-		cmd.Process.Kill()
+		signalGroup(cmd, syscall.SIGKILL)
 	}
 
-	// Wait for graceful shutdown
-	done := make(chan struct{})
-	go func() {
-		cmd.Wait()
-		close(done)
-	}()
+	// The job's own runCommand waits on (and reaps) the process; calling
+	// cmd.Wait here as well would return immediately with an error.
+	if done == nil {
+		return true
+	}
 
+	// Wait for graceful shutdown, then kill whatever is left of the group
 	select {
 	case <-done:
-		return true
-	case <-time.After(10 * time.Second):
-		cmd.Process.Kill()
-		return true
+	case <-time.After(killGracePeriod):
+		signalGroup(cmd, syscall.SIGKILL)
+		<-done
 	}
+	signalGroup(cmd, syscall.SIGKILL)
+	return true
 }
 
-// CancelAll cancels all running jobs.
+// CancelAll cancels all running jobs at once, so each job's grace period
+// runs concurrently.
 func (e *Executor) CancelAll() {
 	e.mu.Lock()
 	jobIDs := make([]int, 0, len(e.runningJobs))
@@ -113,22 +392,28 @@ func (e *Executor) CancelAll() {
 	}
 	e.mu.Unlock()
 
+	var wg sync.WaitGroup
 	for _, id := range jobIDs {
-		e.Cancel(id)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.Cancel(id)
+		}()
 	}
+	wg.Wait()
 }
 
 // runSystem executes a job directly in the system shell.
 func (e *Executor) runSystem(ctx context.Context, job client.Job, workDir string) JobResult {
-	timeout := time.Duration(job.TimeoutSeconds) * time.Second
-	if timeout == 0 {
-		timeout = time.Hour // Default 1 hour
-	}
+	timeout := e.jobTimeout(job)
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", job.Command)
+	cmd, err := shellCommand(ctx, e.cfg.JobShellSystem, job.Command)
+	if err != nil {
+		return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+	}
 	cmd.Dir = workDir
 	cmd.Env = e.buildEnv(job.EnvironmentVars)
 
@@ -142,32 +427,11 @@ func (e *Executor) runSystem(ctx context.Context, job client.Job, workDir string
 		e.mu.Unlock()
 	}()
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		exitCode := -1
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		}
-		errMsg := truncate(string(output), 1000)
-		if errMsg == "" {
-			errMsg = err.Error()
-		}
-		return JobResult{ExitCode: exitCode, ErrorMessage: errMsg}
-	}
-
-	return JobResult{ExitCode: 0}
+	return e.runCommand(ctx, job, cmd)
 }
 
 // runDocker executes a job in a Docker container.
 func (e *Executor) runDocker(ctx context.Context, job client.Job, workDir string) JobResult {
-	timeout := time.Duration(job.TimeoutSeconds) * time.Second
-	if timeout == 0 {
-		timeout = time.Hour
-	}
-
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
 	// Get Docker configuration
 	envConfig := job.EnvConfig
 	image := "python:3.12"
@@ -175,8 +439,31 @@ func (e *Executor) runDocker(ctx context.Context, job client.Job, workDir string
 		image = img
 	}
 
-	// Build docker run command
-	args := []string{"run", "--rm"}
+	// Check limits before pulling so a malformed value fails fast
+	limits, err := dockerResourceArgs(envConfig)
+	if err != nil {
+		return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+	}
+
+	if err := e.checkImagePolicy(image); err != nil {
+		return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+	}
+
+	// The image is pulled up front, outside the job's timeout
+	if err := e.ensureImage(ctx, job.ID, image); err != nil {
+		return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+	}
+	if err := e.verifyImage(ctx, image); err != nil {
+		return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+	}
+
+	timeout := e.jobTimeout(job)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Build docker run command; the name lets us find the container's processes
+	name := containerName(job.ID)
+	args := []string{"run", "--rm", "--name", name}
 
 	// Add volume mounts
 	args = append(args, "-v", fmt.Sprintf("%s:/workspace", workDir))
@@ -188,14 +475,38 @@ func (e *Executor) runDocker(ctx context.Context, job client.Job, workDir string
 		}
 	}
 
-	// Add GPU support
-	if gpu, ok := envConfig["gpu"].(bool); ok && gpu {
+	// Network mode: the node default, or a job's choice from the allowlist
+	network := e.cfg.DockerDefaultNetwork
+	if mode, ok := envConfig["network_mode"].(string); ok && mode != "" && mode != network {
+		if !slices.Contains(e.cfg.DockerAllowedNetworks, mode) {
+			return JobResult{ExitCode: -1, ErrorMessage: fmt.Sprintf("network mode %q is not allowed on this node", mode)}
+		}
+		network = mode
+	}
+	if network != "" {
+		args = append(args, "--network", network)
+		slog.Info("Job network mode", "job_id", job.ID, "network", network)
+	}
+
+	// Resource limits; see dockerResourceArgs for how cpus and gpu combine
+	args = append(args, limits...)
+
+	// Add GPU support; a job with a memory reservation only sees its GPU
+	if r, ok := e.jobGPU(job.ID); ok {
+		args = append(args, "--gpus", "device="+r.device())
+	} else if gpu, ok := envConfig["gpu"].(bool); ok && gpu {
 		args = append(args, "--gpus", "all")
 	}
 
-	// Add environment variables
-	for k, v := range job.EnvironmentVars {
-		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+	// Environment variables go through a private file rather than -e, which
+	// would show them in the process table
+	if env := e.jobEnv(job.EnvironmentVars); len(env) > 0 {
+		envFile, err := e.writeEnvFile(job.ID, env)
+		if err != nil {
+			return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+		}
+		defer os.Remove(envFile)
+		args = append(args, "--env-file", envFile)
 	}
 
 	// Set working directory and image
@@ -208,36 +519,26 @@ func (e *Executor) runDocker(ctx context.Context, job client.Job, workDir string
 
 	e.mu.Lock()
 	e.runningJobs[job.ID] = cmd
+	e.dockerJobs[job.ID] = name
 	e.mu.Unlock()
 
 	defer func() {
 		e.mu.Lock()
 		delete(e.runningJobs, job.ID)
+		delete(e.dockerJobs, job.ID)
 		e.mu.Unlock()
 	}()
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		exitCode := -1
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		}
-		errMsg := truncate(string(output), 1000)
-		if errMsg == "" {
-			errMsg = err.Error()
-		}
-		return JobResult{ExitCode: exitCode, ErrorMessage: errMsg}
+	result := e.runCommand(ctx, job, cmd)
+	if ctx.Err() != nil {
+		removeContainer(job.ID, name)
 	}
-
-	return JobResult{ExitCode: 0}
+	return result
 }
 
 // runConda executes a job in a conda environment.
 func (e *Executor) runConda(ctx context.Context, job client.Job, workDir string) JobResult {
-	timeout := time.Duration(job.TimeoutSeconds) * time.Second
-	if timeout == 0 {
-		timeout = time.Hour
-	}
+	timeout := e.jobTimeout(job)
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
@@ -254,7 +555,21 @@ func (e *Executor) runConda(ctx context.Context, job client.Job, workDir string)
 		envName, job.Command,
 	)
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", wrappedCmd)
+	// A prebuilt conda-pack archive replaces the named environment
+	if packURL, ok := job.EnvConfig["conda_pack_url"].(string); ok && packURL != "" {
+		checksum, _ := job.EnvConfig["conda_pack_sha256"].(string)
+		e.logPhase(job.ID, PhaseSetup, "preparing conda-pack environment %s", checksum)
+		envPath, err := e.prepareCondaPack(ctx, packURL, checksum)
+		if err != nil {
+			return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+		}
+		wrappedCmd = fmt.Sprintf("source %s && %s", filepath.Join(envPath, "bin", "activate"), job.Command)
+	}
+
+	cmd, err := shellCommand(ctx, e.cfg.JobShellConda, wrappedCmd)
+	if err != nil {
+		return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+	}
 	cmd.Dir = workDir
 	cmd.Env = e.buildEnv(job.EnvironmentVars)
 
@@ -268,28 +583,12 @@ func (e *Executor) runConda(ctx context.Context, job client.Job, workDir string)
 		e.mu.Unlock()
 	}()
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		exitCode := -1
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		}
-		errMsg := truncate(string(output), 1000)
-		if errMsg == "" {
-			errMsg = err.Error()
-		}
-		return JobResult{ExitCode: exitCode, ErrorMessage: errMsg}
-	}
-
-	return JobResult{ExitCode: 0}
+	return e.runCommand(ctx, job, cmd)
 }
 
 // runVenv executes a job in a Python virtual environment.
 func (e *Executor) runVenv(ctx context.Context, job client.Job, workDir string) JobResult {
-	timeout := time.Duration(job.TimeoutSeconds) * time.Second
-	if timeout == 0 {
-		timeout = time.Hour
-	}
+	timeout := e.jobTimeout(job)
 
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
@@ -309,7 +608,10 @@ func (e *Executor) runVenv(ctx context.Context, job client.Job, workDir string)
 	activateScript := filepath.Join(venvPath, "bin", "activate")
 	wrappedCmd := fmt.Sprintf("source %s && %s", activateScript, job.Command)
 
-	cmd := exec.CommandContext(ctx, "bash", "-c", wrappedCmd)
+	cmd, err := shellCommand(ctx, e.cfg.JobShellVenv, wrappedCmd)
+	if err != nil {
+		return JobResult{ExitCode: -1, ErrorMessage: err.Error()}
+	}
 	cmd.Dir = workDir
 	cmd.Env = e.buildEnv(job.EnvironmentVars)
 
@@ -323,31 +625,48 @@ func (e *Executor) runVenv(ctx context.Context, job client.Job, workDir string)
 		e.mu.Unlock()
 	}()
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		exitCode := -1
-		if exitError, ok := err.(*exec.ExitError); ok {
-			exitCode = exitError.ExitCode()
-		}
-		errMsg := truncate(string(output), 1000)
-		if errMsg == "" {
-			errMsg = err.Error()
-		}
-		return JobResult{ExitCode: exitCode, ErrorMessage: errMsg}
-	}
+	return e.runCommand(ctx, job, cmd)
+}
 
-	return JobResult{ExitCode: 0}
+// jobEnv merges the node-wide global environment with a job's own variables.
+// Per-job values take precedence.
+func (e *Executor) jobEnv(envVars map[string]string) map[string]string {
+	env := make(map[string]string, len(e.cfg.JobGlobalEnv)+len(envVars))
+	maps.Copy(env, e.cfg.JobGlobalEnv)
+	maps.Copy(env, envVars)
+	return env
 }
 
-// buildEnv builds environment variables for job execution.
+// buildEnv builds environment variables for job execution. The agent's PATH
+// can be replaced or prefixed by config; a PATH set by the job still wins.
 func (e *Executor) buildEnv(envVars map[string]string) []string {
 	env := os.Environ()
-	for k, v := range envVars {
+	if e.cfg.JobPath != "" || e.cfg.JobPathPrefix != "" {
+		path := os.Getenv("PATH")
+		if e.cfg.JobPath != "" {
+			path = e.cfg.JobPath
+		}
+		if e.cfg.JobPathPrefix != "" {
+			path = e.cfg.JobPathPrefix + string(os.PathListSeparator) + path
+		}
+		env = append(env, "PATH="+path)
+	}
+	for k, v := range e.jobEnv(envVars) {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
 	return env
 }
 
+// shellCommand runs script with shell -c, failing clearly when the shell
+// isn't installed rather than with an opaque exec error.
+func shellCommand(ctx context.Context, shell, script string) (*exec.Cmd, error) {
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		return nil, fmt.Errorf("job shell %q not found on this node: %v", shell, err)
+	}
+	return exec.CommandContext(ctx, path, "-c", script), nil
+}
+
 // truncate truncates a string to the specified length.
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/sysinfo"
+)
+
+// gpuReservation is GPU memory set aside for a job that asked for
+// EnvConfig["gpu_memory_mb"].
+type gpuReservation struct {
+	index    int
+	uuid     string
+	memoryMB int
+}
+
+// device returns how the GPU is named to CUDA and Docker. The UUID is stable
+// regardless of CUDA's device ordering.
+func (r gpuReservation) device() string {
+	if r.uuid != "" {
+		return r.uuid
+	}
+	return fmt.Sprint(r.index)
+}
+
+// reserveGPU picks a GPU with enough free memory for the job and reserves it
+// until releaseGPU. Memory a job has reserved but not yet allocated counts as
+// taken, so jobs starting together don't claim the same headroom. The
+// tightest fit wins, leaving larger gaps for larger jobs.
+func (e *Executor) reserveGPU(job client.Job) (gpuReservation, bool, error) {
+	mb, ok := job.EnvConfig["gpu_memory_mb"].(float64)
+	if !ok || mb <= 0 {
+		return gpuReservation{}, false, nil
+	}
+	want := int(mb)
+
+	// One allocation at a time, from query to reservation
+	e.gpuMu.Lock()
+	defer e.gpuMu.Unlock()
+
+	stats := sysinfo.GetGPUStats()
+	if len(stats) == 0 {
+		return gpuReservation{}, false, fmt.Errorf("gpu_memory_mb requested but no GPU memory stats are available")
+	}
+	pending := e.pendingGPUMemory()
+
+	var best *sysinfo.GPUStat
+	bestFree, mostFree := 0, 0
+	for i := range stats {
+		free := stats[i].MemoryFreeMB() - pending[stats[i].UUID]
+		mostFree = max(mostFree, free)
+		if free >= want && (best == nil || free < bestFree) {
+			best, bestFree = &stats[i], free
+		}
+	}
+	if best == nil {
+		return gpuReservation{}, false, fmt.Errorf("no GPU has %d MB free (most available: %d MB)", want, mostFree)
+	}
+
+	r := gpuReservation{index: best.Index, uuid: best.UUID, memoryMB: want}
+	e.mu.Lock()
+	e.gpuReservations[job.ID] = r
+	e.mu.Unlock()
+
+	slog.Info("Assigned GPU",
+		"job_id", job.ID, "gpu", best.Index, "gpu_name", best.Name, "reserved_mb", want, "free_mb", bestFree)
+	return r, true, nil
+}
+
+// releaseGPU drops a job's GPU memory reservation.
+func (e *Executor) releaseGPU(jobID int) {
+	e.mu.Lock()
+	delete(e.gpuReservations, jobID)
+	e.mu.Unlock()
+}
+
+// jobGPU returns a job's GPU reservation, if it has one.
+func (e *Executor) jobGPU(jobID int) (gpuReservation, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.gpuReservations[jobID]
+	return r, ok
+}
+
+// pendingGPUMemory returns, per GPU UUID, reserved memory that the owning
+// jobs have not allocated yet. Allocated memory already shows up as used.
+func (e *Executor) pendingGPUMemory() map[string]int {
+	e.mu.Lock()
+	reservations := maps.Clone(e.gpuReservations)
+	e.mu.Unlock()
+
+	pending := make(map[string]int)
+	if len(reservations) == 0 {
+		return pending
+	}
+
+	allocated := make(map[int]int)
+	for _, u := range e.GPUUsage() {
+		if r, ok := reservations[u.JobID]; ok && u.GPUUUID == r.uuid {
+			allocated[u.JobID] += u.UsedMemoryMB
+		}
+	}
+	for jobID, r := range reservations {
+		pending[r.uuid] += max(r.memoryMB-allocated[jobID], 0)
+	}
+	return pending
+}
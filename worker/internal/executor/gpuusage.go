@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/sysinfo"
+)
+
+// jobRootPIDs maps the host PID at the root of each running job's process
+// tree to its job ID. Docker jobs are resolved to the container's init
+// process, since the container is not a child of the docker CLI.
+func (e *Executor) jobRootPIDs() map[int32]int {
+	e.mu.Lock()
+	roots := make(map[int32]int, len(e.runningJobs))
+	dockerJobs := make(map[int]string)
+	for id, cmd := range e.runningJobs {
+		if name, ok := e.dockerJobs[id]; ok {
+			dockerJobs[id] = name
+			continue
+		}
+		if cmd.Process != nil {
+			roots[int32(cmd.Process.Pid)] = id
+		}
+	}
+	e.mu.Unlock()
+
+	for id, name := range dockerJobs {
+		if pid := containerPID(name); pid > 0 {
+			roots[pid] = id
+		}
+	}
+
+	return roots
+}
+
+// containerPID returns the host PID of a container's init process, or 0.
+func containerPID(name string) int32 {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "docker", "inspect", "-f", "{{.State.Pid}}", name).Output()
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(pid)
+}
+
+// GPUUsage attributes GPU compute processes to the running jobs that own them.
+func (e *Executor) GPUUsage() []client.JobGPUUsage {
+	procs := sysinfo.GetGPUProcesses()
+	if len(procs) == 0 {
+		return nil
+	}
+
+	roots := e.jobRootPIDs()
+	if len(roots) == 0 {
+		return nil
+	}
+	parents := sysinfo.ParentPIDs()
+
+	var usage []client.JobGPUUsage
+	for _, proc := range procs {
+		// Walk up the process tree until we hit a job's root process
+		for pid, depth := proc.PID, 0; pid > 1 && depth < 64; depth++ {
+			if jobID, ok := roots[pid]; ok {
+				usage = append(usage, client.JobGPUUsage{
+					JobID:        jobID,
+					PID:          proc.PID,
+					GPUUUID:      proc.GPUUUID,
+					UsedMemoryMB: proc.UsedMemoryMB,
+				})
+				break
+			}
+			pid = parents[pid]
+		}
+	}
+
+	return usage
+}
+
+// JobGPUUsage returns the GPU usage attributed to a single job.
+func (e *Executor) JobGPUUsage(jobID int) []client.JobGPUUsage {
+	var usage []client.JobGPUUsage
+	for _, u := range e.GPUUsage() {
+		if u.JobID == jobID {
+			usage = append(usage, u)
+		}
+	}
+	return usage
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// maxHistoryRuns is the number of runtimes kept per job shape.
+const maxHistoryRuns = 20
+
+// History records runtimes of successful jobs, keyed by job name and command.
+type History struct {
+	path string
+
+	mu   sync.Mutex
+	runs map[string][]float64 // seconds
+}
+
+// NewHistory loads the runtime history stored at path, if any.
+func NewHistory(path string) *History {
+	h := &History{
+		path: path,
+		runs: make(map[string][]float64),
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &h.runs); err != nil {
+			h.runs = make(map[string][]float64)
+		}
+	}
+
+	return h
+}
+
+// historyKey identifies jobs of the same shape.
+func historyKey(job client.Job) string {
+	sum := sha256.Sum256([]byte(job.Command))
+	return job.Name + ":" + hex.EncodeToString(sum[:8])
+}
+
+// Record stores the runtime of a successful job and persists the history.
+// The file is written under the lock, so jobs finishing together can't
+// replace a newer snapshot with an older one.
+func (h *History) Record(job client.Job, d time.Duration) {
+	key := historyKey(job)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	runs := append(h.runs[key], d.Seconds())
+	if len(runs) > maxHistoryRuns {
+		runs = runs[len(runs)-maxHistoryRuns:]
+	}
+	h.runs[key] = runs
+
+	data, err := json.Marshal(h.runs)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err == nil {
+		os.Rename(tmp, h.path)
+	}
+}
+
+// Median returns the median runtime of prior jobs with the same shape.
+func (h *History) Median(job client.Job) (time.Duration, int, bool) {
+	h.mu.Lock()
+	runs := append([]float64(nil), h.runs[historyKey(job)]...)
+	h.mu.Unlock()
+
+	if len(runs) == 0 {
+		return 0, 0, false
+	}
+
+	sort.Float64s(runs)
+	mid := len(runs) / 2
+	median := runs[mid]
+	if len(runs)%2 == 0 {
+		median = (runs[mid-1] + runs[mid]) / 2
+	}
+
+	return time.Duration(median * float64(time.Second)), len(runs), true
+}
@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// Jobs finishing together must not lose each other's runtimes on disk.
+func TestHistoryRecordConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".job_history.json")
+	h := NewHistory(path)
+
+	const jobs = 16
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Record(client.Job{Name: fmt.Sprintf("job-%d", i), Command: "train"}, time.Duration(i+1)*time.Second)
+		}()
+	}
+	wg.Wait()
+
+	reloaded := NewHistory(path)
+	for i := range jobs {
+		job := client.Job{Name: fmt.Sprintf("job-%d", i), Command: "train"}
+		if d, n, ok := reloaded.Median(job); !ok || n != 1 || d != time.Duration(i+1)*time.Second {
+			t.Errorf("job-%d: median %v over %d runs (ok=%v) after reload", i, d, n, ok)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var imageDigestRe = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// imageDigest returns the digest of an image reference pinned as repo@sha256:...
+func imageDigest(image string) (string, bool) {
+	_, digest, ok := strings.Cut(image, "@")
+	if !ok || !imageDigestRe.MatchString(digest) {
+		return "", false
+	}
+	return digest, true
+}
+
+// checkImagePolicy refuses unpinned images when the node requires digests.
+func (e *Executor) checkImagePolicy(image string) error {
+	if _, pinned := imageDigest(image); !pinned && e.cfg.RequireImageDigest {
+		return fmt.Errorf("image %q is not pinned by digest (repo@sha256:...), required on this node", image)
+	}
+	return nil
+}
+
+// ensureImage pulls a Docker job's image unless it is already present. The
+// pull has its own timeout (AGENT_DOCKER_PULL_TIMEOUT) instead of the job's,
+// and its failures are reported as pull failures rather than as the job's.
+func (e *Executor) ensureImage(ctx context.Context, jobID int, image string) error {
+	if err := exec.CommandContext(ctx, "docker", "image", "inspect", "--format", "{{.Id}}", image).Run(); err == nil {
+		return nil
+	}
+
+	timeout := time.Duration(e.cfg.DockerPullTimeout) * time.Second
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Pull progress goes to the job's log as setup output
+	slog.Info("Pulling image", "job_id", jobID, "image", image)
+	e.logPhase(jobID, PhaseSetup, "pulling %s", image)
+	start := time.Now()
+
+	var output bytes.Buffer
+	pull := exec.CommandContext(ctx, "docker", "pull", image)
+	pull.Stdout = io.MultiWriter(&output, e.setupOutput(jobID))
+	pull.Stderr = pull.Stdout
+	if err := pull.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("image pull failed: %s not pulled within %v", image, timeout)
+		}
+		return fmt.Errorf("image pull failed: %s: %v: %s", image, err, truncate(output.String(), 500))
+	}
+
+	elapsed := time.Since(start).Round(time.Second)
+	slog.Info("Pulled image", "job_id", jobID, "image", image, "elapsed", elapsed.String())
+	e.logPhase(jobID, PhaseSetup, "pulled %s in %v", image, elapsed)
+	return nil
+}
+
+// verifyImage makes sure an image pinned by digest resolves locally to that
+// digest, so a repointed tag or tampered local image is never run. The image
+// must have been pulled by ensureImage.
+func (e *Executor) verifyImage(ctx context.Context, image string) error {
+	digest, pinned := imageDigest(image)
+	if !pinned {
+		return nil
+	}
+
+	digests, err := localImageDigests(ctx, image)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", image, err)
+	}
+
+	for _, d := range digests {
+		if strings.HasSuffix(d, "@"+digest) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image digest mismatch for %s: local image has %s", image, strings.Join(digests, ", "))
+}
+
+// localImageDigests returns the repo digests of a locally available image.
+func localImageDigests(ctx context.Context, image string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "docker", "image", "inspect",
+		"--format", "{{range .RepoDigests}}{{println .}}{{end}}", image)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
@@ -0,0 +1,198 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// orphanPollInterval is how often a job left running by a previous agent is
+// checked for exit.
+const orphanPollInterval = 10 * time.Second
+
+// orphanMessage is reported for jobs whose outcome was lost to a restart.
+const orphanMessage = "agent restarted while the job was running; its exit status is unknown"
+
+// JournalEntry records a started job's process.
+type JournalEntry struct {
+	JobID     int       `json:"job_id"`
+	PID       int       `json:"pid"`
+	StartTick uint64    `json:"start_tick,omitempty"` // /proc start time, to detect PID reuse
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Journal records the processes of running jobs on disk, so jobs orphaned by
+// an agent restart can be reported to the master.
+type Journal struct {
+	path string
+
+	mu   sync.Mutex
+	jobs map[int]JournalEntry
+}
+
+// NewJournal loads the journal stored at path, if any.
+func NewJournal(path string) *Journal {
+	j := &Journal{
+		path: path,
+		jobs: make(map[int]JournalEntry),
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		var entries []JournalEntry
+		if err := json.Unmarshal(data, &entries); err == nil {
+			for _, entry := range entries {
+				j.jobs[entry.JobID] = entry
+			}
+		}
+	}
+
+	return j
+}
+
+// Add records a job's process once it has started.
+func (j *Journal) Add(jobID, pid int) {
+	start, _ := processStartTick(pid)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jobs[jobID] = JournalEntry{JobID: jobID, PID: pid, StartTick: start, StartedAt: time.Now()}
+	j.save()
+}
+
+// Remove drops a job's entry once its command has finished.
+func (j *Journal) Remove(jobID int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.jobs[jobID]; !ok {
+		return
+	}
+	delete(j.jobs, jobID)
+	j.save()
+}
+
+// Entries returns the recorded jobs ordered by job ID.
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := make([]JournalEntry, 0, len(j.jobs))
+	for _, entry := range j.jobs {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].JobID < entries[b].JobID })
+	return entries
+}
+
+// save writes the journal; the caller holds j.mu.
+func (j *Journal) save() {
+	entries := make([]JournalEntry, 0, len(j.jobs))
+	for _, entry := range j.jobs {
+		entries = append(entries, entry)
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		slog.Warn("Failed to write job journal", "error", err)
+		return
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		slog.Warn("Failed to write job journal", "error", err)
+		return
+	}
+	os.Rename(tmp, j.path)
+}
+
+// RecoverOrphans reports jobs a previous agent process left in the journal.
+// Jobs whose process is gone are failed now; jobs still running are watched
+// in the background and failed once they exit, since their output and exit
+// code can no longer be collected. Job containers not belonging to a watched
+// job are removed. Call it before accepting new jobs.
+func (e *Executor) RecoverOrphans(ctx context.Context) {
+	watched := make(map[int]bool)
+	for _, entry := range e.journal.Entries() {
+		if processAlive(entry) {
+			slog.Warn("Job outlived the previous agent, reporting it once it exits", "job_id", entry.JobID, "pid", entry.PID)
+			watched[entry.JobID] = true
+			go e.watchOrphan(ctx, entry)
+			continue
+		}
+		e.reportOrphan(ctx, entry)
+	}
+	removeStaleContainers(ctx, watched)
+}
+
+// watchOrphan waits for an orphaned job's process to exit, then reports it.
+func (e *Executor) watchOrphan(ctx context.Context, entry JournalEntry) {
+	ticker := time.NewTicker(orphanPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return // Left in the journal for the next start
+		case <-ticker.C:
+			if !processAlive(entry) {
+				e.reportOrphan(ctx, entry)
+				return
+			}
+		}
+	}
+}
+
+// reportOrphan fails an orphaned job on the master and drops its entry.
+func (e *Executor) reportOrphan(ctx context.Context, entry JournalEntry) {
+	slog.Warn("Job was running when the agent stopped, marking it failed", "job_id", entry.JobID)
+	msg := orphanMessage
+	if err := e.ReportStatus(ctx, entry.JobID, client.JobStatusUpdate{Status: "failed", ErrorMessage: &msg}); err != nil {
+		slog.Warn("Failed to report orphaned job", "job_id", entry.JobID, "error", err)
+		return // Kept in the journal to retry on the next start
+	}
+	e.journal.Remove(entry.JobID)
+}
+
+// processAlive reports whether a journaled process is still running, and is
+// the same process rather than a new one that reused its PID.
+func processAlive(entry JournalEntry) bool {
+	start, ok := processStartTick(entry.PID)
+	if !ok {
+		return false
+	}
+	return entry.StartTick == 0 || start == entry.StartTick
+}
+
+// processStartTick returns a live (non-zombie) process's start time in clock
+// ticks since boot, from /proc/<pid>/stat.
+func processStartTick(pid int) (uint64, bool) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+
+	// Format: pid (comm) state ppid ...; starttime is the 22nd field
+	stat := string(data)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 || fields[0] == "Z" || fields[0] == "X" {
+		return 0, false
+	}
+	start, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
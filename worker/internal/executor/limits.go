@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dockerSizeRe matches Docker's byte sizes: a number with an optional b, k,
+// m or g unit.
+var dockerSizeRe = regexp.MustCompile(`^[0-9]+[bkmg]?$`)
+
+// dockerResourceArgs turns the cpus, memory and shm_size keys of a job's
+// env_config into docker run flags. A malformed value is an error rather
+// than a dropped flag, so a job never runs without the limit it asked for.
+//
+// cpus caps host CPU time only. It applies the same way whether or not the
+// job has GPU access: gpu: true (or a GPU memory reservation) decides which
+// devices the container sees, and cpus still bounds the host threads that
+// feed them.
+func dockerResourceArgs(envConfig map[string]any) ([]string, error) {
+	var args []string
+
+	if v, ok := envConfig["cpus"]; ok {
+		var cpus float64
+		switch c := v.(type) {
+		case float64:
+			cpus = c
+		case string:
+			parsed, err := strconv.ParseFloat(c, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpus value %q: must be a number", c)
+			}
+			cpus = parsed
+		default:
+			return nil, fmt.Errorf("invalid cpus value %v: must be a number", v)
+		}
+		if cpus <= 0 {
+			return nil, fmt.Errorf("invalid cpus value %v: must be greater than 0", v)
+		}
+		args = append(args, "--cpus", strconv.FormatFloat(cpus, 'f', -1, 64))
+	}
+
+	for _, limit := range []struct{ key, flag string }{
+		{"memory", "--memory"},
+		{"shm_size", "--shm-size"},
+	} {
+		v, ok := envConfig[limit.key]
+		if !ok {
+			continue
+		}
+		size, err := dockerSize(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %w", limit.key, err)
+		}
+		args = append(args, limit.flag, size)
+	}
+
+	return args, nil
+}
+
+// dockerSize validates a byte size given as a string like "4g" or as a
+// plain number of bytes.
+func dockerSize(v any) (string, error) {
+	switch s := v.(type) {
+	case string:
+		size := strings.ToLower(strings.TrimSpace(s))
+		if !dockerSizeRe.MatchString(size) || strings.Trim(size, "0bkmg") == "" {
+			return "", fmt.Errorf("%q is not a size like 512m or 4g", s)
+		}
+		return size, nil
+	case float64:
+		if s <= 0 || s != float64(int64(s)) {
+			return "", fmt.Errorf("%v is not a positive whole number of bytes", s)
+		}
+		return strconv.FormatInt(int64(s), 10), nil
+	default:
+		return "", fmt.Errorf("%v is not a size like 512m or 4g", v)
+	}
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDockerResourceArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		envConfig map[string]any
+		want      []string
+	}{
+		{"no limits", map[string]any{}, nil},
+		{"cpus number", map[string]any{"cpus": 2.5}, []string{"--cpus", "2.5"}},
+		{"cpus string", map[string]any{"cpus": "4"}, []string{"--cpus", "4"}},
+		{"memory", map[string]any{"memory": "8G"}, []string{"--memory", "8g"}},
+		{"memory bytes", map[string]any{"memory": float64(1 << 30)}, []string{"--memory", "1073741824"}},
+		{"shm size", map[string]any{"shm_size": "512m"}, []string{"--shm-size", "512m"}},
+		{
+			"cpus and memory",
+			map[string]any{"cpus": 2.0, "memory": "4g"},
+			[]string{"--cpus", "2", "--memory", "4g"},
+		},
+		// GPU access is added separately and doesn't change the CPU and memory caps
+		{"gpu only", map[string]any{"gpu": true}, nil},
+		{
+			"gpu with cpus and memory",
+			map[string]any{"gpu": true, "cpus": 8.0, "memory": "32g", "shm_size": "2g"},
+			[]string{"--cpus", "8", "--memory", "32g", "--shm-size", "2g"},
+		},
+		{"gpu disabled with cpus", map[string]any{"gpu": false, "cpus": 1.0}, []string{"--cpus", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dockerResourceArgs(tt.envConfig)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("dockerResourceArgs(%v) = %q, want %q", tt.envConfig, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDockerResourceArgsInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		envConfig map[string]any
+		wantErr   string
+	}{
+		{"cpus zero", map[string]any{"cpus": 0.0}, "greater than 0"},
+		{"cpus negative", map[string]any{"cpus": -1.0}, "greater than 0"},
+		{"cpus not a number", map[string]any{"cpus": "two"}, "must be a number"},
+		{"cpus wrong type", map[string]any{"cpus": true}, "must be a number"},
+		{"memory unknown unit", map[string]any{"memory": "4tb"}, "invalid memory value"},
+		{"memory zero", map[string]any{"memory": "0g"}, "invalid memory value"},
+		{"memory unit only", map[string]any{"memory": "g"}, "invalid memory value"},
+		{"memory fractional bytes", map[string]any{"memory": 1.5}, "invalid memory value"},
+		{"memory negative bytes", map[string]any{"memory": -1.0}, "invalid memory value"},
+		{"shm size wrong type", map[string]any{"shm_size": []any{"1g"}}, "invalid shm_size value"},
+		{"valid cpus with bad memory", map[string]any{"cpus": 2.0, "memory": "lots", "gpu": true}, "invalid memory value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dockerResourceArgs(tt.envConfig)
+			if err == nil {
+				t.Fatalf("dockerResourceArgs(%v) = %q, want error", tt.envConfig, got)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q does not mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
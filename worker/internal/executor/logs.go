@@ -0,0 +1,509 @@
+package executor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// logBuffer keeps the most recent output of a running job in a fixed-size
+// ring and fans new output out to followers.
+type logBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	size      int
+	start     int // index of the oldest byte
+	length    int
+	closed    bool
+	followers map[chan []byte]struct{}
+}
+
+func newLogBuffer(size int) *logBuffer {
+	return &logBuffer{
+		buf:       make([]byte, size),
+		size:      size,
+		followers: make(map[chan []byte]struct{}),
+	}
+}
+
+// Write appends output to the ring, overwriting the oldest bytes when full.
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := p
+	if len(data) > b.size {
+		data = data[len(data)-b.size:]
+	}
+	for _, c := range data {
+		end := (b.start + b.length) % b.size
+		b.buf[end] = c
+		if b.length < b.size {
+			b.length++
+		} else {
+			b.start = (b.start + 1) % b.size
+		}
+	}
+
+	for ch := range b.followers {
+		chunk := append([]byte(nil), p...)
+		select {
+		case ch <- chunk:
+		default: // Slow follower, drop rather than block the job
+		}
+	}
+
+	return len(p), nil
+}
+
+// Bytes returns a copy of the buffered output, oldest first.
+func (b *logBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]byte, b.length)
+	for i := 0; i < b.length; i++ {
+		out[i] = b.buf[(b.start+i)%b.size]
+	}
+	return out
+}
+
+// Follow subscribes to new output. The channel is closed when the job ends.
+func (b *logBuffer) Follow() (<-chan []byte, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan []byte, 64)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.followers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.followers[ch]; ok {
+			delete(b.followers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends all followers.
+func (b *logBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.followers {
+		delete(b.followers, ch)
+		close(ch)
+	}
+}
+
+// lastLines returns at most n trailing lines of data.
+func lastLines(data []byte, n int) []string {
+	text := strings.TrimRight(string(data), "\n")
+	if text == "" {
+		return []string{}
+	}
+	lines := strings.Split(text, "\n")
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// jobLogPath returns where a job's output is stored on disk.
+func (e *Executor) jobLogPath(jobID int) string {
+	return filepath.Join(e.cfg.LogPath, "jobs", fmt.Sprintf("job_%d.log", jobID))
+}
+
+// jobLog receives a job attempt's output and the agent's notes about it,
+// tagged by phase, and feeds both the in-memory ring used for live tails and
+// the log file.
+type jobLog struct {
+	*phaseWriter
+	buf   *logBuffer
+	file  *os.File
+	path  string
+	sinks []*sinkWriter
+}
+
+// openJobLog starts a new log for a job attempt, teeing it to the configured
+// output sinks. The log is written uncompressed while the job runs and
+// compressed by closeJobLog. Retried jobs keep every attempt's output in the
+// one file, each under a header naming the attempt.
+func (e *Executor) openJobLog(job client.Job, attempt int) *jobLog {
+	jobID := job.ID
+	l := &jobLog{
+		buf:   newLogBuffer(max(e.cfg.JobLogBufferKB, 1) * 1024),
+		path:  e.jobLogPath(jobID),
+		sinks: e.openSinks(job, attempt),
+	}
+
+	writers := []io.Writer{l.buf}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err == nil {
+		if f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+			l.file = f
+			writers = append(writers, f)
+			if attempt > 0 {
+				fmt.Fprintf(f, "=== attempt %d ===\n", attempt)
+			}
+		} else {
+			slog.Warn("Failed to create job log file", "error", err)
+		}
+	}
+	for _, sink := range l.sinks {
+		writers = append(writers, sink)
+	}
+	l.phaseWriter = newPhaseWriter(io.MultiWriter(writers...))
+
+	e.mu.Lock()
+	e.jobLogs[jobID] = l
+	e.mu.Unlock()
+
+	return l
+}
+
+// closeJobLog ends live tails of a job's log and compresses the file.
+func (e *Executor) closeJobLog(jobID int, l *jobLog) {
+	e.mu.Lock()
+	delete(e.jobLogs, jobID)
+	e.mu.Unlock()
+
+	l.buf.Close()
+	for _, sink := range l.sinks {
+		sink.Close()
+	}
+	if l.file != nil {
+		l.file.Close()
+		if err := compressLog(l.path); err != nil {
+			slog.Warn("Failed to compress job log", "error", err)
+		}
+	}
+}
+
+// logPhase adds an agent message to a running job's log.
+func (e *Executor) logPhase(jobID int, phase, format string, args ...any) {
+	e.mu.Lock()
+	l := e.jobLogs[jobID]
+	e.mu.Unlock()
+
+	if l != nil {
+		l.Printf(phase, format, args...)
+	}
+}
+
+// setupOutput returns a writer for tool output during a job's setup, such as
+// an image pull.
+func (e *Executor) setupOutput(jobID int) io.Writer {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if l := e.jobLogs[jobID]; l != nil {
+		return l
+	}
+	return io.Discard
+}
+
+// runCommand runs cmd, capturing its combined output while also feeding the
+// job's log, and converts the outcome into a JobResult.
+func (e *Executor) runCommand(ctx context.Context, job client.Job, cmd *exec.Cmd) JobResult {
+	jobID := job.ID
+
+	e.mu.Lock()
+	l := e.jobLogs[jobID]
+	e.mu.Unlock()
+
+	var output bytes.Buffer
+	var sink io.Writer = &output
+	if l != nil {
+		sink = io.MultiWriter(&output, l)
+		l.SetPhase(PhaseJob)
+		defer l.SetPhase(PhaseTeardown)
+	}
+
+	// Guard against jobs flooding the agent with output
+	var onRunaway func()
+	if e.cfg.JobOutputKillRunaway {
+		onRunaway = func() {
+			slog.Warn("Job exceeded the output rate limit, killing it", "job_id", jobID)
+			signalGroup(cmd, syscall.SIGKILL)
+		}
+	}
+	guard := newOutputGuard(sink, int64(e.cfg.JobOutputRateLimitKB)*1024, e.cfg.JobOutputRateWindow, onRunaway)
+
+	done := make(chan struct{})
+
+	e.mu.Lock()
+	e.jobOutput[jobID] = guard
+	e.jobDone[jobID] = done
+	e.mu.Unlock()
+
+	defer func() {
+		close(done)
+		e.mu.Lock()
+		delete(e.jobOutput, jobID)
+		delete(e.jobDone, jobID)
+		e.mu.Unlock()
+	}()
+
+	cmd.Stdout = guard
+	cmd.Stderr = guard
+
+	// Timeouts and cancellation reach the shell's children too
+	group := newProcessGroup(cmd)
+
+	// Don't hang on pipes held open by orphaned children after the shell exits
+	cmd.WaitDelay = killGracePeriod
+
+	start := time.Now()
+	err := cmd.Start()
+	started := err == nil
+	var peakMemoryMB int
+	var avgCPUPercent float64
+	if started {
+		e.journal.Add(jobID, cmd.Process.Pid)
+		probe := e.startReadinessProbe(job, cmd)
+		var usage *usageSampler
+		if job.Environment == "docker" {
+			usage = startContainerSampler(e.containerOf(jobID))
+		} else {
+			usage = startUsageSampler(cmd.Process.Pid)
+		}
+		err = cmd.Wait()
+		peakMemoryMB, avgCPUPercent = usage.Stop()
+		group.Finish()
+		e.journal.Remove(jobID)
+		probe.Stop()
+		if peakMemoryMB > 0 || avgCPUPercent > 0 {
+			e.logPhase(jobID, PhaseTeardown, "peak memory %d MB, average CPU %.1f%%", peakMemoryMB, avgCPUPercent)
+		}
+		if probe.Failed() {
+			e.logPhase(jobID, PhaseTeardown, "stopped: not ready within %v", probe.timeout)
+			return JobResult{
+				ExitCode:      -1,
+				ErrorMessage:  fmt.Sprintf("job not ready within %v", probe.timeout),
+				PeakMemoryMB:  peakMemoryMB,
+				AvgCPUPercent: avgCPUPercent,
+				output:        output.Bytes(),
+				ran:           true,
+			}
+		}
+	}
+	if err != nil {
+		exitCode := -1
+		if exitError, ok := err.(*exec.ExitError); ok {
+			exitCode = exitError.ExitCode()
+		}
+		// A process that exits on its own just as the deadline fires keeps
+		// its own result; only one still running when the deadline passed
+		// (and so signalled by it) counts as timed out.
+		timedOut := started && group.Terminated() && errors.Is(ctx.Err(), context.DeadlineExceeded)
+		errMsg := truncate(output.String(), 1000)
+		if timedOut {
+			errMsg = fmt.Sprintf("job timed out after %v", time.Since(start).Round(time.Second))
+		} else if guard.Killed() {
+			errMsg = "job killed: output rate limit exceeded"
+		} else if errMsg == "" {
+			errMsg = err.Error()
+		}
+		if timedOut {
+			e.logPhase(jobID, PhaseTeardown, "command timed out after %v", time.Since(start).Round(time.Second))
+		} else if started {
+			e.logPhase(jobID, PhaseTeardown, "command failed after %v: %v", time.Since(start).Round(time.Second), err)
+		}
+		return JobResult{
+			ExitCode:      exitCode,
+			ErrorMessage:  errMsg,
+			TimedOut:      timedOut,
+			PeakMemoryMB:  peakMemoryMB,
+			AvgCPUPercent: avgCPUPercent,
+			output:        output.Bytes(),
+			ran:           started,
+		}
+	}
+
+	e.logPhase(jobID, PhaseTeardown, "command succeeded after %v", time.Since(start).Round(time.Second))
+	return JobResult{
+		ExitCode:      0,
+		PeakMemoryMB:  peakMemoryMB,
+		AvgCPUPercent: avgCPUPercent,
+		output:        output.Bytes(),
+		ran:           true,
+	}
+}
+
+// OutputStats returns the output counters of a running job.
+func (e *Executor) OutputStats(jobID int) (OutputStats, bool) {
+	e.mu.Lock()
+	guard, ok := e.jobOutput[jobID]
+	e.mu.Unlock()
+
+	if !ok {
+		return OutputStats{}, false
+	}
+	return guard.Stats(), true
+}
+
+// TailLogs returns the last n lines of a job's output. Running jobs are served
+// from memory, finished jobs from their log file.
+func (e *Executor) TailLogs(jobID, n int) ([]string, bool) {
+	e.mu.Lock()
+	l, running := e.jobLogs[jobID]
+	e.mu.Unlock()
+
+	if running {
+		return lastLines(l.buf.Bytes(), n), true
+	}
+
+	// Only keep as much of the file as the in-memory buffer would hold
+	limit := max(e.cfg.JobLogBufferKB, 1) * 1024
+	data, err := e.readLogTail(jobID, limit)
+	if err != nil {
+		return nil, false
+	}
+
+	return lastLines(data, n), true
+}
+
+// readLogTail returns up to limit trailing bytes of a finished job's log,
+// reading the compressed log or, for older jobs, the plain one.
+func (e *Executor) readLogTail(jobID, limit int) ([]byte, error) {
+	if f, err := os.Open(e.jobLogPath(jobID) + ".gz"); err == nil {
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		tail := newLogBuffer(limit)
+		if _, err := io.Copy(tail, zr); err != nil {
+			return nil, err
+		}
+		return tail.Bytes(), nil
+	}
+
+	f, err := os.Open(e.jobLogPath(jobID))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > int64(limit) {
+		f.Seek(info.Size()-int64(limit), io.SeekStart)
+	}
+	return io.ReadAll(f)
+}
+
+// OpenLog opens a finished job's complete log. When gzipped is true the
+// reader yields the compressed bytes as stored.
+func (e *Executor) OpenLog(jobID int) (rc io.ReadCloser, gzipped bool, err error) {
+	if f, err := os.Open(e.jobLogPath(jobID) + ".gz"); err == nil {
+		return f, true, nil
+	}
+	f, err := os.Open(e.jobLogPath(jobID))
+	if err != nil {
+		return nil, false, err
+	}
+	return f, false, nil
+}
+
+// compressLog replaces a log file with a gzipped copy. If an earlier attempt
+// was already compressed, the file is added to it as another gzip member,
+// which readers see as one continuous stream.
+func compressLog(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if prev, perr := os.Open(path + ".gz"); perr == nil {
+		_, err = io.Copy(dst, prev)
+		prev.Close()
+	}
+	zw := gzip.NewWriter(dst)
+	if err == nil {
+		_, err = io.Copy(zw, src)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// PurgeJobLogs deletes finished job logs older than the configured retention
+// and returns how many were removed.
+func (e *Executor) PurgeJobLogs() int {
+	if e.cfg.JobLogRetentionDays <= 0 {
+		return 0
+	}
+	retention := time.Duration(e.cfg.JobLogRetentionDays) * 24 * time.Hour
+
+	dir := filepath.Join(e.cfg.LogPath, "jobs")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		// Only compressed logs belong to finished jobs
+		if !strings.HasSuffix(entry.Name(), ".log.gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < retention {
+			continue
+		}
+		if os.Remove(filepath.Join(dir, entry.Name())) == nil {
+			purged++
+		}
+	}
+	return purged
+}
+
+// FollowLogs subscribes to new output of a running job.
+func (e *Executor) FollowLogs(jobID int) (<-chan []byte, func(), bool) {
+	e.mu.Lock()
+	l, running := e.jobLogs[jobID]
+	e.mu.Unlock()
+
+	if !running {
+		return nil, nil, false
+	}
+
+	ch, cancel := l.buf.Follow()
+	return ch, cancel, true
+}
@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+// A retried job's log keeps every attempt, not just the last one.
+func TestJobLogKeepsEveryAttempt(t *testing.T) {
+	e := &Executor{
+		cfg:     &config.Config{LogPath: t.TempDir(), JobLogBufferKB: 64},
+		jobLogs: make(map[int]*jobLog),
+	}
+	job := client.Job{ID: 7, MaxAttempts: 3}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		l := e.openJobLog(job, attempt)
+		l.Printf(PhaseSetup, "output of attempt %d", attempt)
+		e.closeJobLog(job.ID, l)
+	}
+
+	rc, gzipped, err := e.OpenLog(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if !gzipped {
+		t.Fatal("finished job log was not compressed")
+	}
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := string(data)
+	for _, want := range []string{
+		"=== attempt 1 ===", "output of attempt 1",
+		"=== attempt 2 ===", "output of attempt 2",
+		"=== attempt 3 ===", "output of attempt 3",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("job log is missing %q:\n%s", want, log)
+		}
+	}
+	if _, err := os.Stat(e.jobLogPath(job.ID)); !os.IsNotExist(err) {
+		t.Errorf("uncompressed log left behind: %v", err)
+	}
+
+	tail, err := e.readLogTail(job.ID, 1024)
+	if err != nil || !strings.Contains(string(tail), "output of attempt 1") {
+		t.Errorf("readLogTail = %q, %v; want all attempts", tail, err)
+	}
+}
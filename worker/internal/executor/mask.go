@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
+)
+
+// secretKeyMarkers flag environment variable names whose values must not be logged.
+var secretKeyMarkers = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "KEY", "CREDENTIAL", "AUTH"}
+
+// isSecretKey reports whether an environment variable name looks sensitive.
+func isSecretKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range secretKeyMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskEnv formats environment variables for logging with secret values
+// hidden, along with the values of the hidden variables. Credentials in URL
+// values are hidden too.
+func maskEnv(env map[string]string, hidden ...string) string {
+	keys := slices.Sorted(maps.Keys(env))
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := env[k]
+		if isSecretKey(k) || slices.Contains(hidden, k) {
+			v = "****"
+		} else {
+			v = fileops.RedactURL(v)
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
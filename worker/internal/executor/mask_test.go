@@ -0,0 +1,25 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaskEnv(t *testing.T) {
+	env := map[string]string{
+		"HF_TOKEN":     "hf_s3cr3t",
+		"DATABASE_URL": "postgres://app:s3cr3t@db:5432/runs",
+		"PIP_INDEX":    "https://s3cr3t@pypi.example.com/simple",
+		"MODE":         "train",
+	}
+	masked := maskEnv(env)
+
+	if strings.Contains(masked, "s3cr3t") {
+		t.Errorf("maskEnv() = %q, leaks a secret", masked)
+	}
+	for _, want := range []string{"HF_TOKEN=****", "DATABASE_URL=postgres://app:xxxxx@db:5432/runs", "MODE=train"} {
+		if !strings.Contains(masked, want) {
+			t.Errorf("maskEnv() = %q, want it to contain %q", masked, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// maxPendingLogLines bounds lines held for the master while it is unreachable.
+const maxPendingLogLines = 10000
+
+// masterSink streams job output to the master's job log in batches while
+// the job runs.
+type masterSink struct {
+	client   *client.MasterClient
+	interval time.Duration
+	maxLines int
+}
+
+func (s *masterSink) Name() string { return "master" }
+
+func (s *masterSink) Open(job client.Job, _ int) (io.WriteCloser, error) {
+	w := &masterLogWriter{
+		sink:  s,
+		jobID: job.ID,
+		full:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// masterLogWriter collects complete lines and sends them every interval, or
+// sooner once maxLines are waiting. A single goroutine sends, so batches
+// arrive in order.
+type masterLogWriter struct {
+	sink  *masterSink
+	jobID int
+
+	mu      sync.Mutex
+	partial []byte
+	lines   []string
+	dropped int
+
+	full chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (w *masterLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.addLocked(string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+	ready := len(w.lines) >= w.sink.maxLines
+	w.mu.Unlock()
+
+	if ready {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// addLocked queues a line, dropping the oldest ones past the bound.
+func (w *masterLogWriter) addLocked(line string) {
+	w.lines = append(w.lines, line)
+	if over := len(w.lines) - maxPendingLogLines; over > 0 {
+		w.lines = w.lines[over:]
+		w.dropped += over
+	}
+}
+
+func (w *masterLogWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.sink.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.full:
+			w.flush()
+		case <-w.stop:
+			w.flush()
+			return
+		}
+	}
+}
+
+// flush sends the queued lines. On failure they are kept for the next try.
+func (w *masterLogWriter) flush() {
+	w.mu.Lock()
+	lines := w.lines
+	if w.dropped > 0 {
+		lines = append([]string{fmt.Sprintf("[agent] %d log lines dropped while the master was unreachable", w.dropped)}, lines...)
+	}
+	w.lines = nil
+	dropped := w.dropped
+	w.dropped = 0
+	w.mu.Unlock()
+
+	if len(lines) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := w.sink.client.AppendJobLog(ctx, w.jobID, lines); err != nil {
+		w.mu.Lock()
+		if dropped > 0 {
+			lines = lines[1:]
+		}
+		w.lines = append(lines, w.lines...)
+		w.dropped += dropped
+		if over := len(w.lines) - maxPendingLogLines; over > 0 {
+			w.lines = w.lines[over:]
+			w.dropped += over
+		}
+		w.mu.Unlock()
+	}
+}
+
+// Close sends what is left, including an unterminated last line. The last
+// send gets its own timeout, so output is delivered even when the job was
+// cancelled.
+func (w *masterLogWriter) Close() error {
+	w.mu.Lock()
+	if len(w.partial) > 0 {
+		w.addLocked(string(w.partial))
+		w.partial = nil
+	}
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if n := len(w.lines) + w.dropped; n > 0 {
+		return fmt.Errorf("%d log lines not delivered to the master", n)
+	}
+	return nil
+}
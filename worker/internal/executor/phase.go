@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Job log phases. Each line of a job's log is tagged with the phase that
+// wrote it, e.g. "[setup] pulling image" or "[job] epoch 1/10".
+const (
+	PhaseSetup    = "setup"    // environment preparation before the command
+	PhaseJob      = "job"      // the job's own output
+	PhaseTeardown = "teardown" // what happened after the command exited
+)
+
+// phaseWriter prefixes every line written through it with the current phase.
+type phaseWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	phase   string
+	midLine bool // the last write didn't end with a newline
+}
+
+func newPhaseWriter(w io.Writer) *phaseWriter {
+	return &phaseWriter{w: w, phase: PhaseSetup}
+}
+
+// SetPhase switches the tag for following output, ending any partial line.
+func (p *phaseWriter) SetPhase(phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setPhaseLocked(phase)
+}
+
+func (p *phaseWriter) setPhaseLocked(phase string) {
+	if p.midLine && phase != p.phase {
+		p.w.Write([]byte("\n"))
+		p.midLine = false
+	}
+	p.phase = phase
+}
+
+// Write tags each line of data with the current phase.
+func (p *phaseWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.writeLocked(data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (p *phaseWriter) writeLocked(data []byte) error {
+	var out bytes.Buffer
+	for len(data) > 0 {
+		if !p.midLine {
+			out.WriteString("[" + p.phase + "] ")
+		}
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		out.Write(line)
+		data = data[len(line):]
+		p.midLine = line[len(line)-1] != '\n'
+	}
+	_, err := p.w.Write(out.Bytes())
+	return err
+}
+
+// Printf writes an agent message as a line in the given phase, without
+// changing the phase of following output.
+func (p *phaseWriter) Printf(phase, format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prev := p.phase
+	p.setPhaseLocked(phase)
+	p.writeLocked([]byte(strings.TrimRight(fmt.Sprintf(format, args...), "\n") + "\n"))
+	p.phase = prev
+}
+
+// SplitPhase separates a log line's phase tag from its text. Lines without a
+// known tag, such as those of logs written before tagging, have no phase.
+func SplitPhase(line string) (phase, text string) {
+	for _, ph := range []string{PhaseSetup, PhaseJob, PhaseTeardown} {
+		if rest, ok := strings.CutPrefix(line, "["+ph+"] "); ok {
+			return ph, rest
+		}
+	}
+	return "", line
+}
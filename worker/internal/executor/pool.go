@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// Go runs a job in the background if the pool (AGENT_MAX_CONCURRENT_JOBS)
+// has a free slot, and calls done with the result before freeing the slot.
+// It returns false when the pool is full; the job stays queued on the master.
+func (e *Executor) Go(ctx context.Context, job client.Job, done func(JobResult)) bool {
+	select {
+	case e.slots <- struct{}{}:
+	default:
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	e.mu.Lock()
+	e.active[job.ID] = cancel
+	e.mu.Unlock()
+	e.wg.Add(1)
+
+	go func() {
+		defer func() {
+			cancel()
+			e.mu.Lock()
+			delete(e.active, job.ID)
+			delete(e.cancelled, job.ID)
+			e.mu.Unlock()
+			<-e.slots
+			e.wg.Done()
+		}()
+
+		done(e.Execute(ctx, job))
+	}()
+	return true
+}
+
+// IsActive reports whether a job has been dispatched and not yet reported.
+// Jobs stay in the master's queue until their status changes, so the same
+// job can show up in the next poll.
+func (e *Executor) IsActive(jobID int) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.active[jobID]
+	return ok
+}
+
+// ActiveCount returns how many dispatched jobs have not been reported yet.
+func (e *Executor) ActiveCount() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.active)
+}
+
+// FreeSlots returns how many more jobs the pool can take.
+func (e *Executor) FreeSlots() int {
+	return cap(e.slots) - len(e.slots)
+}
+
+// Wait blocks until every dispatched job has finished and been reported, or
+// ctx is done.
+func (e *Executor) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
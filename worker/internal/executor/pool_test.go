@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+// A job can be cancelled before its command starts, here while it waits
+// for its start slot.
+func TestCancelJobBeforeStart(t *testing.T) {
+	e := NewExecutor(&config.Config{
+		MaxConcurrentJobs: 1,
+		JobStartStaggerMS: int(time.Hour / time.Millisecond),
+		JobsWorkspace:     t.TempDir(),
+		EnvCacheDir:       t.TempDir(),
+	}, nil)
+	e.lastStart = time.Now()
+
+	results := make(chan JobResult, 1)
+	if !e.Go(context.Background(), client.Job{ID: 1, Command: "true"}, func(r JobResult) { results <- r }) {
+		t.Fatal("Go refused a job with a free slot")
+	}
+	if !e.CancelJob(1) {
+		t.Fatal("CancelJob(1) = false for a job waiting to start")
+	}
+
+	select {
+	case r := <-results:
+		if !r.Cancelled || r.Status() != "cancelled" {
+			t.Errorf("result = %+v, want a cancelled job", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled job did not finish")
+	}
+
+	if err := e.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if e.CancelJob(1) {
+		t.Error("CancelJob(1) = true for a job that already finished")
+	}
+	if len(e.cancelled) != 0 {
+		t.Errorf("cancel marks left behind: %v", e.cancelled)
+	}
+}
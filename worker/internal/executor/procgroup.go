@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// killGracePeriod is how long a job's processes get to exit after SIGTERM
+// before they are killed.
+const killGracePeriod = 10 * time.Second
+
+// processGroup runs a command as the leader of its own process group, so
+// children forked by the shell wrappers (python under conda activation, for
+// example) are signalled along with it.
+type processGroup struct {
+	cmd        *exec.Cmd
+	terminated atomic.Bool
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// newProcessGroup prepares cmd, before it starts, to run in a new process
+// group. When cmd's context is done the whole group gets SIGTERM, then
+// SIGKILL after the grace period.
+func newProcessGroup(cmd *exec.Cmd) *processGroup {
+	g := &processGroup{cmd: cmd}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		g.terminated.Store(true)
+		g.mu.Lock()
+		g.timer = time.AfterFunc(killGracePeriod, func() {
+			signalGroup(cmd, syscall.SIGKILL)
+		})
+		g.mu.Unlock()
+		return signalGroup(cmd, syscall.SIGTERM)
+	}
+	return g
+}
+
+// Terminated reports whether the group was signalled because the command's
+// context was done.
+func (g *processGroup) Terminated() bool {
+	return g.terminated.Load()
+}
+
+// Finish is called once cmd.Wait returns. A terminated group's stragglers
+// are killed now rather than when the grace timer fires, while the group
+// still exists and its ID can't have been reused.
+func (g *processGroup) Finish() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+		signalGroup(g.cmd, syscall.SIGKILL)
+	}
+}
+
+// signalGroup sends sig to the process group led by cmd, falling back to the
+// process alone if the group is gone.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err == nil {
+		return nil
+	}
+	return cmd.Process.Signal(sig)
+}
@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimitMarker is written in place of output dropped by the rate guard.
+const rateLimitMarker = "\n[output truncated, rate limit]\n"
+
+// outputGuard forwards job output while tracking its volume. When a job keeps
+// exceeding the byte rate limit for a sustained window, further output in each
+// over-limit second is dropped, or the job is killed as a runaway.
+type outputGuard struct {
+	w         io.Writer
+	limit     int64 // bytes per second, 0 disables the guard
+	window    int   // consecutive over-limit seconds before throttling
+	onRunaway func()
+
+	mu          sync.Mutex
+	total       int64
+	dropped     int64
+	second      time.Time
+	secondBytes int64
+	overSeconds int
+	counted     bool // current second already counted towards the streak
+	throttled   bool
+	marked      bool
+	killed      bool
+}
+
+func newOutputGuard(w io.Writer, limit int64, window int, onRunaway func()) *outputGuard {
+	return &outputGuard{
+		w:         w,
+		limit:     limit,
+		window:    max(window, 1),
+		onRunaway: onRunaway,
+	}
+}
+
+// Write forwards p unless the job is being throttled.
+func (g *outputGuard) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	g.total += int64(len(p))
+
+	if g.limit <= 0 {
+		g.mu.Unlock()
+		return g.w.Write(p)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	if !now.Equal(g.second) {
+		g.rollSecond(now)
+	}
+	g.secondBytes += int64(len(p))
+
+	if g.secondBytes > g.limit && !g.counted {
+		g.counted = true
+		g.overSeconds++
+		if g.overSeconds >= g.window && !g.throttled {
+			g.throttled = true
+			if g.onRunaway != nil && !g.killed {
+				g.killed = true
+				go g.onRunaway()
+			}
+		}
+	}
+
+	if g.throttled && g.secondBytes > g.limit {
+		g.dropped += int64(len(p))
+		mark := !g.marked
+		g.marked = true
+		g.mu.Unlock()
+
+		if mark {
+			g.w.Write([]byte(rateLimitMarker))
+		}
+		return len(p), nil
+	}
+	g.mu.Unlock()
+
+	return g.w.Write(p)
+}
+
+// rollSecond starts a new one-second bucket. The over-limit streak only
+// continues if the previous second was also over the limit.
+func (g *outputGuard) rollSecond(now time.Time) {
+	if g.secondBytes <= g.limit || now.Sub(g.second) > time.Second {
+		g.overSeconds = 0
+		g.throttled = false
+	}
+	g.second = now
+	g.secondBytes = 0
+	g.counted = false
+	g.marked = false
+}
+
+// OutputStats is a snapshot of a job's output volume.
+type OutputStats struct {
+	OutputBytes  int64 `json:"output_bytes"`
+	DroppedBytes int64 `json:"dropped_bytes"`
+	Throttled    bool  `json:"throttled"`
+}
+
+// Stats returns the current output counters.
+func (g *outputGuard) Stats() OutputStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return OutputStats{OutputBytes: g.total, DroppedBytes: g.dropped, Throttled: g.throttled}
+}
+
+// Killed reports whether the job was killed as a runaway.
+func (g *outputGuard) Killed() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.killed
+}
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// readinessProbe polls a service-style job's EnvConfig["readiness_command"]
+// after it starts. Success reports the job "ready"; no success within
+// readiness_timeout seconds (default 300) fails and stops the job.
+type readinessProbe struct {
+	failed  atomic.Bool
+	timeout time.Duration
+	stop    context.CancelFunc
+}
+
+// startReadinessProbe starts probing the job run by cmd, or returns nil when
+// the job has no readiness command. Call stop once the job has exited.
+func (e *Executor) startReadinessProbe(job client.Job, cmd *exec.Cmd) *readinessProbe {
+	command, ok := job.EnvConfig["readiness_command"].(string)
+	if !ok || command == "" {
+		return nil
+	}
+
+	timeout := 300 * time.Second
+	if t, ok := job.EnvConfig["readiness_timeout"].(float64); ok && t > 0 {
+		timeout = time.Duration(t * float64(time.Second))
+	}
+	interval := 5 * time.Second
+	if i, ok := job.EnvConfig["readiness_interval"].(float64); ok && i > 0 {
+		interval = time.Duration(i * float64(time.Second))
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	p := &readinessProbe{timeout: timeout, stop: stop}
+
+	go func() {
+		deadline := time.After(timeout)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return // The job exited first
+			case <-deadline:
+				slog.Warn("Job not ready in time, stopping it", "job_id", job.ID, "timeout", timeout.String())
+				p.failed.Store(true)
+				signalGroup(cmd, syscall.SIGKILL)
+				return
+			case <-ticker.C:
+			}
+
+			probeCtx, cancel := context.WithTimeout(ctx, interval)
+			probe := exec.CommandContext(probeCtx, "sh", "-c", command)
+			probe.Dir = cmd.Dir
+			probe.Env = cmd.Env
+			err := probe.Run()
+			cancel()
+
+			if err == nil {
+				slog.Info("Job is ready", "job_id", job.ID)
+				e.notifyStatus(job.ID, client.JobStatusUpdate{Status: "ready"})
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// Failed reports whether the job was stopped for not becoming ready.
+func (p *readinessProbe) Failed() bool {
+	return p != nil && p.failed.Load()
+}
+
+// Stop ends probing.
+func (p *readinessProbe) Stop() {
+	if p != nil {
+		p.stop()
+	}
+}
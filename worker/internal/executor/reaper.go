@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ZombieStats reports defunct child processes of the agent.
+type ZombieStats struct {
+	Current int
+	Reaped  int64
+}
+
+// RunReaper periodically reaps defunct children of the agent until ctx is
+// done. Orphaned grandchildren only become our children when the agent runs
+// as PID 1 (e.g. in a container), which is where they accumulate.
+func (e *Executor) RunReaper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.reapZombies()
+		}
+	}
+}
+
+// reapZombies waits on zombies that were already defunct at the previous scan.
+// Processes started through os/exec are waited on as soon as they exit, so a
+// zombie that survives a whole interval has nobody waiting for it.
+func (e *Executor) reapZombies() {
+	zombies := childZombies(os.Getpid())
+
+	e.mu.Lock()
+	for _, cmd := range e.runningJobs {
+		if cmd.Process != nil {
+			delete(zombies, cmd.Process.Pid)
+		}
+	}
+	previous := e.zombies
+	e.mu.Unlock()
+
+	var reaped int64
+	for pid, name := range zombies {
+		if _, seen := previous[pid]; !seen {
+			continue
+		}
+		var status syscall.WaitStatus
+		if n, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err == nil && n == pid {
+			slog.Warn("Reaped defunct process", "pid", pid, "name", name, "exit_status", status.ExitStatus())
+			delete(zombies, pid)
+			reaped++
+		}
+	}
+
+	e.mu.Lock()
+	e.zombies = zombies
+	e.zombiesReaped += reaped
+	e.mu.Unlock()
+
+	if len(zombies) > 0 {
+		slog.Warn("Defunct child processes pending", "count", len(zombies))
+	}
+}
+
+// ZombieStats returns the zombie count from the last scan and the total reaped.
+func (e *Executor) ZombieStats() ZombieStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return ZombieStats{Current: len(e.zombies), Reaped: e.zombiesReaped}
+}
+
+// childZombies lists defunct children of ppid, keyed by PID with their command name.
+func childZombies(ppid int) map[int]string {
+	zombies := make(map[int]string)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return zombies
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+
+		// Format: pid (comm) state ppid ...; comm may contain spaces and parens
+		stat := string(data)
+		open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+		if open < 0 || end < open {
+			continue
+		}
+		fields := strings.Fields(stat[end+1:])
+		if len(fields) < 2 || fields[0] != "Z" {
+			continue
+		}
+		if parent, err := strconv.Atoi(fields[1]); err == nil && parent == ppid {
+			zombies[pid] = stat[open+1 : end]
+		}
+	}
+
+	return zombies
+}
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// parseResults extracts metrics from a job's output according to
+// EnvConfig["result_parser"], which is either a regex string or an object:
+//
+//	{"regex": "acc=(?P<accuracy>[0-9.]+) loss=(?P<loss>[0-9.]+)"}
+//	{"json_paths": {"accuracy": "eval.acc", "loss": "eval.loss"}}
+//
+// Regex named groups become metrics, taking the last match in the output.
+// JSON paths are looked up in the last line of output that is a JSON object.
+func parseResults(job client.Job, output []byte) (map[string]any, error) {
+	spec, ok := job.EnvConfig["result_parser"]
+	if !ok {
+		return nil, nil
+	}
+
+	switch p := spec.(type) {
+	case string:
+		return parseRegexResults(p, output)
+	case map[string]any:
+		if pattern, ok := p["regex"].(string); ok {
+			return parseRegexResults(pattern, output)
+		}
+		if paths, ok := p["json_paths"].(map[string]any); ok {
+			return parseJSONResults(paths, output)
+		}
+	}
+	return nil, fmt.Errorf("result_parser needs a regex or json_paths")
+}
+
+// parseRegexResults applies pattern and returns its named groups from the last match.
+func parseRegexResults(pattern string, output []byte) (map[string]any, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid result_parser regex: %w", err)
+	}
+
+	matches := re.FindAllSubmatch(output, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("result_parser regex did not match the output")
+	}
+	last := matches[len(matches)-1]
+
+	results := make(map[string]any)
+	for i, name := range re.SubexpNames() {
+		if name != "" && i < len(last) {
+			results[name] = resultValue(string(last[i]))
+		}
+	}
+	return results, nil
+}
+
+// parseJSONResults looks up dotted paths in the last JSON object line.
+func parseJSONResults(paths map[string]any, output []byte) (map[string]any, error) {
+	var doc map[string]any
+	lines := bytes.Split(output, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := bytes.TrimSpace(lines[i])
+		if len(line) > 0 && line[0] == '{' && json.Unmarshal(line, &doc) == nil {
+			break
+		}
+		doc = nil
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("no JSON object line found in the output")
+	}
+
+	results := make(map[string]any)
+	for name, p := range paths {
+		path, ok := p.(string)
+		if !ok {
+			continue
+		}
+		var v any = doc
+		for _, key := range strings.Split(path, ".") {
+			m, ok := v.(map[string]any)
+			if !ok {
+				v = nil
+				break
+			}
+			v = m[key]
+		}
+		if v != nil {
+			results[name] = v
+		}
+	}
+	return results, nil
+}
+
+// resultValue returns numbers as floats and anything else as a string.
+func resultValue(s string) any {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// runtimeProbeTimeout bounds each runtime availability probe.
+const runtimeProbeTimeout = 10 * time.Second
+
+// jobRuntime returns the runtime a job's environment needs.
+func jobRuntime(job client.Job) string {
+	switch job.Environment {
+	case "docker", "conda", "venv":
+		return job.Environment
+	case "apptainer", "singularity":
+		return "apptainer"
+	default:
+		return "system"
+	}
+}
+
+// probeRuntime checks that the tools a runtime needs are usable.
+func (e *Executor) probeRuntime(ctx context.Context, runtime string, needConda bool) error {
+	ctx, cancel := context.WithTimeout(ctx, runtimeProbeTimeout)
+	defer cancel()
+
+	switch runtime {
+	case "docker":
+		if _, err := exec.LookPath("docker"); err != nil {
+			return errors.New("docker CLI not installed")
+		}
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "docker", "info", "--format", "{{.ServerVersion}}")
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = err.Error()
+			}
+			return fmt.Errorf("docker daemon unreachable: %s", truncate(msg, 300))
+		}
+	case "apptainer":
+		if _, err := exec.LookPath("apptainer"); err != nil {
+			return errors.New("apptainer not installed")
+		}
+	case "conda":
+		if _, err := exec.LookPath(e.cfg.JobShellConda); err != nil {
+			return fmt.Errorf("shell %q not installed", e.cfg.JobShellConda)
+		}
+		if _, err := exec.LookPath("conda"); needConda && err != nil {
+			return errors.New("conda not installed")
+		}
+	case "venv":
+		if _, err := exec.LookPath(e.cfg.JobShellVenv); err != nil {
+			return fmt.Errorf("shell %q not installed", e.cfg.JobShellVenv)
+		}
+	default:
+		if _, err := exec.LookPath(e.cfg.JobShellSystem); err != nil {
+			return fmt.Errorf("shell %q not installed", e.cfg.JobShellSystem)
+		}
+	}
+	return nil
+}
+
+// checkRuntime verifies a job's runtime before it runs and records whether
+// the runtime is available, so heartbeats can tell the master to stop
+// scheduling jobs that need it.
+func (e *Executor) checkRuntime(ctx context.Context, job client.Job) error {
+	runtime := jobRuntime(job)
+	packURL, _ := job.EnvConfig["conda_pack_url"].(string)
+
+	err := e.probeRuntime(ctx, runtime, packURL == "")
+	e.setRuntimeState(runtime, err)
+	if err != nil {
+		return fmt.Errorf("%s runtime unavailable on this node: %v", runtime, err)
+	}
+	return nil
+}
+
+// setRuntimeState records a runtime probe result, logging changes.
+func (e *Executor) setRuntimeState(runtime string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, wasDown := e.runtimesDown[runtime]
+	switch {
+	case err != nil:
+		if !wasDown {
+			slog.Warn("Runtime unavailable, reporting it to the master", "runtime", runtime, "error", err)
+		}
+		e.runtimesDown[runtime] = err.Error()
+	case wasDown:
+		slog.Info("Runtime available again", "runtime", runtime)
+		delete(e.runtimesDown, runtime)
+	}
+}
+
+// UnavailableRuntimes re-probes runtimes that failed earlier and returns the
+// ones still unavailable, with the reason.
+func (e *Executor) UnavailableRuntimes() map[string]string {
+	e.mu.Lock()
+	down := maps.Clone(e.runtimesDown)
+	e.mu.Unlock()
+
+	for runtime := range down {
+		// conda-pack jobs work without conda, but a conda probe wants the real thing
+		e.setRuntimeState(runtime, e.probeRuntime(context.Background(), runtime, true))
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return maps.Clone(e.runtimesDown)
+}
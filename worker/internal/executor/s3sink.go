@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+// s3UploadTimeout bounds uploading one attempt's output.
+const s3UploadTimeout = 5 * time.Minute
+
+// s3Sink uploads each attempt's output as an object once the attempt ends.
+// Uploads run in the background so a slow store doesn't hold up the job's
+// final status; Wait lets them finish at shutdown. Requests are signed with
+// AWS Signature V4 and use path-style URLs, which AWS and MinIO both accept.
+type s3Sink struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	prefix    string
+	nodeName  string
+	tmpDir    string
+	client    *http.Client
+
+	ctx     context.Context // cancelled to abort uploads still running at exit
+	abort   context.CancelFunc
+	uploads sync.WaitGroup
+}
+
+func newS3Sink(cfg *config.Config) (*s3Sink, error) {
+	if cfg.JobOutputS3Endpoint == "" || cfg.JobOutputS3Bucket == "" {
+		return nil, errors.New("the s3 job output sink needs AGENT_JOB_OUTPUT_S3_ENDPOINT and AGENT_JOB_OUTPUT_S3_BUCKET")
+	}
+	endpoint, err := url.Parse(cfg.JobOutputS3Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid AGENT_JOB_OUTPUT_S3_ENDPOINT %q", cfg.JobOutputS3Endpoint)
+	}
+
+	ctx, abort := context.WithCancel(context.Background())
+	return &s3Sink{
+		endpoint:  endpoint,
+		bucket:    cfg.JobOutputS3Bucket,
+		region:    cfg.JobOutputS3Region,
+		accessKey: cfg.JobOutputS3AccessKey,
+		secretKey: cfg.JobOutputS3SecretKey,
+		prefix:    strings.Trim(cfg.JobOutputS3Prefix, "/"),
+		nodeName:  cfg.NodeName,
+		tmpDir:    os.TempDir(),
+		client:    &http.Client{Timeout: s3UploadTimeout},
+		ctx:       ctx,
+		abort:     abort,
+	}, nil
+}
+
+func (s *s3Sink) Name() string { return "s3" }
+
+// Open buffers the attempt's output in a temp file, uploaded on Close as
+// <prefix>/<node>/job_<id>/output.log, or attempt_<n>.log for retried jobs.
+func (s *s3Sink) Open(job client.Job, attempt int) (io.WriteCloser, error) {
+	f, err := os.CreateTemp(s.tmpDir, "job-output-*")
+	if err != nil {
+		return nil, err
+	}
+	name := "output.log"
+	if attempt > 0 {
+		name = fmt.Sprintf("attempt_%d.log", attempt)
+	}
+	key := fmt.Sprintf("%s/job_%d/%s", s.nodeName, job.ID, name)
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return &s3Object{sink: s, jobID: job.ID, key: key, file: f, hash: sha256.New()}, nil
+}
+
+// Wait waits for uploads in progress. When ctx is done first, the remaining
+// uploads are aborted and ctx's error is returned.
+func (s *s3Sink) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.uploads.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+type s3Object struct {
+	sink  *s3Sink
+	jobID int
+	key   string
+	file  *os.File
+	hash  hash.Hash
+	size  int64
+}
+
+func (o *s3Object) Write(p []byte) (int, error) {
+	n, err := o.file.Write(p)
+	o.hash.Write(p[:n])
+	o.size += int64(n)
+	return n, err
+}
+
+// Close starts uploading the buffered output; failures are logged.
+func (o *s3Object) Close() error {
+	if _, err := o.file.Seek(0, io.SeekStart); err != nil {
+		o.file.Close()
+		os.Remove(o.file.Name())
+		return err
+	}
+
+	o.sink.uploads.Add(1)
+	go func() {
+		defer o.sink.uploads.Done()
+		defer os.Remove(o.file.Name())
+		defer o.file.Close()
+
+		ctx, cancel := context.WithTimeout(o.sink.ctx, s3UploadTimeout)
+		defer cancel()
+		if err := o.sink.put(ctx, o.key, o.file, o.size, hex.EncodeToString(o.hash.Sum(nil))); err != nil {
+			slog.Warn("Failed to upload job output", "job_id", o.jobID, "key", o.key, "error", err)
+		}
+	}()
+	return nil
+}
+
+// put uploads an object with a signed PUT request.
+func (s *s3Sink) put(ctx context.Context, key string, body io.Reader, size int64, payloadHash string) error {
+	u := *s.endpoint
+	u.Path = "/" + s3Escape(s.bucket) + "/" + s3Escape(key)
+	u.RawPath = u.Path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	s.sign(req, u.Path, payloadHash, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// sign adds AWS Signature V4 headers to req.
+func (s *s3Sink) sign(req *http.Request, path, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		"", // no query
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape percent-encodes a path as SigV4 expects: everything but
+// unreserved characters and "/".
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+func newTestS3Sink(t *testing.T, handler http.HandlerFunc) *s3Sink {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	sink, err := newS3Sink(&config.Config{
+		JobOutputS3Endpoint: srv.URL,
+		JobOutputS3Bucket:   "jobs",
+		JobOutputS3Region:   "us-east-1",
+		NodeName:            "node-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.tmpDir = t.TempDir()
+	return sink
+}
+
+func writeS3Object(t *testing.T, sink *s3Sink, data string) io.WriteCloser {
+	t.Helper()
+	w, err := sink.Open(client.Job{ID: 3}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// Closing an attempt's output must not wait for the upload.
+func TestS3SinkUploadsInBackground(t *testing.T) {
+	release := make(chan struct{})
+	got := make(chan string, 1)
+	sink := newTestS3Sink(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		body, _ := io.ReadAll(r.Body)
+		got <- r.URL.Path + " " + string(body)
+	})
+
+	w := writeS3Object(t, sink, "hello")
+	start := time.Now()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Close blocked for %v on a stalled upload", d)
+	}
+
+	close(release)
+	if err := sink.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/jobs/node-1/job_3/output.log hello"; <-got != want {
+		t.Errorf("upload did not arrive as %q", want)
+	}
+}
+
+// Uploads still running when the shutdown bound passes are aborted.
+func TestS3SinkWaitAbortsAtDeadline(t *testing.T) {
+	stalled := make(chan struct{})
+	sink := newTestS3Sink(t, func(w http.ResponseWriter, r *http.Request) {
+		<-stalled
+	})
+	t.Cleanup(func() { close(stalled) }) // Runs before the server is closed
+
+	if err := writeS3Object(t, sink, "hello").Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := sink.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// secretScheme prefixes job variable values that name a file in the
+// secrets directory (AGENT_SECRETS_DIR) instead of holding the value.
+const secretScheme = "secret://"
+
+// resolveSecrets returns env with secret references replaced by the
+// secrets' contents. Secret files must be readable by their owner only; a
+// reference to a missing secret fails the job.
+func (e *Executor) resolveSecrets(env map[string]string) (map[string]string, error) {
+	var resolved map[string]string
+	for k, v := range env {
+		name, ok := strings.CutPrefix(v, secretScheme)
+		if !ok {
+			continue
+		}
+		value, err := e.readSecret(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		if resolved == nil {
+			resolved = maps.Clone(env)
+		}
+		resolved[k] = value
+	}
+
+	if resolved == nil {
+		return env, nil
+	}
+	return resolved, nil
+}
+
+// readSecret reads a secret from the secrets directory.
+func (e *Executor) readSecret(name string) (string, error) {
+	if e.cfg.SecretsDir == "" {
+		return "", fmt.Errorf("secret %q referenced but no secrets directory is configured (AGENT_SECRETS_DIR)", name)
+	}
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+
+	path := filepath.Join(e.cfg.SecretsDir, name)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("unknown secret %q", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %q: %w", name, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("secret %q is not a regular file", name)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("secret %q must only be readable by its owner (mode 0600), has %04o", name, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %q: %w", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// writeEnvFile writes env to a file readable by the agent only, for docker
+// run --env-file, so values stay off the command line. The caller removes
+// it after the run.
+func (e *Executor) writeEnvFile(jobID int, env map[string]string) (string, error) {
+	var b strings.Builder
+	for k, v := range env {
+		if strings.ContainsAny(v, "\r\n") {
+			return "", fmt.Errorf("environment variable %s has a multi-line value, which docker --env-file can't pass", k)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k, v)
+	}
+
+	f, err := os.CreateTemp(e.cfg.JobsWorkspace, fmt.Sprintf(".env_%d_*", jobID))
+	if err != nil {
+		return "", fmt.Errorf("failed to create env file: %w", err)
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write env file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write env file: %w", err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+// JobOutputSink is a destination for job output besides the agent's own job
+// log. Each attempt (0 for jobs without retries) gets its own writer, which receives the phase-tagged log
+// lines and is closed when the attempt ends.
+type JobOutputSink interface {
+	Name() string
+	Open(job client.Job, attempt int) (io.WriteCloser, error)
+}
+
+// NewOutputSinks creates the sinks named in AGENT_JOB_OUTPUT_SINKS:
+//
+//	master   the master's job log, streamed while the job runs (the default)
+//	file     plain files under AGENT_JOB_OUTPUT_DIR
+//	stdout   the agent's stdout, for local debugging
+//	discard  nowhere
+//	s3       an S3-compatible object store (AWS, MinIO)
+func NewOutputSinks(cfg *config.Config, mc *client.MasterClient) ([]JobOutputSink, error) {
+	var sinks []JobOutputSink
+	for _, name := range cfg.JobOutputSinks {
+		switch name {
+		case "master":
+			sinks = append(sinks, &masterSink{
+				client:   mc,
+				interval: time.Duration(max(cfg.JobLogFlushInterval, 1)) * time.Second,
+				maxLines: max(cfg.JobLogFlushLines, 1),
+			})
+		case "discard":
+			sinks = append(sinks, discardSink{})
+		case "stdout":
+			sinks = append(sinks, &stdoutSink{})
+		case "file":
+			dir := cfg.JobOutputDir
+			if dir == "" {
+				dir = filepath.Join(cfg.LogPath, "output")
+			}
+			sinks = append(sinks, fileSink{dir: dir})
+		case "s3":
+			sink, err := newS3Sink(cfg)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, fmt.Errorf("unknown job output sink %q (want master, file, stdout, discard or s3)", name)
+		}
+	}
+	return sinks, nil
+}
+
+// sinkWaiter is implemented by sinks that keep delivering an attempt's
+// output after its writer is closed.
+type sinkWaiter interface {
+	Wait(ctx context.Context) error
+}
+
+// WaitOutputSinks waits for output of finished jobs still being delivered,
+// aborting the delivery when ctx is done.
+func (e *Executor) WaitOutputSinks(ctx context.Context) error {
+	e.mu.Lock()
+	sinks := e.sinks
+	e.mu.Unlock()
+
+	var errs []error
+	for _, sink := range sinks {
+		if w, ok := sink.(sinkWaiter); ok {
+			if err := w.Wait(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetOutputSinks sets where job output is sent besides the job log.
+func (e *Executor) SetOutputSinks(sinks []JobOutputSink) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sinks = sinks
+}
+
+// openSinks opens every sink for a job attempt. A sink that fails to open is
+// skipped; the job runs regardless.
+func (e *Executor) openSinks(job client.Job, attempt int) []*sinkWriter {
+	e.mu.Lock()
+	sinks := e.sinks
+	e.mu.Unlock()
+
+	var writers []*sinkWriter
+	for _, sink := range sinks {
+		w, err := sink.Open(job, attempt)
+		if err != nil {
+			slog.Warn("Output sink unavailable", "job_id", job.ID, "sink", sink.Name(), "error", err)
+			continue
+		}
+		writers = append(writers, &sinkWriter{name: sink.Name(), jobID: job.ID, w: w})
+	}
+	return writers
+}
+
+// sinkWriter keeps a failing sink from interrupting the job: the first
+// error is logged and later output to that sink is dropped.
+type sinkWriter struct {
+	name   string
+	jobID  int
+	w      io.WriteCloser
+	failed bool
+}
+
+func (s *sinkWriter) Write(p []byte) (int, error) {
+	if !s.failed {
+		if _, err := s.w.Write(p); err != nil {
+			slog.Warn("Output sink failed, dropping its output", "job_id", s.jobID, "sink", s.name, "error", err)
+			s.failed = true
+		}
+	}
+	return len(p), nil
+}
+
+func (s *sinkWriter) Close() {
+	if err := s.w.Close(); err != nil {
+		slog.Warn("Output sink error", "job_id", s.jobID, "sink", s.name, "error", err)
+	}
+}
+
+// discardSink drops all output.
+type discardSink struct{}
+
+func (discardSink) Name() string { return "discard" }
+
+func (discardSink) Open(client.Job, int) (io.WriteCloser, error) {
+	return nopWriteCloser{io.Discard}, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// stdoutSink prints output to the agent's stdout, each line prefixed with
+// the job ID. Lines of concurrent jobs are kept whole.
+type stdoutSink struct {
+	mu sync.Mutex
+}
+
+func (s *stdoutSink) Name() string { return "stdout" }
+
+func (s *stdoutSink) Open(job client.Job, _ int) (io.WriteCloser, error) {
+	return &stdoutWriter{sink: s, prefix: fmt.Sprintf("[job %d] ", job.ID)}, nil
+}
+
+type stdoutWriter struct {
+	sink    *stdoutSink
+	prefix  string
+	pending []byte
+}
+
+func (w *stdoutWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.print(w.pending[:i+1])
+		w.pending = w.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *stdoutWriter) print(line []byte) {
+	w.sink.mu.Lock()
+	defer w.sink.mu.Unlock()
+	os.Stdout.WriteString(w.prefix)
+	os.Stdout.Write(line)
+}
+
+func (w *stdoutWriter) Close() error {
+	if len(w.pending) > 0 {
+		w.print(append(w.pending, '\n'))
+		w.pending = nil
+	}
+	return nil
+}
+
+// fileSink appends each job's output to dir/job_<id>.log, across attempts.
+type fileSink struct {
+	dir string
+}
+
+func (fileSink) Name() string { return "file" }
+
+func (s fileSink) Open(job client.Job, attempt int) (io.WriteCloser, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.dir, fmt.Sprintf("job_%d.log", job.ID))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if attempt > 0 {
+		fmt.Fprintf(f, "=== job %d attempt %d ===\n", job.ID, attempt)
+	} else {
+		fmt.Fprintf(f, "=== job %d ===\n", job.ID)
+	}
+	return f, nil
+}
@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"context"
+	"time"
+)
+
+// waitStartSlot spaces job starts at least AGENT_JOB_START_STAGGER_MS apart so
+// a batch of jobs doesn't hit disk and network all at once. Slots are handed
+// out in call order, so jobs still start in the order they were dispatched.
+func (e *Executor) waitStartSlot(ctx context.Context) error {
+	stagger := time.Duration(e.cfg.JobStartStaggerMS) * time.Millisecond
+	if stagger <= 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	start := time.Now()
+	if next := e.lastStart.Add(stagger); next.After(start) {
+		start = next
+	}
+	e.lastStart = start
+	e.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(start)):
+		return nil
+	}
+}
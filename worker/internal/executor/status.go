@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+const (
+	statusTimeout = 10 * time.Second
+
+	// finalStatusTimeout bounds the client's retries of a final status, long
+	// enough to ride out a master restart.
+	finalStatusTimeout = 5 * time.Minute
+)
+
+// notifyStatus sends an intermediate status ("running", "ready") without
+// delaying the job. Updates go out in order in the background with their own
+// timeout; ReportStatus waits for them so the master never sees one after
+// the final status.
+func (e *Executor) notifyStatus(jobID int, update client.JobStatusUpdate) {
+	done := make(chan struct{})
+
+	e.mu.Lock()
+	prev := e.pendingStatus[jobID]
+	e.pendingStatus[jobID] = done
+	e.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+		defer cancel()
+		if err := e.masterClient.SendJobStatus(ctx, jobID, update); err != nil {
+			slog.Warn("Failed to update job status", "job_id", jobID, "status", update.Status, "error", err)
+		}
+	}()
+}
+
+// ReportStatus sends a job's final status once any pending intermediate update
+// has gone out; the client retries failures. It keeps trying after ctx is
+// cancelled so jobs finishing during shutdown are still reported.
+func (e *Executor) ReportStatus(ctx context.Context, jobID int, update client.JobStatusUpdate) error {
+	e.mu.Lock()
+	pending := e.pendingStatus[jobID]
+	delete(e.pendingStatus, jobID)
+	e.mu.Unlock()
+
+	if pending != nil {
+		<-pending
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalStatusTimeout)
+	defer cancel()
+	return e.masterClient.SendJobStatus(ctx, jobID, update)
+}
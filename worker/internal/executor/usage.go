@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// usageSampleInterval is how often a running job's resource usage is read.
+const usageSampleInterval = 2 * time.Second
+
+// usageSampler tracks the memory and CPU use of a job's process tree: the
+// shell the command runs in and everything it starts, or for Docker jobs the
+// container's processes.
+type usageSampler struct {
+	root  func() int32 // 0 until the tree's root is known
+	start time.Time
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	peakRSS uint64
+	cpu     map[int32]float64 // seconds of CPU time per process, at its last sample
+}
+
+// startUsageSampler samples the process tree rooted at pid until Stop.
+func startUsageSampler(pid int) *usageSampler {
+	return startTreeSampler(func() int32 { return int32(pid) })
+}
+
+// startContainerSampler samples the processes of a Docker container until
+// Stop. The container is not a child of the docker CLI, so its init process
+// is looked up once it has started. If it never can be, for example when the
+// daemon runs in a VM, no usage is reported.
+func startContainerSampler(name string) *usageSampler {
+	var pid int32
+	return startTreeSampler(func() int32 {
+		if pid == 0 {
+			pid = containerPID(name)
+		}
+		return pid
+	})
+}
+
+// startTreeSampler samples the process tree below the PID root returns.
+func startTreeSampler(root func() int32) *usageSampler {
+	s := &usageSampler{
+		root:  root,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+		cpu:   make(map[int32]float64),
+	}
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(usageSampleInterval)
+		defer ticker.Stop()
+
+		for {
+			s.sample()
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+// sample adds up the resident memory of the tree and records each process's
+// CPU time. Processes that exit between samples lose their last interval.
+func (s *usageSampler) sample() {
+	pid := s.root()
+	if pid <= 0 {
+		return
+	}
+	root, err := process.NewProcess(pid)
+	if err != nil {
+		return
+	}
+
+	var rss uint64
+	times := make(map[int32]float64)
+	queue := []*process.Process{root}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if mem, err := p.MemoryInfo(); err == nil {
+			rss += mem.RSS
+		}
+		if t, err := p.Times(); err == nil {
+			times[p.Pid] = t.User + t.System
+		}
+		if children, err := p.Children(); err == nil {
+			queue = append(queue, children...)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peakRSS = max(s.peakRSS, rss)
+	for pid, t := range times {
+		s.cpu[pid] = max(s.cpu[pid], t)
+	}
+}
+
+// Stop ends sampling and returns the peak memory in MB and the average CPU
+// use over the job's run, where 100 is one core.
+func (s *usageSampler) Stop() (peakMemoryMB int, avgCPUPercent float64) {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cpu float64
+	for _, t := range s.cpu {
+		cpu += t
+	}
+	if elapsed := time.Since(s.start).Seconds(); elapsed > 0 {
+		avgCPUPercent = cpu / elapsed * 100
+	}
+	return int(s.peakRSS / (1024 * 1024)), avgCPUPercent
+}
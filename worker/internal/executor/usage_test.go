@@ -0,0 +1,20 @@
+package executor
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUsageSamplerUnresolvedRoot(t *testing.T) {
+	s := startTreeSampler(func() int32 { return 0 })
+	if mem, cpu := s.Stop(); mem != 0 || cpu != 0 {
+		t.Errorf("Stop() = %d MB, %.1f%%, want no usage for a container that never started", mem, cpu)
+	}
+}
+
+func TestUsageSamplerOwnProcess(t *testing.T) {
+	s := startUsageSampler(os.Getpid())
+	if mem, _ := s.Stop(); mem <= 0 {
+		t.Errorf("Stop() = %d MB peak memory for the test process, want > 0", mem)
+	}
+}
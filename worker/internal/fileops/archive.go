@@ -0,0 +1,187 @@
+// Package fileops provides archive operations for the worker agent.
+package fileops
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// skipGit returns fs.SkipDir for .git directories unless they are included.
+func skipGit(d fs.DirEntry, includeGit bool) error {
+	if !includeGit && d.IsDir() && d.Name() == ".git" {
+		return fs.SkipDir
+	}
+	return nil
+}
+
+// TreeSize returns the total size of regular files under root.
+func TreeSize(root string, includeGit bool) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := skipGit(d, includeGit); err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// WriteTarGz streams root as a gzip-compressed tar to w. Entries are prefixed
+// with the directory's base name; symlinks are stored as links, not followed.
+func WriteTarGz(ctx context.Context, w io.Writer, root string, includeGit bool) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	prefix := filepath.Base(root)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := skipGit(d, includeGit); err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() && info.Mode()&fs.ModeSymlink == 0 {
+			return nil // Sockets, devices and pipes aren't exported
+		}
+
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, io.LimitReader(f, info.Size()))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// WriteFilesTarGz writes the regular files at the given slash-separated
+// paths under root to w as a gzip-compressed tar, named by those paths.
+func WriteFilesTarGz(ctx context.Context, w io.Writer, root string, files []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := addTarFile(tw, filepath.Join(root, filepath.FromSlash(rel)), rel); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// addTarFile writes one regular file to tw under name.
+func addTarFile(tw *tar.Writer, file, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, io.LimitReader(f, info.Size()))
+	return err
+}
+
+// MatchGlob reports whether a slash-separated path matches pattern, where
+// "**" as a whole segment matches any number of directories and the other
+// segments follow path.Match.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, err := path.Match(pattern[0], name[0])
+	return err == nil && ok && matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,71 @@
+// Package fileops provides checksum operations for the worker agent.
+package fileops
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// NewHash returns a hash for the given algorithm name (sha256, md5 or crc32).
+func NewHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha256":
+		return sha256.New(), nil
+	case "md5":
+		return md5.New(), nil
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+}
+
+// Checksum streams a regular file through the requested hash and returns
+// the hex digest and the number of bytes read.
+func Checksum(ctx context.Context, path, algo string) (string, int64, error) {
+	h, err := NewHash(algo)
+	if err != nil {
+		return "", 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", 0, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	n, err := io.Copy(h, &contextReader{ctx: ctx, r: f})
+	if err != nil {
+		return "", n, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// contextReader aborts reads once the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
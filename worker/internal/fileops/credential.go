@@ -0,0 +1,142 @@
+// Package fileops provides the git credential helper for the worker agent.
+package fileops
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// GitCredential holds the credentials for one git host.
+type GitCredential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoadGitCredentials reads per-host git credentials from a JSON file mapping
+// host names to credentials. The file must not be readable by group or others.
+func LoadGitCredentials(path string) (map[string]GitCredential, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("credentials file %s must not be accessible by group or others (mode %s)", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds map[string]GitCredential
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("invalid credentials file: %w", err)
+	}
+	return creds, nil
+}
+
+// ServeCredentialHelper implements the "get" operation of the git credential
+// helper protocol: it reads key=value attributes from in and writes the
+// matching username and password to out. Other operations are ignored.
+func ServeCredentialHelper(credsPath, operation string, in io.Reader, out io.Writer) error {
+	attrs := make(map[string]string)
+	sc := bufio.NewScanner(in)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			break
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			attrs[k] = v
+		}
+	}
+
+	if operation != "get" {
+		return nil
+	}
+
+	creds, err := LoadGitCredentials(credsPath)
+	if err != nil {
+		return err
+	}
+
+	cred, ok := creds[attrs["host"]]
+	if !ok {
+		return nil // No match, git falls through to other helpers
+	}
+
+	fmt.Fprintf(out, "username=%s\npassword=%s\n", cred.Username, cred.Password)
+	return nil
+}
+
+// credentialArgs returns git config arguments that replace any configured
+// credential helpers with the given one.
+func credentialArgs(helper string) []string {
+	if helper == "" {
+		return nil
+	}
+	return []string{"-c", "credential.helper=", "-c", "credential.helper=" + helper}
+}
+
+// inlineCredentialHelper answers git from the environment, so per-request
+// credentials never appear in command lines or URLs.
+const inlineCredentialHelper = `!f() { test "$1" = get && printf 'username=%s\npassword=%s\n' "$AGENT_GIT_USERNAME" "$AGENT_GIT_PASSWORD"; }; f`
+
+// gitAuth is how a git command authenticates: explicit credentials for this
+// operation, else the configured credential helper.
+type gitAuth struct {
+	helper string
+	creds  *GitCredential
+}
+
+// args returns the git config arguments selecting the credential helper.
+func (a gitAuth) args() []string {
+	if a.creds != nil {
+		return credentialArgs(inlineCredentialHelper)
+	}
+	return credentialArgs(a.helper)
+}
+
+// env returns the environment for a git command. Prompts are disabled so
+// missing credentials fail at once instead of waiting for the timeout.
+func (a gitAuth) env(extra ...string) []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+	if a.creds != nil {
+		env = append(env, "AGENT_GIT_USERNAME="+a.creds.Username, "AGENT_GIT_PASSWORD="+a.creds.Password)
+	}
+	return append(env, extra...)
+}
+
+// redact removes the credentials' secret from git output, also in the
+// escaped forms it takes in a URL's user info, path or query.
+func (a gitAuth) redact(s string) string {
+	if a.creds == nil || a.creds.Password == "" {
+		return s
+	}
+	password := a.creds.Password
+	userinfo := strings.TrimPrefix(url.UserPassword("", password).String(), ":")
+	for _, secret := range []string{password, userinfo, url.PathEscape(password), url.QueryEscape(password)} {
+		s = strings.ReplaceAll(s, secret, "***")
+	}
+	return s
+}
+
+// RedactURL hides the password of a URL with user info, for logging. A user
+// name without a password is hidden instead, since tokens are often passed
+// that way.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); !ok {
+		u.User = url.User("xxxxx")
+		return u.String()
+	}
+	return u.Redacted()
+}
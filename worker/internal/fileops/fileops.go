@@ -32,13 +32,61 @@ func ValidatePath(basePath, targetPath string) (string, error) {
 	}
 
 	// Ensure target is within base directory (prevent path traversal)
-	if !strings.HasPrefix(absTarget, absBase+string(os.PathSeparator)) && absTarget != absBase {
+	if !within(absTarget, absBase) {
 		return "", fmt.Errorf("path traversal detected: %s is outside %s", absTarget, absBase)
 	}
 
+	// A symlink inside the base can still lead out of it, so check where the
+	// path really goes. The base itself may be a symlink (e.g. to a mount).
+	realBase, err := resolveExisting(absBase)
+	if err != nil {
+		return "", fmt.Errorf("invalid base path: %w", err)
+	}
+	realTarget, err := resolveExisting(absTarget)
+	if err != nil {
+		return "", fmt.Errorf("invalid target path: %w", err)
+	}
+	if !within(realTarget, realBase) {
+		return "", fmt.Errorf("path traversal detected: %s resolves to %s, outside %s", absTarget, realTarget, absBase)
+	}
+
 	return absTarget, nil
 }
 
+// within reports whether path is base or below it. Both must be clean.
+func within(path, base string) bool {
+	return path == base || strings.HasPrefix(path, base+string(os.PathSeparator))
+}
+
+// resolveExisting resolves symlinks in the deepest existing ancestor of path
+// and appends the components that don't exist yet, e.g. a clone target. A
+// dangling symlink is followed to where writing through it would land.
+func resolveExisting(path string) (string, error) {
+	var missing []string
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{real}, missing...)...), nil
+		}
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return "", err
+		}
+		if link, err := os.Readlink(path); err == nil {
+			if !filepath.IsAbs(link) {
+				link = filepath.Join(parent, link)
+			}
+			real, err := resolveExisting(link)
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(append([]string{real}, missing...)...), nil
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
 // EnsureDir creates a directory and all parent directories if they don't exist.
 func EnsureDir(path string) error {
 	return os.MkdirAll(path, 0755)
@@ -0,0 +1,110 @@
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidatePathSymlinks(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "base")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{base, outside, filepath.Join(base, "inner")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"abs-escape":      outside,
+		"rel-escape":      "../outside",
+		"dangling-escape": filepath.Join(outside, "missing"),
+		"dangling-inside": "inner/missing",
+		"rel-inside":      "inner",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		wantErr bool
+	}{
+		{"absolute escaping link", "abs-escape", true},
+		{"file below absolute escaping link", "abs-escape/secret", true},
+		{"relative escaping link", "rel-escape", true},
+		{"dangling escaping link", "dangling-escape", true},
+		{"dangling link inside base", "dangling-inside", false},
+		{"relative link inside base", "rel-inside/file", false},
+		{"non-existent clone target", "new-project", false},
+		{"non-existent nested target", "new/deeper/project", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidatePath(base, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePath(%q) error = %v, want error %v", tt.target, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePathSymlinkedBase(t *testing.T) {
+	root := t.TempDir()
+	real := filepath.Join(root, "mnt", "projects")
+	if err := os.MkdirAll(filepath.Join(real, "existing"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(root, "projects")
+	if err := os.Symlink(real, base); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"existing", "new-project"} {
+		got, err := ValidatePath(base, target)
+		if err != nil {
+			t.Fatalf("ValidatePath(%q) through symlinked base: %v", target, err)
+		}
+		if want := filepath.Join(base, target); got != want {
+			t.Errorf("ValidatePath(%q) = %q, want %q", target, got, want)
+		}
+	}
+
+	if err := os.Symlink(root, filepath.Join(real, "up")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidatePath(base, "up/mnt"); err == nil {
+		t.Error("link escaping a symlinked base was accepted")
+	}
+}
+
+func TestValidatePathTraversal(t *testing.T) {
+	base := t.TempDir()
+
+	tests := []struct {
+		name    string
+		target  string
+		wantErr bool
+	}{
+		{"parent escape", "../../etc/passwd", true},
+		{"escape after descent", "a/../../x", true},
+		{"absolute outside base", "/etc/passwd", true},
+		{"base sibling with shared prefix", base + "-other/file", true},
+		{"absolute inside base", filepath.Join(base, "proj", "file"), false},
+		{"relative inside base", "proj/file", false},
+		{"descent and return", "a/../proj/file", false},
+		{"base itself", ".", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidatePath(base, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePath(%q) error = %v, want error %v", tt.target, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,8 +3,10 @@ package fileops
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
+	"path"
 	"strings"
 	"time"
 )
@@ -16,29 +18,61 @@ type CloneOptions struct {
 	TargetPath string
 	Depth      int // 0 means full clone
 	Timeout    time.Duration
+
+	// Ref, when set, is checked out (detached) after cloning instead of
+	// Branch: a branch, tag or commit SHA, fetched if the clone lacks it
+	Ref string
+
+	// CredentialHelper is a git credential.helper value used instead of any
+	// globally configured helpers
+	CredentialHelper string
+
+	// Credentials, when set, are used for this clone instead of the helper.
+	// The password is scrubbed from the result.
+	Credentials *GitCredential
+
+	// LFS fetches Git LFS objects after the clone, replacing pointer files
+	LFS bool
+
+	// Submodules clones submodules recursively, within the same timeout
+	Submodules bool
+
+	// SparsePaths limits the checkout to these directories (cone mode);
+	// empty checks out everything. Needs git 2.25 or later.
+	SparsePaths []string
 }
 
+// Timeouts of clones and pulls given none.
+const (
+	DefaultCloneTimeout = 10 * time.Minute
+	DefaultPullTimeout  = 5 * time.Minute
+)
+
 // CloneResult contains the result of a clone operation.
 type CloneResult struct {
-	Success   bool   `json:"success"`
-	LocalPath string `json:"local_path,omitempty"`
-	Message   string `json:"message,omitempty"`
-	Error     string `json:"error,omitempty"`
+	Success        bool   `json:"success"`
+	LocalPath      string `json:"local_path,omitempty"`
+	Message        string `json:"message,omitempty"`
+	Error          string `json:"error,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
 }
 
 // Clone clones a Git repository to the target path.
 func Clone(ctx context.Context, opts CloneOptions) *CloneResult {
-	if opts.Timeout == 0 {
-		opts.Timeout = 10 * time.Minute
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultCloneTimeout
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
 	defer cancel()
 
+	auth := gitAuth{helper: opts.CredentialHelper, creds: opts.Credentials}
+
 	// Build git clone command
-	args := []string{"clone", "--progress"}
+	args := append(auth.args(), "clone", "--progress")
 
-	if opts.Branch != "" {
+	pinned := opts.Ref != ""
+	if opts.Branch != "" && !pinned {
 		args = append(args, "--branch", opts.Branch)
 	}
 
@@ -46,23 +80,105 @@ func Clone(ctx context.Context, opts CloneOptions) *CloneResult {
 		args = append(args, "--depth", fmt.Sprintf("%d", opts.Depth))
 	}
 
-	args = append(args, opts.URL, opts.TargetPath)
+	// Sparse and pinned clones check out after the paths are set and the ref
+	// is found, submodules included
+	sparse := len(opts.SparsePaths) > 0
+	if sparse || pinned {
+		args = append(args, "--no-checkout")
+	} else if opts.Submodules {
+		args = append(args, "--recurse-submodules")
+	}
+
+	args = append(args, "--", opts.URL, opts.TargetPath)
+
+	if err := checkRemote(opts.URL); err != nil {
+		return &CloneResult{Error: err.Error(), TimeoutSeconds: int(opts.Timeout.Seconds())}
+	}
+	if pinned {
+		if err := checkRef(opts.Ref); err != nil {
+			return &CloneResult{Error: err.Error(), TimeoutSeconds: int(opts.Timeout.Seconds())}
+		}
+	}
+	if opts.LFS {
+		if err := checkLFS(); err != nil {
+			return &CloneResult{Error: err.Error(), TimeoutSeconds: int(opts.Timeout.Seconds())}
+		}
+	}
+	if sparse {
+		if err := checkSparseCheckout(ctx, opts.SparsePaths); err != nil {
+			return &CloneResult{Error: err.Error(), TimeoutSeconds: int(opts.Timeout.Seconds())}
+		}
+	}
 
 	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = auth.env()
+	if opts.LFS {
+		// LFS objects are fetched by lfsPull below, where its errors are reported
+		cmd.Env = auth.env("GIT_LFS_SKIP_SMUDGE=1")
+	}
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {
 		return &CloneResult{
-			Success: false,
-			Error:   err.Error(),
-			Message: string(output),
+			Success:        false,
+			Error:          auth.redact(gitError(ctx, err, "clone", opts.Timeout)),
+			Message:        auth.redact(string(output)),
+			TimeoutSeconds: int(opts.Timeout.Seconds()),
 		}
 	}
 
+	if sparse || pinned {
+		env := auth.env()
+		if opts.LFS {
+			env = auth.env("GIT_LFS_SKIP_SMUDGE=1")
+		}
+
+		commit := ""
+		if pinned {
+			var output string
+			commit, output, err = resolveRef(ctx, opts.TargetPath, "origin", opts.Ref, opts.Depth, auth, env)
+			if err != nil {
+				return &CloneResult{
+					Success:        false,
+					LocalPath:      opts.TargetPath,
+					Error:          auth.redact(gitError(ctx, err, "fetch", opts.Timeout)),
+					Message:        auth.redact(output),
+					TimeoutSeconds: int(opts.Timeout.Seconds()),
+				}
+			}
+		}
+
+		if output, err := checkoutClone(ctx, opts.TargetPath, opts.SparsePaths, commit, opts.Submodules, auth, env); err != nil {
+			return &CloneResult{
+				Success:        false,
+				LocalPath:      opts.TargetPath,
+				Error:          auth.redact(gitError(ctx, err, "checkout", opts.Timeout)),
+				Message:        auth.redact(output),
+				TimeoutSeconds: int(opts.Timeout.Seconds()),
+			}
+		}
+	}
+
+	message := "Clone completed successfully"
+	if opts.LFS {
+		lfsOutput, err := lfsPull(ctx, opts.TargetPath, auth)
+		if err != nil {
+			return &CloneResult{
+				Success:        false,
+				LocalPath:      opts.TargetPath,
+				Error:          auth.redact(lfsError(ctx, err, opts.Timeout)),
+				Message:        auth.redact(lfsOutput),
+				TimeoutSeconds: int(opts.Timeout.Seconds()),
+			}
+		}
+		message = "Clone completed successfully with LFS objects"
+	}
+
 	return &CloneResult{
-		Success:   true,
-		LocalPath: opts.TargetPath,
-		Message:   "Clone completed successfully",
+		Success:        true,
+		LocalPath:      opts.TargetPath,
+		Message:        message,
+		TimeoutSeconds: int(opts.Timeout.Seconds()),
 	}
 }
 
@@ -72,49 +188,309 @@ type PullOptions struct {
 	Remote   string
 	Branch   string
 	Timeout  time.Duration
+
+	// Ref, when set, is fetched and checked out (detached) instead of
+	// pulling Branch, see CloneOptions
+	Ref string
+
+	// CredentialHelper is a git credential.helper value, see CloneOptions
+	CredentialHelper string
+
+	// LFS fetches Git LFS objects after the pull, see CloneOptions
+	LFS bool
+
+	// Submodules updates submodules recursively after the pull
+	Submodules bool
 }
 
 // PullResult contains the result of a pull operation.
 type PullResult struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success        bool   `json:"success"`
+	Message        string `json:"message,omitempty"`
+	Error          string `json:"error,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
 }
 
 // Pull pulls the latest changes from a remote repository.
 func Pull(ctx context.Context, opts PullOptions) *PullResult {
-	if opts.Timeout == 0 {
-		opts.Timeout = 5 * time.Minute
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultPullTimeout
 	}
 	if opts.Remote == "" {
 		opts.Remote = "origin"
 	}
 
+	if opts.Ref != "" {
+		if err := checkRef(opts.Ref); err != nil {
+			return &PullResult{Error: err.Error(), TimeoutSeconds: int(opts.Timeout.Seconds())}
+		}
+	}
+	if opts.LFS {
+		if err := checkLFS(); err != nil {
+			return &PullResult{Error: err.Error(), TimeoutSeconds: int(opts.Timeout.Seconds())}
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
 	defer cancel()
 
-	// Build git pull command
-	args := []string{"pull", opts.Remote}
-	if opts.Branch != "" {
-		args = append(args, opts.Branch)
+	auth := gitAuth{helper: opts.CredentialHelper}
+	env := auth.env()
+	if opts.LFS {
+		env = auth.env("GIT_LFS_SKIP_SMUDGE=1")
 	}
 
-	cmd := exec.CommandContext(ctx, "git", args...)
-	cmd.Dir = opts.RepoPath
-	output, err := cmd.CombinedOutput()
+	var message string
+	if opts.Ref != "" {
+		output, op, err := checkoutRef(ctx, opts.RepoPath, opts.Remote, opts.Ref, auth, env)
+		if err != nil {
+			return &PullResult{
+				Success:        false,
+				Error:          gitError(ctx, err, op, opts.Timeout),
+				Message:        output,
+				TimeoutSeconds: int(opts.Timeout.Seconds()),
+			}
+		}
+		message = output
+	} else {
+		// Build git pull command
+		args := append(auth.args(), "pull", opts.Remote)
+		if opts.Branch != "" {
+			args = append(args, opts.Branch)
+		}
 
-	if err != nil {
-		return &PullResult{
-			Success: false,
-			Error:   err.Error(),
-			Message: string(output),
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = opts.RepoPath
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+
+		if err != nil {
+			return &PullResult{
+				Success:        false,
+				Error:          gitError(ctx, err, "pull", opts.Timeout),
+				Message:        string(output),
+				TimeoutSeconds: int(opts.Timeout.Seconds()),
+			}
 		}
+		message = strings.TrimSpace(string(output))
+	}
+
+	if opts.Submodules {
+		update := exec.CommandContext(ctx, "git", append(auth.args(), "submodule", "update", "--init", "--recursive")...)
+		update.Dir = opts.RepoPath
+		update.Env = env
+		updateOutput, err := update.CombinedOutput()
+		if err != nil {
+			return &PullResult{
+				Success:        false,
+				Error:          gitError(ctx, err, "submodule update", opts.Timeout),
+				Message:        string(updateOutput),
+				TimeoutSeconds: int(opts.Timeout.Seconds()),
+			}
+		}
+		message = strings.TrimSpace(message + "\n" + strings.TrimSpace(string(updateOutput)))
+	}
+	if opts.LFS {
+		lfsOutput, err := lfsPull(ctx, opts.RepoPath, auth)
+		if err != nil {
+			return &PullResult{
+				Success:        false,
+				Error:          lfsError(ctx, err, opts.Timeout),
+				Message:        lfsOutput,
+				TimeoutSeconds: int(opts.Timeout.Seconds()),
+			}
+		}
+		message = strings.TrimSpace(message + "\n" + lfsOutput)
 	}
 
 	return &PullResult{
-		Success: true,
-		Message: strings.TrimSpace(string(output)),
+		Success:        true,
+		Message:        message,
+		TimeoutSeconds: int(opts.Timeout.Seconds()),
+	}
+}
+
+// sparseCheckoutMinVersion is the first git release with sparse-checkout.
+var sparseCheckoutMinVersion = [2]int{2, 25}
+
+// checkSparseCheckout validates sparse paths and makes sure the local git
+// supports the sparse-checkout command.
+func checkSparseCheckout(ctx context.Context, paths []string) error {
+	for _, p := range paths {
+		clean := path.Clean(strings.TrimSpace(p))
+		if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(clean, "-") || path.IsAbs(clean) {
+			return fmt.Errorf("invalid sparse path %q: must be a directory inside the repository", p)
+		}
+	}
+
+	output, err := exec.CommandContext(ctx, "git", "version").Output()
+	if err != nil {
+		return fmt.Errorf("failed to get git version: %w", err)
+	}
+	version := strings.TrimSpace(string(output))
+	var major, minor int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(version, "git version "), "%d.%d", &major, &minor); err != nil {
+		return fmt.Errorf("unrecognized git version %q", version)
+	}
+	if major < sparseCheckoutMinVersion[0] || (major == sparseCheckoutMinVersion[0] && minor < sparseCheckoutMinVersion[1]) {
+		return fmt.Errorf("sparse checkout needs git %d.%d or later, this node has %s",
+			sparseCheckoutMinVersion[0], sparseCheckoutMinVersion[1], version)
+	}
+	return nil
+}
+
+// checkoutClone checks out a --no-checkout clone, limited to paths if any
+// and at commit if set, then fetches submodules if asked. It returns the
+// failing step's output.
+func checkoutClone(ctx context.Context, repoPath string, paths []string, commit string, submodules bool, auth gitAuth, env []string) (string, error) {
+	var steps [][]string
+	if len(paths) > 0 {
+		steps = append(steps, append([]string{"sparse-checkout", "set"}, paths...))
+	}
+	if commit != "" {
+		steps = append(steps, []string{"checkout", "--detach", commit})
+	} else {
+		steps = append(steps, []string{"checkout"})
+	}
+	if submodules {
+		steps = append(steps, append(auth.args(), "submodule", "update", "--init", "--recursive"))
+	}
+
+	for _, args := range steps {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = repoPath
+		cmd.Env = env
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return string(output), err
+		}
+	}
+	return "", nil
+}
+
+// checkRef rejects refs git would take for an option.
+func checkRef(ref string) error {
+	if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n") {
+		return fmt.Errorf("%w %q", ErrInvalidRef, ref)
+	}
+	return nil
+}
+
+// checkRemote rejects a remote URL git would parse as an option, such as
+// --upload-pack=<command>.
+func checkRemote(remote string) error {
+	if strings.HasPrefix(remote, "-") {
+		return fmt.Errorf("%w %q", ErrInvalidRemote, remote)
+	}
+	return nil
+}
+
+// resolveRef returns the commit ref names in a repository, preferring the
+// remote's branch of that name over a local one. A ref the repository lacks,
+// like a commit beyond a shallow clone's history, is fetched from remote.
+// It returns the fetch output.
+func resolveRef(ctx context.Context, repoPath, remote, ref string, depth int, auth gitAuth, env []string) (string, string, error) {
+	revParse := func(name string) string {
+		cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", name+"^{commit}")
+		cmd.Dir = repoPath
+		output, err := cmd.Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	for _, name := range []string{remote + "/" + ref, ref} {
+		if commit := revParse(name); commit != "" {
+			return commit, "", nil
+		}
+	}
+
+	args := append(auth.args(), "fetch")
+	if depth > 0 {
+		args = append(args, "--depth", fmt.Sprintf("%d", depth))
+	}
+	cmd := exec.CommandContext(ctx, "git", append(args, remote, ref)...)
+	cmd.Dir = repoPath
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return "", string(output), err
 	}
+	if err == nil {
+		if commit := revParse("FETCH_HEAD"); commit != "" {
+			return commit, string(output), nil
+		}
+	}
+	return "", string(output), fmt.Errorf("ref %q not found on %s", ref, remote)
+}
+
+// checkoutRef fetches remote and checks out ref on a detached HEAD. It
+// returns the checkout output, or on failure the failing step's output and
+// name.
+func checkoutRef(ctx context.Context, repoPath, remote, ref string, auth gitAuth, env []string) (string, string, error) {
+	fetch := exec.CommandContext(ctx, "git", append(auth.args(), "fetch", "--tags", remote)...)
+	fetch.Dir = repoPath
+	fetch.Env = env
+	if output, err := fetch.CombinedOutput(); err != nil {
+		return string(output), "fetch", err
+	}
+
+	commit, output, err := resolveRef(ctx, repoPath, remote, ref, 0, auth, env)
+	if err != nil {
+		return output, "fetch", err
+	}
+
+	checkout := exec.CommandContext(ctx, "git", "checkout", "--detach", commit)
+	checkout.Dir = repoPath
+	checkout.Env = env
+	checkoutOutput, err := checkout.CombinedOutput()
+	if err != nil {
+		return string(checkoutOutput), "checkout", err
+	}
+	return strings.TrimSpace(string(checkoutOutput)), "", nil
+}
+
+// checkLFS reports a missing git-lfs up front rather than as a git exit code.
+func checkLFS() error {
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		return errors.New("git-lfs is not installed on this node; install it or retry without lfs")
+	}
+	return nil
+}
+
+// lfsPull enables LFS for the repository and fetches the objects for the
+// checked-out commit, returning git's combined output.
+func lfsPull(ctx context.Context, repoPath string, auth gitAuth) (string, error) {
+	install := exec.CommandContext(ctx, "git", "lfs", "install", "--local")
+	install.Dir = repoPath
+	if output, err := install.CombinedOutput(); err != nil {
+		return string(output), fmt.Errorf("git lfs install: %w", err)
+	}
+
+	pull := exec.CommandContext(ctx, "git", append(auth.args(), "lfs", "pull")...)
+	pull.Dir = repoPath
+	pull.Env = auth.env()
+	output, err := pull.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("git lfs pull: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// lfsError describes a failed LFS step like gitError does for git commands.
+func lfsError(ctx context.Context, err error, timeout time.Duration) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Sprintf("git lfs pull timed out after %v", timeout)
+	}
+	return err.Error()
+}
+
+// gitError describes a failed git command, calling out timeouts explicitly.
+func gitError(ctx context.Context, err error, op string, timeout time.Duration) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Sprintf("git %s timed out after %v", op, timeout)
+	}
+	return err.Error()
 }
 
 // GitStatus represents the status of a Git repository.
@@ -134,13 +510,11 @@ func GetStatus(ctx context.Context, repoPath string) (*GitStatus, error) {
 	status := &GitStatus{}
 
 	// Get current branch
-	branchCmd := exec.CommandContext(ctx, "git", "branch", "--show-current")
-	branchCmd.Dir = repoPath
-	branchOutput, err := branchCmd.Output()
+	branch, err := CurrentBranch(ctx, repoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get branch: %w", err)
+		return nil, err
 	}
-	status.Branch = strings.TrimSpace(string(branchOutput))
+	status.Branch = branch
 
 	// Get status
 	statusCmd := exec.CommandContext(ctx, "git", "status", "--porcelain")
@@ -182,6 +556,169 @@ func GetStatus(ctx context.Context, repoPath string) (*GitStatus, error) {
 	return status, nil
 }
 
+// Checkout and remote errors the API maps to HTTP statuses.
+var (
+	ErrUncommittedChanges = errors.New("repository has uncommitted changes; commit or discard them first")
+	ErrRefNotFound        = errors.New("ref not found")
+	ErrInvalidRef         = errors.New("invalid ref")
+	ErrInvalidRemote      = errors.New("invalid remote")
+)
+
+// CheckoutResult is the state of a repository after a checkout.
+type CheckoutResult struct {
+	Branch  string `json:"branch"` // Empty for a detached HEAD
+	Commit  string `json:"commit"`
+	Message string `json:"message,omitempty"`
+}
+
+// Checkout switches a repository to ref, a branch (including one that only
+// exists on the remote), tag or commit. With create, ref is a new branch
+// started at the current HEAD. Changes to tracked files make it fail with
+// ErrUncommittedChanges rather than carrying them over.
+func Checkout(ctx context.Context, repoPath, ref string, create bool) (*CheckoutResult, error) {
+	if ref == "" {
+		return nil, errors.New("ref is required")
+	}
+	if err := checkRef(ref); err != nil {
+		return nil, err
+	}
+
+	status := exec.CommandContext(ctx, "git", "status", "--porcelain", "--untracked-files=no")
+	status.Dir = repoPath
+	statusOutput, err := status.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	if strings.TrimSpace(string(statusOutput)) != "" {
+		return nil, ErrUncommittedChanges
+	}
+
+	args := []string{"checkout", ref, "--"}
+	if create {
+		args = []string{"checkout", "-b", ref}
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		switch {
+		case strings.Contains(msg, "would be overwritten by checkout"):
+			return nil, ErrUncommittedChanges
+		case strings.Contains(msg, "did not match any") || strings.Contains(msg, "invalid reference"):
+			return nil, fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+		}
+		return nil, fmt.Errorf("git checkout failed: %s", msg)
+	}
+
+	result := &CheckoutResult{Message: strings.TrimSpace(string(output))}
+	if result.Branch, err = CurrentBranch(ctx, repoPath); err != nil {
+		return nil, err
+	}
+	head := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
+	head.Dir = repoPath
+	headOutput, err := head.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	result.Commit = strings.TrimSpace(string(headOutput))
+
+	return result, nil
+}
+
+// CurrentBranch returns the checked-out branch, or "" for a detached HEAD.
+func CurrentBranch(ctx context.Context, repoPath string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "branch", "--show-current")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Branches lists a repository's branches.
+type Branches struct {
+	Current string   `json:"current"` // Empty for a detached HEAD
+	Local   []string `json:"local"`
+	Remote  []string `json:"remote"` // As remote/branch
+}
+
+// ListBranches returns the local and remote-tracking branches of a repository.
+func ListBranches(ctx context.Context, repoPath string) (*Branches, error) {
+	cmd := exec.CommandContext(ctx, "git", "branch", "-a", "--format=%(refname)")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	branches := &Branches{Local: []string{}, Remote: []string{}}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		switch {
+		case strings.HasPrefix(line, "refs/heads/"):
+			branches.Local = append(branches.Local, strings.TrimPrefix(line, "refs/heads/"))
+		case strings.HasPrefix(line, "refs/remotes/") && !strings.HasSuffix(line, "/HEAD"):
+			branches.Remote = append(branches.Remote, strings.TrimPrefix(line, "refs/remotes/"))
+		}
+	}
+
+	if branches.Current, err = CurrentBranch(ctx, repoPath); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+// Commit is one entry of a repository's history.
+type Commit struct {
+	Hash    string `json:"hash"`
+	Author  string `json:"author"`
+	Date    string `json:"date"` // RFC 3339
+	Subject string `json:"subject"`
+}
+
+// GetLog returns the last limit commits reachable from HEAD, newest first.
+// A repository without commits has an empty log.
+func GetLog(ctx context.Context, repoPath string, limit int) ([]Commit, error) {
+	// Fields are separated by the unit separator, which subjects don't contain
+	cmd := exec.CommandContext(ctx, "git", "log", "-n", fmt.Sprintf("%d", limit), "--format=%H%x1f%an%x1f%aI%x1f%s")
+	cmd.Dir = repoPath
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "does not have any commits") {
+			return []Commit{}, nil
+		}
+		return nil, fmt.Errorf("failed to get log: %s", strings.TrimSpace(string(output)))
+	}
+
+	commits := []Commit{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\x1f", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		commits = append(commits, Commit{Hash: fields[0], Author: fields[1], Date: fields[2], Subject: fields[3]})
+	}
+	return commits, nil
+}
+
+// BranchAllowed reports whether branch matches one of the glob patterns
+// (e.g. "main", "release/*", "v*"). An empty pattern list allows any branch.
+func BranchAllowed(patterns []string, branch string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if branch == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, err := path.Match(strings.TrimSpace(pattern), branch); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
 // IsGitRepo checks if a directory is a Git repository.
 func IsGitRepo(path string) bool {
 	cmd := exec.Command("git", "rev-parse", "--git-dir")
@@ -0,0 +1,72 @@
+package fileops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// RemoteInfo describes a reachable git remote.
+type RemoteInfo struct {
+	DefaultBranch string `json:"default_branch,omitempty"` // Empty for an empty repository
+	Commit        string `json:"commit,omitempty"`         // Commit of the default branch
+}
+
+// VerifyRemote checks that a remote can be read with the given credentials
+// (or the credential helper) by listing its HEAD with git ls-remote. The
+// returned error never contains the password.
+func VerifyRemote(ctx context.Context, remote, helper string, creds *GitCredential, timeout time.Duration) (*RemoteInfo, error) {
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := checkRemote(remote); err != nil {
+		return nil, err
+	}
+
+	auth := gitAuth{helper: helper, creds: creds}
+	args := append(auth.args(), "ls-remote", "--symref", "--exit-code", "--", remote, "HEAD")
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = auth.env()
+	output, err := cmd.CombinedOutput()
+
+	// A password written into the URL itself is scrubbed too
+	redact := func(s string) string {
+		s = auth.redact(s)
+		if u, err := url.Parse(remote); err == nil && u.User != nil {
+			if password, ok := u.User.Password(); ok && password != "" {
+				s = strings.ReplaceAll(s, password, "***")
+			}
+		}
+		return s
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 && ctx.Err() == nil {
+			return &RemoteInfo{}, nil // Reachable, but no HEAD: an empty repository
+		}
+		msg := gitError(ctx, err, "ls-remote", timeout)
+		if out := strings.TrimSpace(string(output)); out != "" {
+			msg += ": " + out
+		}
+		return nil, fmt.Errorf("%s", redact(msg))
+	}
+
+	info := &RemoteInfo{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if ref, ok := strings.CutPrefix(line, "ref: "); ok {
+			ref, _, _ = strings.Cut(ref, "\t")
+			info.DefaultBranch = strings.TrimPrefix(ref, "refs/heads/")
+		} else if commit, name, ok := strings.Cut(line, "\t"); ok && name == "HEAD" {
+			info.Commit = commit
+		}
+	}
+	return info, nil
+}
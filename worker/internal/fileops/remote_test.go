@@ -0,0 +1,33 @@
+package fileops
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A git_url starting with "-" must never reach git as an option; with
+// --upload-pack it would run a command.
+func TestRemoteOptionInjection(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "pwned")
+	remote := "--upload-pack=touch " + marker + ";"
+
+	if _, err := VerifyRemote(context.Background(), remote, "", nil, 10*time.Second); !errors.Is(err, ErrInvalidRemote) {
+		t.Errorf("VerifyRemote error = %v, want ErrInvalidRemote", err)
+	}
+	result := Clone(context.Background(), CloneOptions{
+		URL:        remote,
+		TargetPath: filepath.Join(t.TempDir(), "clone"),
+		Timeout:    10 * time.Second,
+	})
+	if result.Success {
+		t.Error("clone of an option-like remote succeeded")
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("remote was run as a git option")
+	}
+}
@@ -0,0 +1,57 @@
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// MoveToTrash moves path into trashDir under a timestamped name instead of
+// deleting it. The entry's mtime records when it was trashed.
+func MoveToTrash(trashDir, path, label string) (string, error) {
+	if err := os.MkdirAll(trashDir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create trash directory: %w", err)
+	}
+
+	now := time.Now()
+	dest := filepath.Join(trashDir, fmt.Sprintf("%s-%s-%s",
+		now.UTC().Format("20060102T150405Z"), label, filepath.Base(path)))
+
+	if err := os.Rename(path, dest); err != nil {
+		if errors.Is(err, syscall.EXDEV) {
+			return "", fmt.Errorf("trash directory %s must be on the same filesystem as %s", trashDir, path)
+		}
+		return "", err
+	}
+	os.Chtimes(dest, now, now)
+
+	return dest, nil
+}
+
+// PurgeTrash removes trash entries older than retention and returns how many were removed.
+func PurgeTrash(trashDir string, retention time.Duration) (int, error) {
+	entries, err := os.ReadDir(trashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < retention {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(trashDir, entry.Name())); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,106 @@
+// Package health provides node self-checks for fatal resource loss.
+package health
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/sysinfo"
+)
+
+// Node status values reported in heartbeats.
+const (
+	StatusOnline    = "online"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Options controls which self-checks are enabled.
+type Options struct {
+	CheckGPU     bool
+	CheckStorage bool
+	StoragePath  string
+}
+
+// Monitor tracks resources seen on the node and detects when they disappear.
+type Monitor struct {
+	opts Options
+
+	mu       sync.RWMutex
+	peakGPUs int
+	healthy  bool
+	reason   string
+}
+
+// NewMonitor creates a new health monitor. The node starts out healthy.
+func NewMonitor(opts Options) *Monitor {
+	return &Monitor{
+		opts:    opts,
+		healthy: true,
+	}
+}
+
+// Check evaluates the latest system info and returns the node status and,
+// when unhealthy, a human-readable reason.
+func (m *Monitor) Check(info *sysinfo.SystemInfo) (string, string) {
+	var problems []string
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.opts.CheckGPU {
+		if info.GPUCount > m.peakGPUs {
+			m.peakGPUs = info.GPUCount
+		}
+		if m.peakGPUs > 0 && info.GPUCount == 0 {
+			problems = append(problems, fmt.Sprintf("all %d GPUs disappeared", m.peakGPUs))
+		}
+	}
+
+	if m.opts.CheckStorage && m.opts.StoragePath != "" {
+		if err := checkStorage(m.opts.StoragePath); err != nil {
+			problems = append(problems, fmt.Sprintf("storage path inaccessible: %v", err))
+		}
+	}
+
+	m.healthy = len(problems) == 0
+	m.reason = strings.Join(problems, "; ")
+
+	if !m.healthy {
+		return StatusUnhealthy, m.reason
+	}
+	return StatusOnline, ""
+}
+
+// Healthy reports whether the last check passed, and the reason if it did not.
+func (m *Monitor) Healthy() (bool, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.healthy, m.reason
+}
+
+// checkStorage verifies the storage path exists, is a directory and can be listed.
+func checkStorage(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	// Listing a single entry catches stale network mounts that still stat fine
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package metrics holds the agent's counters and gauges, shared by every
+// exporter so they all report the same values.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Kind distinguishes monotonically increasing counters from gauges.
+type Kind int
+
+const (
+	KindCounter Kind = iota
+	KindGauge
+)
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() { c.v.Add(1) }
+
+// Add adds n to the counter.
+func (c *Counter) Add(n uint64) { c.v.Add(n) }
+
+// Value returns the current count.
+func (c *Counter) Value() float64 { return float64(c.v.Load()) }
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set replaces the gauge value.
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// Add adds delta to the gauge value.
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+// Sample is a point-in-time reading of one metric.
+type Sample struct {
+	Name  string
+	Help  string
+	Kind  Kind
+	Value float64
+}
+
+type metric struct {
+	help    string
+	kind    Kind
+	counter *Counter
+	gauge   *Gauge
+}
+
+// Registry is a named set of metrics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*metric)}
+}
+
+// NewCounter registers and returns a counter.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.mu.Lock()
+	r.metrics[name] = &metric{help: help, kind: KindCounter, counter: c}
+	r.mu.Unlock()
+	return c
+}
+
+// NewGauge registers and returns a gauge.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.mu.Lock()
+	r.metrics[name] = &metric{help: help, kind: KindGauge, gauge: g}
+	r.mu.Unlock()
+	return g
+}
+
+// Snapshot returns the current value of every metric, sorted by name.
+func (r *Registry) Snapshot() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	samples := make([]Sample, 0, len(r.metrics))
+	for name, m := range r.metrics {
+		s := Sample{Name: name, Help: m.help, Kind: m.kind}
+		if m.kind == KindCounter {
+			s.Value = m.counter.Value()
+		} else {
+			s.Value = m.gauge.Value()
+		}
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+
+	return samples
+}
+
+// Default is the registry the agent's metrics live in.
+var Default = NewRegistry()
+
+// Agent metrics
+var (
+	JobsExecuted  = Default.NewCounter("mlsagent_jobs_executed_total", "Jobs executed by this agent")
+	JobsSucceeded = Default.NewCounter("mlsagent_jobs_succeeded_total", "Jobs that exited successfully")
+	JobsFailed    = Default.NewCounter("mlsagent_jobs_failed_total", "Jobs that failed")
+	JobsRunning   = Default.NewGauge("mlsagent_jobs_running", "Jobs currently running")
+
+	HeartbeatUp = Default.NewGauge("mlsagent_heartbeat_up", "1 if the last heartbeat to the master succeeded")
+
+	CPUUsagePercent = Default.NewGauge("mlsagent_cpu_usage_percent", "Host CPU usage")
+	GPUCount        = Default.NewGauge("mlsagent_gpu_count", "GPUs detected on the host")
+	GPUUtilization  = Default.NewGauge("mlsagent_gpu_utilization_percent", "Mean utilization across the host's GPUs")
+	GPUMemoryUsedMB = Default.NewGauge("mlsagent_gpu_memory_used_mb", "GPU memory in use across the host's GPUs")
+	StorageTotalGB  = Default.NewGauge("mlsagent_storage_total_gb", "Size of the storage volume")
+	StorageUsedGB   = Default.NewGauge("mlsagent_storage_used_gb", "Used space on the storage volume")
+)
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// PrometheusHandler serves the registry's metrics for Prometheus scrapes,
+// along with the agent process's and Go runtime's own metrics.
+func PrometheusHandler(registry *Registry) (http.Handler, error) {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+		return nil, err
+	}
+	if err := reg.Register(collectors.NewGoCollector()); err != nil {
+		return nil, err
+	}
+
+	for _, c := range registry.collectors() {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{
+		ErrorLog:      promLogger{},
+		ErrorHandling: promhttp.ContinueOnError,
+	}), nil
+}
+
+// collectors returns a Prometheus collector reading each metric's value at
+// scrape time.
+func (r *Registry) collectors() []prometheus.Collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cs := make([]prometheus.Collector, 0, len(r.metrics))
+	for name, m := range r.metrics {
+		if m.kind == KindCounter {
+			cs = append(cs, prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: m.help}, m.counter.Value))
+		} else {
+			cs = append(cs, prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: m.help}, m.gauge.Value))
+		}
+	}
+	return cs
+}
+
+// promLogger sends errors from serving a scrape to the agent's log.
+type promLogger struct{}
+
+func (promLogger) Println(v ...interface{}) {
+	slog.Error("Failed to serve metrics", "error", fmt.Sprint(v...))
+}
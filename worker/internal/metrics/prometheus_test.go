@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("test_jobs_total", "Jobs run").Add(3)
+	registry.NewGauge("test_jobs_running", "Jobs running").Set(2)
+
+	handler, err := PrometheusHandler(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Code != 200 {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE test_jobs_total counter\ntest_jobs_total 3\n",
+		"# TYPE test_jobs_running gauge\ntest_jobs_running 2\n",
+		"go_goroutines ",
+		"process_start_time_seconds ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape is missing %q", want)
+		}
+	}
+}
+
+// Values are read at scrape time, not when the handler is built.
+func TestPrometheusHandlerReadsCurrentValues(t *testing.T) {
+	registry := NewRegistry()
+	running := registry.NewGauge("test_jobs_running", "Jobs running")
+
+	handler, err := PrometheusHandler(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running.Set(5)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "test_jobs_running 5\n") {
+		t.Errorf("scrape did not report the gauge's current value:\n%s", rec.Body)
+	}
+}
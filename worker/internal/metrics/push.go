@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Pusher periodically sends a registry's metrics to a StatsD or OTLP collector.
+type Pusher struct {
+	registry *Registry
+	nodeName string
+	push     func(ctx context.Context, samples []Sample) error
+
+	lastCounters map[string]float64 // StatsD sends counter deltas
+	start        time.Time
+}
+
+// NewPusher creates a pusher for sink, which is one of
+//
+//	statsd://host:8125
+//	otlp://host:4318   (OTLP/HTTP JSON, https with otlps://)
+func NewPusher(sink, nodeName string, registry *Registry) (*Pusher, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics sink %q: %w", sink, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid metrics sink %q: missing host", sink)
+	}
+
+	p := &Pusher{
+		registry:     registry,
+		nodeName:     nodeName,
+		lastCounters: make(map[string]float64),
+		start:        time.Now(),
+	}
+
+	switch u.Scheme {
+	case "statsd":
+		p.push = p.statsdPush(u.Host)
+	case "otlp", "otlps":
+		scheme := "http"
+		if u.Scheme == "otlps" {
+			scheme = "https"
+		}
+		endpoint := fmt.Sprintf("%s://%s/v1/metrics", scheme, u.Host)
+		p.push = p.otlpPush(endpoint)
+	default:
+		return nil, fmt.Errorf("unsupported metrics sink scheme %q (want statsd, otlp or otlps)", u.Scheme)
+	}
+
+	return p, nil
+}
+
+// Run pushes metrics every interval until ctx is done.
+func (p *Pusher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.push(ctx, p.registry.Snapshot()); err != nil {
+				slog.Warn("Failed to push metrics", "error", err)
+			}
+		}
+	}
+}
+
+// maxStatsDPacket keeps datagrams below a typical MTU.
+const maxStatsDPacket = 1400
+
+// statsdPush sends gauges as values and counters as deltas over UDP.
+func (p *Pusher) statsdPush(addr string) func(context.Context, []Sample) error {
+	return func(ctx context.Context, samples []Sample) error {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		var packet bytes.Buffer
+		flush := func() error {
+			if packet.Len() == 0 {
+				return nil
+			}
+			_, err := conn.Write(bytes.TrimSuffix(packet.Bytes(), []byte("\n")))
+			packet.Reset()
+			return err
+		}
+
+		for _, s := range samples {
+			var line string
+			if s.Kind == KindCounter {
+				delta := s.Value - p.lastCounters[s.Name]
+				p.lastCounters[s.Name] = s.Value
+				line = fmt.Sprintf("%s:%s|c\n", s.Name, strconv.FormatFloat(delta, 'f', -1, 64))
+			} else {
+				line = fmt.Sprintf("%s:%s|g\n", s.Name, strconv.FormatFloat(s.Value, 'f', -1, 64))
+			}
+
+			if packet.Len()+len(line) > maxStatsDPacket {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			packet.WriteString(line)
+		}
+
+		return flush()
+	}
+}
+
+// OTLP/HTTP JSON payload, reduced to the parts the agent uses.
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpDataPoint struct {
+	AsDouble          float64 `json:"asDouble"`
+	StartTimeUnixNano string  `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string  `json:"timeUnixNano"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const otlpCumulative = 2
+
+// otlpPush posts the metrics as an OTLP/HTTP JSON export request.
+func (p *Pusher) otlpPush(endpoint string) func(context.Context, []Sample) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return func(ctx context.Context, samples []Sample) error {
+		now := strconv.FormatInt(time.Now().UnixNano(), 10)
+		start := strconv.FormatInt(p.start.UnixNano(), 10)
+
+		metrics := make([]otlpMetric, 0, len(samples))
+		for _, s := range samples {
+			m := otlpMetric{Name: s.Name, Description: s.Help}
+			if s.Kind == KindCounter {
+				m.Sum = &otlpSum{
+					DataPoints:             []otlpDataPoint{{AsDouble: s.Value, StartTimeUnixNano: start, TimeUnixNano: now}},
+					AggregationTemporality: otlpCumulative,
+					IsMonotonic:            true,
+				}
+			} else {
+				m.Gauge = &otlpGauge{
+					DataPoints: []otlpDataPoint{{AsDouble: s.Value, TimeUnixNano: now}},
+				}
+			}
+			metrics = append(metrics, m)
+		}
+
+		attr := func(key, value string) otlpKeyValue {
+			kv := otlpKeyValue{Key: key}
+			kv.Value.StringValue = value
+			return kv
+		}
+		payload := map[string]any{
+			"resourceMetrics": []any{map[string]any{
+				"resource": map[string]any{
+					"attributes": []otlpKeyValue{
+						attr("service.name", "mlsmanager-worker"),
+						attr("host.name", p.nodeName),
+					},
+				},
+				"scopeMetrics": []any{map[string]any{
+					"scope":   map[string]string{"name": "mlsmanager-worker"},
+					"metrics": metrics,
+				}},
+			}},
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("collector returned %s", resp.Status)
+		}
+		return nil
+	}
+}
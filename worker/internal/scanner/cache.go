@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// cachedDataset is a dataset as last scanned, reused while its directory's
+// own mtime is unchanged.
+type cachedDataset struct {
+	dirModTime time.Time
+	info       client.DatasetInfo // includes file count and size
+	generation int                // scan in which the dataset was last seen
+}
+
+// beginScan starts a scan pass and decides whether it must walk every
+// dataset: the first pass, every pass with the cache disabled, and once per
+// full rescan interval, since changes below a dataset's top level don't
+// touch its mtime.
+func (s *Scanner) beginScan() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	s.fullScan = !s.opts.Cache || s.lastFull.IsZero() ||
+		(s.opts.FullRescanInterval > 0 && time.Since(s.lastFull) >= s.opts.FullRescanInterval)
+	if s.fullScan {
+		s.lastFull = time.Now()
+	}
+}
+
+// endScan drops cached datasets not seen in the pass, so deleted datasets
+// aren't resurrected later.
+func (s *Scanner) endScan() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for path, cached := range s.cache {
+		if cached.generation != s.generation {
+			delete(s.cache, path)
+		}
+	}
+}
+
+// cached returns the stored result for a dataset directory if it can be
+// reused in this pass.
+func (s *Scanner) cached(path string, dirModTime time.Time) (*client.DatasetInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[path]
+	if !ok {
+		return nil, false
+	}
+	entry.generation = s.generation
+	s.cache[path] = entry
+	if s.fullScan || !entry.dirModTime.Equal(dirModTime) {
+		return nil, false
+	}
+
+	info := entry.info
+	return &info, true
+}
+
+// previous returns the last stored result for a dataset directory, whatever
+// its mtime. cached has already marked it seen in this pass.
+func (s *Scanner) previous(path string) (*client.DatasetInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[path]
+	if !ok {
+		return nil, false
+	}
+	info := entry.info
+	return &info, true
+}
+
+// store remembers a scanned dataset for later passes.
+func (s *Scanner) store(path string, dirModTime time.Time, info client.DatasetInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cache == nil {
+		s.cache = make(map[string]cachedDataset)
+	}
+	s.cache[path] = cachedDataset{
+		dirModTime: dirModTime,
+		info:       info,
+		generation: s.generation,
+	}
+}
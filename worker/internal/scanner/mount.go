@@ -0,0 +1,86 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrUnavailable means a dataset base path is temporarily unreachable (e.g. a
+// network mount is down), as opposed to present but empty.
+var ErrUnavailable = errors.New("dataset path unavailable")
+
+// unmountedMount returns the /etc/fstab mount point covering path when that
+// mount is not currently active, in which case path shows the bare mount
+// point directory (or nothing) instead of the real datasets.
+func unmountedMount(path string) (string, bool) {
+	mounted := mountPoints("/proc/self/mounts")
+	if mounted == nil {
+		return "", false // Can't tell on this platform
+	}
+
+	for _, mp := range mountPoints("/etc/fstab") {
+		if mp == "/" || !(path == mp || strings.HasPrefix(path, mp+"/")) {
+			continue
+		}
+		if !contains(mounted, mp) {
+			return mp, true
+		}
+	}
+	return "", false
+}
+
+// mountPoints returns the mount point column of an fstab-format file.
+func mountPoints(file string) []string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	points := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "/") {
+			continue // swap and other non-path entries
+		}
+		points = append(points, filepath.Clean(unescapeMount(fields[1])))
+	}
+	return points
+}
+
+// unescapeMount decodes the octal escapes (\040 for space) used in mount tables.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
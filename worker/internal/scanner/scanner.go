@@ -2,60 +2,176 @@
 package scanner
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+	"github.com/YangYuS8/mlsmanager-worker/internal/fileops"
 )
 
+// Options controls how datasets are scanned and reported.
+type Options struct {
+	// RelativePaths reports LocalPath relative to the scanned base path
+	// instead of as an absolute path
+	RelativePaths bool
+
+	// SettleWindow holds back new datasets with a file modified more recently
+	// than this, as they are probably still being copied in; datasets already
+	// reported keep their previous result until they settle (0 disables)
+	SettleWindow time.Duration
+
+	// MaxDepth is how many directory levels below the base path may hold
+	// datasets; 1 (or less) treats each top-level directory as one dataset.
+	// See scanTree for how nested directories are split.
+	MaxDepth int
+
+	// FormatMap maps file extensions (".npy", or compound like ".tar.zst")
+	// to format labels, merged over the built-in map. An empty label removes
+	// an extension.
+	FormatMap map[string]string
+
+	// Parallelism is how many top-level directories are walked at once
+	// (0 or less uses GOMAXPROCS)
+	Parallelism int
+
+	// ContentChecksum hashes file contents into the dataset checksum instead
+	// of only paths, sizes and mtimes. It reads every byte of every dataset
+	// that is walked.
+	ContentChecksum bool
+
+	// Cache reuses a dataset's previous result while its directory's mtime
+	// is unchanged, instead of walking it again. Files changed below the
+	// top level go unnoticed until the next full rescan, which happens on
+	// the first scan and every FullRescanInterval (0 means never again).
+	Cache              bool
+	FullRescanInterval time.Duration
+}
+
+// defaultFormats maps file extensions to the format labels reported for them.
+var defaultFormats = map[string]string{
+	".csv":         "csv",
+	".parquet":     "parquet",
+	".json":        "json",
+	".jsonl":       "jsonl",
+	".tfrecord":    "tfrecord",
+	".tar":         "archive",
+	".tar.gz":      "archive",
+	".tar.bz2":     "archive",
+	".tar.xz":      "archive",
+	".tgz":         "archive",
+	".zip":         "archive",
+	".7z":          "archive",
+	".gz":          "archive",
+	".jpg":         "images",
+	".jpeg":        "images",
+	".png":         "images",
+	".gif":         "images",
+	".bmp":         "images",
+	".tiff":        "images",
+	".npy":         "numpy",
+	".npz":         "numpy",
+	".safetensors": "safetensors",
+	".pt":          "pytorch",
+	".pth":         "pytorch",
+	".h5":          "hdf5",
+}
+
+// incompleteMarkers are files that mark a dataset directory as still being
+// written; such datasets are skipped until the marker is removed.
+var incompleteMarkers = []string{".uploading", ".incomplete"}
+
 // Scanner scans directories for datasets.
 type Scanner struct {
+	opts      Options
 	formatMap map[string]string
+	compound  []string // extensions with more than one dot, e.g. .tar.gz
+
+	mu        sync.Mutex
+	available map[string]bool // base paths that have been read successfully
+
+	// Results of earlier scans; see cache.go
+	cache      map[string]cachedDataset
+	generation int
+	fullScan   bool
+	lastFull   time.Time
 }
 
 // NewScanner creates a new dataset scanner.
-func NewScanner() *Scanner {
+func NewScanner(opts Options) *Scanner {
+	formats := maps.Clone(defaultFormats)
+	for ext, label := range opts.FormatMap {
+		ext = strings.ToLower(ext)
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if label == "" {
+			delete(formats, ext)
+		} else {
+			formats[ext] = label
+		}
+	}
+
+	var compound []string
+	for ext := range formats {
+		if strings.Count(ext, ".") > 1 {
+			compound = append(compound, ext)
+		}
+	}
+	// Longest first, so .tar.gz is matched before a shorter .gz suffix rule
+	sort.Slice(compound, func(i, j int) bool { return len(compound[i]) > len(compound[j]) })
+
 	return &Scanner{
-		formatMap: map[string]string{
-			".csv":      "csv",
-			".parquet":  "parquet",
-			".json":     "json",
-			".jsonl":    "jsonl",
-			".tfrecord": "tfrecord",
-			".tar":      "archive",
-			".tar.gz":   "archive",
-			".tgz":      "archive",
-			".zip":      "archive",
-			".jpg":      "images",
-			".jpeg":     "images",
-			".png":      "images",
-			".gif":      "images",
-			".bmp":      "images",
-			".tiff":     "images",
-		},
+		opts:      opts,
+		available: make(map[string]bool),
+		formatMap: formats,
+		compound:  compound,
 	}
 }
 
 // Scan scans the base path for datasets.
-// Each subdirectory is treated as a separate dataset.
-func (s *Scanner) Scan(basePath string) []client.DatasetInfo {
+// Each subdirectory is treated as a separate dataset. An error wrapping
+// ErrUnavailable means the path couldn't be read right now and its datasets
+// should not be considered gone.
+func (s *Scanner) Scan(basePath string) ([]client.DatasetInfo, error) {
 	var datasets []client.DatasetInfo
 
+	absBase, _ := filepath.Abs(basePath)
+	if mp, ok := unmountedMount(absBase); ok {
+		return nil, fmt.Errorf("%w: %s is on %s, which is not mounted", ErrUnavailable, basePath, mp)
+	}
+
 	// Check if path exists
-	if _, err := os.Stat(basePath); os.IsNotExist(err) {
-		fmt.Printf("[WARN] Dataset path does not exist: %s\n", basePath)
-		return datasets
+	if _, err := os.Stat(basePath); err != nil {
+		if os.IsNotExist(err) && !s.wasAvailable(basePath) {
+			slog.Warn("Dataset path does not exist", "path", basePath)
+			return datasets, nil
+		}
+		// A path that used to exist, or a stale/unreachable mount
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
 
 	// List directories in base path
 	entries, err := os.ReadDir(basePath)
 	if err != nil {
-		fmt.Printf("[ERROR] Failed to read dataset path: %v\n", err)
-		return datasets
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
+	s.markAvailable(basePath)
 
+	var names []string
 	for _, entry := range entries {
 		// Skip hidden directories and files
 		if strings.HasPrefix(entry.Name(), ".") {
@@ -66,23 +182,297 @@ func (s *Scanner) Scan(basePath string) []client.DatasetInfo {
 			continue
 		}
 
-		dirPath := filepath.Join(basePath, entry.Name())
-		dataset := s.scanDirectory(dirPath, entry.Name())
-		if dataset != nil {
-			datasets = append(datasets, *dataset)
+		names = append(names, entry.Name())
+	}
+
+	// Walk the top-level directories in a bounded pool. Each writes only its
+	// own slot, so the results keep the sorted directory order.
+	found := make([][]client.DatasetInfo, len(names))
+	workers := s.opts.Parallelism
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	sem := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			found[i] = s.scanTree(basePath, filepath.Join(basePath, name), name, 1)
+		}()
+	}
+	wg.Wait()
+
+	for _, ds := range found {
+		datasets = append(datasets, ds...)
+	}
+	return datasets, nil
+}
+
+// scanTree scans a directory depth levels below the base path, named by its
+// path relative to the base. A manifest always decides its directory's
+// datasets. Below MaxDepth, a directory that directly holds data files (of a
+// known format) is one dataset including everything beneath it: the parent
+// wins over its subdirectories. Otherwise each subdirectory is scanned in
+// turn, and a leaf directory is a dataset if it holds any files.
+func (s *Scanner) scanTree(basePath, dirPath, name string, depth int) []client.DatasetInfo {
+	// A manifest splits the directory into several datasets
+	if manifest, err := readManifest(dirPath); err != nil {
+		slog.Warn("Ignoring invalid manifest", "manifest", manifestName, "dir", dirPath, "error", err)
+	} else if manifest != nil {
+		return s.scanManifest(basePath, dirPath, manifest)
+	}
+
+	whole := func() []client.DatasetInfo {
+		if dataset := s.scanDirectory(basePath, dirPath, name); dataset != nil {
+			return []client.DatasetInfo{*dataset}
+		}
+		return nil
+	}
+	if depth >= s.opts.MaxDepth {
+		return whole()
+	}
+
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		slog.Warn("Failed to read dataset directory", "dir", dirPath, "error", err)
+		return nil
+	}
+
+	var subdirs []string
+	hasFiles, hasData := false, false
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.IsDir() {
+			subdirs = append(subdirs, entry.Name())
+			continue
+		}
+		hasFiles = true
+		if s.fileFormat(entry.Name()) != "" {
+			hasData = true
+		}
+	}
+
+	switch {
+	case hasData:
+		return whole()
+	case len(subdirs) == 0:
+		if !hasFiles {
+			return nil
 		}
+		return whole()
 	}
 
+	// os.ReadDir sorts entries, so nested datasets come out in a stable order
+	var datasets []client.DatasetInfo
+	for _, sub := range subdirs {
+		datasets = append(datasets, s.scanTree(basePath, filepath.Join(dirPath, sub), name+"/"+sub, depth+1)...)
+	}
 	return datasets
 }
 
+// fileFormat returns the dataset format of a file name, or "" if unknown.
+func (s *Scanner) fileFormat(fileName string) string {
+	fileName = strings.ToLower(fileName)
+
+	// Check for compound extensions like .tar.gz
+	for _, ext := range s.compound {
+		if strings.HasSuffix(fileName, ext) {
+			return s.formatMap[ext]
+		}
+	}
+	return s.formatMap[filepath.Ext(fileName)]
+}
+
+// wasAvailable reports whether basePath has been read successfully before.
+func (s *Scanner) wasAvailable(basePath string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.available[basePath]
+}
+
+func (s *Scanner) markAvailable(basePath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.available[basePath] = true
+}
+
+// manifestName is the file that declares multiple datasets in one directory.
+const manifestName = "datasets.json"
+
+// ManifestEntry declares one dataset within a directory, e.g.
+//
+//	{"datasets": [{"name": "corpus-train", "path": "train", "format": "jsonl"}]}
+type ManifestEntry struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// readManifest loads a directory's manifest; it returns nil when there is none.
+func readManifest(dirPath string) ([]ManifestEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dirPath, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var manifest struct {
+		Datasets []ManifestEntry `json:"datasets"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest.Datasets) == 0 {
+		return nil, fmt.Errorf("no datasets declared")
+	}
+	return manifest.Datasets, nil
+}
+
+// scanManifest scans each dataset a manifest declares. Entries whose path
+// leaves the directory are skipped.
+func (s *Scanner) scanManifest(basePath, dirPath string, entries []ManifestEntry) []client.DatasetInfo {
+	var datasets []client.DatasetInfo
+	for _, entry := range entries {
+		if entry.Name == "" || entry.Path == "" {
+			slog.Warn("Skipping manifest entry without name or path", "manifest", manifestName, "dir", dirPath)
+			continue
+		}
+
+		subPath, err := fileops.ValidatePath(dirPath, entry.Path)
+		if err != nil {
+			slog.Warn("Skipping manifest entry", "manifest", manifestName, "entry", entry.Name, "error", err)
+			continue
+		}
+		if info, err := os.Stat(subPath); err != nil || !info.IsDir() {
+			slog.Warn("Skipping manifest entry, not a directory", "manifest", manifestName, "entry", entry.Name, "path", subPath)
+			continue
+		}
+
+		// The manifest's values take precedence over a sidecar in the subdirectory
+		dataset := s.scanDirectory(basePath, subPath, entry.Name)
+		if dataset == nil {
+			continue
+		}
+		dataset.Name = entry.Name
+		if entry.Format != "" {
+			format := entry.Format
+			dataset.Format = &format
+		}
+		if entry.Description != "" {
+			description := entry.Description
+			dataset.Description = &description
+		}
+		datasets = append(datasets, *dataset)
+	}
+	return datasets
+}
+
+// ScanAll scans several base paths. With more than one base, dataset names
+// are prefixed with a label for their base so equal names on different
+// mounts don't collide. Datasets from available bases are returned even
+// when others are unavailable.
+func (s *Scanner) ScanAll(basePaths []string) ([]client.DatasetInfo, error) {
+	s.beginScan()
+
+	datasets, err := s.scanAll(basePaths)
+	if err == nil {
+		// Datasets on unavailable paths stay cached for when they return
+		s.endScan()
+	}
+	return datasets, err
+}
+
+func (s *Scanner) scanAll(basePaths []string) ([]client.DatasetInfo, error) {
+	if len(basePaths) == 1 {
+		return s.Scan(basePaths[0])
+	}
+
+	var datasets []client.DatasetInfo
+	var errs []error
+	labels := baseLabels(basePaths)
+	for i, basePath := range basePaths {
+		found, err := s.Scan(basePath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, dataset := range found {
+			dataset.Name = labels[i] + "/" + dataset.Name
+			datasets = append(datasets, dataset)
+		}
+	}
+	return datasets, errors.Join(errs...)
+}
+
+// baseLabels returns the shortest trailing path components that tell the
+// base paths apart, e.g. "data1/datasets" and "data2/datasets".
+func baseLabels(basePaths []string) []string {
+	parts := make([][]string, len(basePaths))
+	for i, p := range basePaths {
+		parts[i] = strings.Split(strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/"), "/")
+	}
+
+	labels := make([]string, len(basePaths))
+	for n := 1; ; n++ {
+		seen := make(map[string]int)
+		exhausted := true
+		for i, p := range parts {
+			start := max(len(p)-n, 0)
+			if start > 0 {
+				exhausted = false
+			}
+			labels[i] = strings.Join(p[start:], "/")
+			seen[labels[i]]++
+		}
+
+		unique := true
+		for _, count := range seen {
+			if count > 1 {
+				unique = false
+			}
+		}
+		if unique || exhausted {
+			return labels
+		}
+	}
+}
+
 // scanDirectory scans a single directory as a dataset.
-func (s *Scanner) scanDirectory(path, name string) *client.DatasetInfo {
+func (s *Scanner) scanDirectory(basePath, path, name string) *client.DatasetInfo {
+	for _, marker := range incompleteMarkers {
+		if _, err := os.Stat(filepath.Join(path, marker)); err == nil {
+			slog.Info("Skipping incomplete dataset", "path", path, "marker", marker)
+			return nil
+		}
+	}
+
+	dirInfo, err := os.Stat(path)
+	if err != nil {
+		slog.Error("Error scanning directory", "path", path, "error", err)
+		return nil
+	}
+	if dataset, ok := s.cached(path, dirInfo.ModTime()); ok {
+		return dataset
+	}
+
 	var totalSize int64
 	var fileCount int
+	var lastModified time.Time
 	formatCounts := make(map[string]int)
 
-	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+	// Walk visits files in lexical order, so the checksum is stable
+	sum := sha256.New()
+
+	err = filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip errors, continue walking
 		}
@@ -91,25 +481,50 @@ func (s *Scanner) scanDirectory(path, name string) *client.DatasetInfo {
 			return nil
 		}
 
+		// Metadata about the dataset isn't part of it
+		if filepath.Dir(filePath) == path && slices.Contains(sidecarNames, info.Name()) {
+			return nil
+		}
+
 		fileCount++
 		totalSize += info.Size()
+		if info.ModTime().After(lastModified) {
+			lastModified = info.ModTime()
+		}
 
 		// Detect format
-		ext := strings.ToLower(filepath.Ext(filePath))
-		fileName := strings.ToLower(info.Name())
-
-		// Check for compound extensions like .tar.gz
-		if strings.HasSuffix(fileName, ".tar.gz") {
-			formatCounts["archive"]++
-		} else if format, ok := s.formatMap[ext]; ok {
+		if format := s.fileFormat(info.Name()); format != "" {
 			formatCounts[format]++
 		}
 
+		rel, _ := filepath.Rel(path, filePath)
+		if s.opts.ContentChecksum {
+			digest, _, err := fileops.Checksum(context.Background(), filePath, "sha256")
+			if err != nil {
+				digest = "unreadable"
+			}
+			fmt.Fprintf(sum, "%s\x00%s\n", filepath.ToSlash(rel), digest)
+		} else {
+			fmt.Fprintf(sum, "%s\x00%d\x00%d\n", filepath.ToSlash(rel), info.Size(), info.ModTime().UnixNano())
+		}
+
 		return nil
 	})
 
 	if err != nil {
-		fmt.Printf("[ERROR] Error scanning directory %s: %v\n", path, err)
+		slog.Error("Error scanning directory", "path", path, "error", err)
+		return nil
+	}
+
+	// Recently written files suggest a copy in progress; report a new dataset
+	// once settled. One reported before keeps its last result meanwhile, so
+	// appending to it doesn't make it look removed.
+	if s.opts.SettleWindow > 0 && time.Since(lastModified) < s.opts.SettleWindow {
+		if dataset, ok := s.previous(path); ok {
+			return dataset
+		}
+		slog.Info("Skipping dataset, still being written",
+			"path", path, "last_change_ago", time.Since(lastModified).Round(time.Second).String())
 		return nil
 	}
 
@@ -125,14 +540,43 @@ func (s *Scanner) scanDirectory(path, name string) *client.DatasetInfo {
 	}
 
 	absPath, _ := filepath.Abs(path)
+	localPath := absPath
+	if s.opts.RelativePaths {
+		if rel, err := filepath.Rel(basePath, path); err == nil {
+			localPath = rel
+		}
+	}
 	description := fmt.Sprintf("Auto-scanned dataset with %d files", fileCount)
+	checksum := "meta-sha256:" + hex.EncodeToString(sum.Sum(nil))
+	if s.opts.ContentChecksum {
+		checksum = "sha256:" + hex.EncodeToString(sum.Sum(nil))
+	}
+
+	// Fields from a sidecar replace the guesses above
+	if sidecar := readSidecar(path); sidecar != nil {
+		if sidecar.Name != "" {
+			name = sidecar.Name
+		}
+		if sidecar.Description != "" {
+			description = sidecar.Description
+		}
+		if sidecar.Format != "" {
+			format := sidecar.Format
+			primaryFormat = &format
+		}
+	}
 
-	return &client.DatasetInfo{
-		Name:        name,
-		LocalPath:   absPath,
-		SizeBytes:   &totalSize,
-		FileCount:   &fileCount,
-		Format:      primaryFormat,
-		Description: &description,
+	dataset := &client.DatasetInfo{
+		Name:         name,
+		LocalPath:    localPath,
+		AbsolutePath: absPath,
+		SourceMount:  basePath,
+		SizeBytes:    &totalSize,
+		FileCount:    &fileCount,
+		Format:       primaryFormat,
+		Description:  &description,
+		Checksum:     checksum,
 	}
+	s.store(path, dirInfo.ModTime(), *dataset)
+	return dataset
 }
@@ -0,0 +1,142 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/client"
+)
+
+// BenchmarkScan walks many sibling datasets, serially and with the default
+// pool, to show the speedup from parallel scanning.
+func BenchmarkScan(b *testing.B) {
+	const datasets, filesPerDataset = 64, 50
+
+	base := b.TempDir()
+	for i := range datasets {
+		dir := filepath.Join(base, fmt.Sprintf("dataset_%03d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		for j := range filesPerDataset {
+			name := filepath.Join(dir, fmt.Sprintf("part_%03d.csv", j))
+			if err := os.WriteFile(name, []byte("a,b\n1,2\n"), 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	for _, bm := range []struct {
+		name        string
+		parallelism int
+	}{
+		{"serial", 1},
+		{fmt.Sprintf("gomaxprocs=%d", runtime.GOMAXPROCS(0)), 0},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			s := NewScanner(Options{Parallelism: bm.parallelism, ContentChecksum: true})
+			for b.Loop() {
+				found, err := s.Scan(base)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(found) != datasets {
+					b.Fatalf("found %d datasets, want %d", len(found), datasets)
+				}
+			}
+		})
+	}
+}
+
+func TestFileFormat(t *testing.T) {
+	s := NewScanner(Options{})
+	tests := map[string]string{
+		"data.tar":      "archive",
+		"data.tar.gz":   "archive",
+		"data.tgz":      "archive",
+		"data.tar.bz2":  "archive",
+		"data.tar.xz":   "archive",
+		"data.7z":       "archive",
+		"data.gz":       "archive",
+		"DATA.TAR.XZ":   "archive",
+		"train.csv":     "csv",
+		"weights.pt":    "pytorch",
+		"notes.txt":     "",
+		"archive.bz2":   "",
+		"data.tar.zst":  "",
+		"no_extension":  "",
+		"images.tar.7z": "archive",
+	}
+	for name, want := range tests {
+		if got := s.fileFormat(name); got != want {
+			t.Errorf("fileFormat(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// A compound extension must win over the bare suffix it ends with, so
+// .tar.gz isn't reported under a differently labelled .gz.
+func TestFileFormatCompoundBeforeSuffix(t *testing.T) {
+	s := NewScanner(Options{FormatMap: map[string]string{".gz": "gzip"}})
+	tests := map[string]string{
+		"x.tar.gz": "archive",
+		"x.csv.gz": "gzip",
+		"x.gz":     "gzip",
+	}
+	for name, want := range tests {
+		if got := s.fileFormat(name); got != want {
+			t.Errorf("fileFormat(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSettleWindowKeepsKnownDatasets(t *testing.T) {
+	base := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	write := func(name string, modTime time.Time) {
+		t.Helper()
+		path := filepath.Join(base, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("1,2\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(found []client.DatasetInfo) []string {
+		var out []string
+		for _, d := range found {
+			out = append(out, d.Name)
+		}
+		return out
+	}
+
+	write("known/a.csv", old)
+	s := NewScanner(Options{SettleWindow: time.Minute})
+	found, err := s.Scan(base)
+	if err != nil || !slices.Equal(names(found), []string{"known"}) {
+		t.Fatalf("first scan: %v, %v", names(found), err)
+	}
+
+	// Appending to a known dataset and copying in a new one, both just now
+	write("known/b.csv", time.Now())
+	write("incoming/a.csv", time.Now())
+	found, err = s.Scan(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names(found), []string{"known"}) {
+		t.Fatalf("scan while writing: %v, want only the known dataset", names(found))
+	}
+	if *found[0].FileCount != 1 {
+		t.Errorf("known dataset reported with %d files, want its settled result of 1", *found[0].FileCount)
+	}
+}
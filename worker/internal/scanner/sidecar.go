@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sidecarNames are the metadata files looked for in a dataset directory, in
+// order; the first one present is used.
+var sidecarNames = []string{"dataset.yaml", "dataset.yml", ".mlsmeta.json"}
+
+// Sidecar is human-authored metadata kept in a dataset directory, e.g. a
+// dataset.yaml of
+//
+//	name: imagenet-2012
+//	description: ILSVRC 2012 training and validation images
+//	format: images
+//
+// or the same keys in .mlsmeta.json. Every field is optional; omitted ones
+// keep the scanner's own values. Other keys are ignored.
+type Sidecar struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	Format      string `yaml:"format" json:"format"`
+}
+
+// readSidecar loads a dataset directory's sidecar. It returns nil when there
+// is none or it is malformed; a malformed one is logged.
+func readSidecar(dirPath string) *Sidecar {
+	for _, name := range sidecarNames {
+		path := filepath.Join(dirPath, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				slog.Warn("Ignoring unreadable sidecar", "path", path, "error", err)
+			}
+			continue
+		}
+
+		var sidecar Sidecar
+		if strings.HasSuffix(name, ".json") {
+			err = json.Unmarshal(data, &sidecar)
+		} else {
+			err = yaml.Unmarshal(data, &sidecar)
+		}
+		if err != nil {
+			slog.Warn("Ignoring malformed sidecar", "path", path, "error", err)
+			return nil
+		}
+		return &sidecar
+	}
+	return nil
+}
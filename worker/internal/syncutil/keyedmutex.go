@@ -0,0 +1,75 @@
+// Package syncutil provides synchronization helpers for the worker agent.
+package syncutil
+
+import "sync"
+
+// KeyedMutex is a set of mutexes addressed by key, e.g. one per project path.
+// Entries exist only while a key is locked or waited on, so the set doesn't
+// grow with every key ever used. The zero value is ready to use.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int // holders plus waiters
+}
+
+// acquire returns the entry for key with its reference count incremented.
+// k.mu must be held.
+func (k *KeyedMutex) acquire(key string) *keyedLock {
+	if k.locks == nil {
+		k.locks = make(map[string]*keyedLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// release drops a reference and removes the entry once unused. k.mu must be held.
+func (k *KeyedMutex) release(key string, l *keyedLock) {
+	l.refs--
+	if l.refs == 0 {
+		delete(k.locks, key)
+	}
+}
+
+// Lock locks key, waiting until it is available.
+func (k *KeyedMutex) Lock(key string) {
+	k.mu.Lock()
+	l := k.acquire(key)
+	k.mu.Unlock()
+
+	l.mu.Lock()
+}
+
+// TryLock locks key if it is free and reports whether it did.
+func (k *KeyedMutex) TryLock(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	l := k.acquire(key)
+	if !l.mu.TryLock() {
+		k.release(key, l)
+		return false
+	}
+	return true
+}
+
+// Unlock unlocks key. It panics if key is not locked.
+func (k *KeyedMutex) Unlock(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	l, ok := k.locks[key]
+	if !ok {
+		panic("syncutil: unlock of unlocked key " + key)
+	}
+	k.release(key, l)
+	l.mu.Unlock()
+}
@@ -0,0 +1,106 @@
+package syncutil
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestKeyedMutexExcludesSameKey(t *testing.T) {
+	var k KeyedMutex
+	var wg sync.WaitGroup
+
+	// Each counter is only guarded by its key's lock
+	a, b := new(int), new(int)
+	for range 50 {
+		for key, n := range map[string]*int{"a": a, "b": b} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				k.Lock(key)
+				*n++ // the race detector flags this if two holders overlap
+				k.Unlock(key)
+			}()
+		}
+	}
+	wg.Wait()
+
+	if *a != 50 || *b != 50 {
+		t.Fatalf("counts = %d, %d, want 50 each", *a, *b)
+	}
+	if len(k.locks) != 0 {
+		t.Errorf("%d lock entries left after all keys were unlocked", len(k.locks))
+	}
+}
+
+func TestKeyedMutexTryLock(t *testing.T) {
+	var k KeyedMutex
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	held, got := 0, 0
+
+	// Of many concurrent attempts on a held key, none succeed
+	if !k.TryLock("path") {
+		t.Fatal("TryLock of a free key failed")
+	}
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if k.TryLock("path") {
+				mu.Lock()
+				got++
+				mu.Unlock()
+				k.Unlock("path")
+			}
+		}()
+	}
+	wg.Wait()
+	if got != 0 {
+		t.Fatalf("TryLock succeeded %d times on a held key", got)
+	}
+	if !k.TryLock("other") {
+		t.Fatal("TryLock of a different key failed while path was held")
+	}
+	k.Unlock("other")
+	k.Unlock("path")
+
+	// Once free, concurrent attempts succeed one holder at a time
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !k.TryLock("path") {
+				return
+			}
+			mu.Lock()
+			held++
+			if held > 1 {
+				t.Error("two holders of the same key")
+			}
+			got++
+			mu.Unlock()
+
+			mu.Lock()
+			held--
+			mu.Unlock()
+			k.Unlock("path")
+		}()
+	}
+	wg.Wait()
+	if got == 0 {
+		t.Fatal("no TryLock succeeded on a free key")
+	}
+	if len(k.locks) != 0 {
+		t.Errorf("%d lock entries left after all keys were unlocked", len(k.locks))
+	}
+}
+
+func TestKeyedMutexUnlockUnlockedPanics(t *testing.T) {
+	var k KeyedMutex
+	defer func() {
+		if recover() == nil {
+			t.Error("Unlock of an unlocked key did not panic")
+		}
+	}()
+	k.Unlock("never-locked")
+}
@@ -0,0 +1,77 @@
+package sysinfo
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cudaVersionPattern matches the "CUDA Version: 12.4" field of nvidia-smi's header.
+var cudaVersionPattern = regexp.MustCompile(`CUDA Version:\s*([0-9][0-9.]*)`)
+
+// Driver versions only change with a driver reload, so they are read once
+// and kept after the first successful query.
+var cudaVersions struct {
+	mu     sync.Mutex
+	driver *string
+	cuda   *string
+}
+
+// getCUDAVersions returns the NVIDIA driver version and the highest CUDA
+// version it supports. Either is nil when nvidia-smi can't report it.
+func getCUDAVersions() (driver, cuda *string) {
+	cudaVersions.mu.Lock()
+	defer cudaVersions.mu.Unlock()
+
+	if cudaVersions.driver == nil {
+		cudaVersions.driver = queryDriverVersion()
+	}
+	if cudaVersions.cuda == nil {
+		cudaVersions.cuda = queryCUDAVersion()
+	}
+	return cudaVersions.driver, cudaVersions.cuda
+}
+
+// queryDriverVersion reads the driver version of the first GPU.
+func queryDriverVersion() *string {
+	output, err := nvidiaSMI("--query-gpu=driver_version", "--format=csv,noheader")
+	if err != nil {
+		return nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if v := strings.TrimSpace(line); v != "" && v != "[N/A]" {
+			return &v
+		}
+	}
+	return nil
+}
+
+// queryCUDAVersion reads the CUDA version from nvidia-smi's header, which
+// has no query field of its own.
+func queryCUDAVersion() *string {
+	output, err := nvidiaSMI()
+	if err != nil {
+		return nil
+	}
+
+	m := cudaVersionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return nil
+	}
+	return &m[1]
+}
+
+func nvidiaSMI(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "nvidia-smi", args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
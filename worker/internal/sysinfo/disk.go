@@ -0,0 +1,127 @@
+package sysinfo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+// VolumeInfo describes the usage and health of one storage mount.
+type VolumeInfo struct {
+	Path      string `json:"path"`
+	Available bool   `json:"available"`
+	TotalGB   *int   `json:"total_gb"`
+	UsedGB    *int   `json:"used_gb"`
+	FreeGB    *int   `json:"free_gb"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DiskProbe controls how storage mounts are probed.
+type DiskProbe struct {
+	Paths       []string      // Mounts to probe besides the storage path
+	Timeout     time.Duration // Per-mount limit; slower mounts are reported unavailable
+	Concurrency int           // Mounts probed at once
+}
+
+// hungProbes holds paths whose previous probe never returned, so a stuck
+// network mount doesn't pile up a blocked goroutine every heartbeat.
+var (
+	hungMu     sync.Mutex
+	hungProbes = make(map[string]bool)
+)
+
+// ProbeVolumes reads the usage of each path concurrently. A path that does
+// not answer within the timeout is marked unavailable instead of holding up
+// the rest.
+func ProbeVolumes(paths []string, timeout time.Duration, concurrency int) []VolumeInfo {
+	results := make([]VolumeInfo, len(paths))
+	sem := make(chan struct{}, max(concurrency, 1))
+
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = probeVolume(path, timeout)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// probeVolume reads the usage of a single path, giving up after timeout.
+func probeVolume(path string, timeout time.Duration) VolumeInfo {
+	vol := VolumeInfo{Path: path}
+
+	hungMu.Lock()
+	if hungProbes[path] {
+		hungMu.Unlock()
+		vol.Error = "previous probe has not returned"
+		return vol
+	}
+	hungProbes[path] = true
+	hungMu.Unlock()
+
+	type result struct {
+		usage *disk.UsageStat
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		usage, err := disk.Usage(path)
+		hungMu.Lock()
+		delete(hungProbes, path)
+		hungMu.Unlock()
+		ch <- result{usage, err}
+	}()
+
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			vol.Error = r.err.Error()
+			return vol
+		}
+		totalGB := int(r.usage.Total / (1024 * 1024 * 1024))
+		usedGB := int(r.usage.Used / (1024 * 1024 * 1024))
+		freeGB := int(r.usage.Free / (1024 * 1024 * 1024))
+		vol.Available = true
+		vol.TotalGB = &totalGB
+		vol.UsedGB = &usedGB
+		vol.FreeGB = &freeGB
+	case <-timer.C:
+		vol.Error = fmt.Sprintf("probe timed out after %v", timeout)
+	}
+
+	return vol
+}
+
+// FreeBytes returns the space available to the agent on the volume holding
+// path. A path that doesn't exist yet is measured at its nearest existing
+// parent.
+func FreeBytes(path string) (uint64, error) {
+	for {
+		usage, err := disk.Usage(path)
+		if err == nil {
+			return usage.Free, nil
+		}
+		parent := filepath.Dir(path)
+		if !errors.Is(err, fs.ErrNotExist) || parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+}
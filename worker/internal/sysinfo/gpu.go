@@ -0,0 +1,196 @@
+package sysinfo
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GPUDetector reports the accelerators of one vendor. Detect returns a
+// human-readable description and the device count; a count of 0 means the
+// detector found nothing and the next one is tried.
+type GPUDetector interface {
+	Name() string
+	Detect() (info string, count int, err error)
+}
+
+var (
+	detectorsMu sync.Mutex
+	detectors   []GPUDetector
+
+	// builtinDetectors cover the vendors the agent knows; a node can mix them
+	builtinDetectors = []GPUDetector{NvidiaDetector{}, AMDDetector{}}
+)
+
+// RegisterGPUDetector adds a detector that runs before the built-in ones.
+func RegisterGPUDetector(d GPUDetector) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors = append([]GPUDetector{d}, detectors...)
+}
+
+// getGPUInfo runs the registered detectors until one reports devices. If
+// none does, the built-in NVIDIA and AMD detectors are tried in that order
+// and their devices combined, one line per device.
+func getGPUInfo() (string, int) {
+	detectorsMu.Lock()
+	ds := append([]GPUDetector(nil), detectors...)
+	detectorsMu.Unlock()
+
+	for _, d := range ds {
+		info, count, err := d.Detect()
+		if err != nil || count == 0 {
+			continue
+		}
+		return info, count
+	}
+
+	var infos []string
+	total := 0
+	for _, d := range builtinDetectors {
+		info, count, err := d.Detect()
+		if err != nil || count == 0 {
+			continue
+		}
+		infos = append(infos, info)
+		total += count
+	}
+	return strings.Join(infos, "\n"), total
+}
+
+// NvidiaDetector queries nvidia-smi.
+type NvidiaDetector struct{}
+
+// Name implements GPUDetector.
+func (NvidiaDetector) Name() string { return "nvidia" }
+
+// Detect implements GPUDetector.
+func (NvidiaDetector) Detect() (string, int, error) {
+	cmd := exec.Command("nvidia-smi", "--query-gpu=name,memory.total", "--format=csv,noheader")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", 0, err
+	}
+
+	outputStr := strings.TrimSpace(string(output))
+	if outputStr == "" {
+		return "", 0, nil
+	}
+
+	lines := strings.Split(outputStr, "\n")
+	count := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+
+	return outputStr, count, nil
+}
+
+// AMDDetector queries rocm-smi.
+type AMDDetector struct{}
+
+// Name implements GPUDetector.
+func (AMDDetector) Name() string { return "amd" }
+
+// Detect implements GPUDetector. Each device is reported as "name, N MiB",
+// the same shape as nvidia-smi's name,memory.total output.
+func (AMDDetector) Detect() (string, int, error) {
+	cmd := exec.Command("rocm-smi", "--showproductname", "--showmeminfo", "vram", "--csv")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Warnings may precede the CSV, whose header starts with "device"
+	text := string(output)
+	if i := strings.Index(text, "device,"); i >= 0 {
+		text = text[i:]
+	}
+	reader := csv.NewReader(strings.NewReader(strings.TrimSpace(text)))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", 0, err
+	}
+	if len(records) == 0 {
+		return "", 0, nil
+	}
+
+	// Columns vary between ROCm releases, so they are found by name
+	column := func(names ...string) int {
+		for i, h := range records[0] {
+			for _, name := range names {
+				if strings.EqualFold(strings.TrimSpace(h), name) {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	nameCol := column("Card series", "Card model")
+	vramCol := column("VRAM Total Memory (B)")
+
+	var devices []string
+	for _, record := range records[1:] {
+		if len(record) == 0 || !strings.HasPrefix(record[0], "card") {
+			continue
+		}
+		name := "AMD GPU"
+		if nameCol >= 0 && nameCol < len(record) && strings.TrimSpace(record[nameCol]) != "" {
+			name = strings.TrimSpace(record[nameCol])
+		}
+		if vramCol >= 0 && vramCol < len(record) {
+			if bytes, err := strconv.ParseUint(strings.TrimSpace(record[vramCol]), 10, 64); err == nil {
+				name = fmt.Sprintf("%s, %d MiB", name, bytes/(1024*1024))
+			}
+		}
+		devices = append(devices, name)
+	}
+
+	return strings.Join(devices, "\n"), len(devices), nil
+}
+
+// CommandDetector runs an external command for accelerators the agent has no
+// built-in support for. The command prints JSON such as
+// {"gpu_count": 8, "gpu_info": "Gaudi2, 96 GB"}.
+type CommandDetector struct {
+	Command string
+	Timeout time.Duration
+}
+
+// Name implements GPUDetector.
+func (d CommandDetector) Name() string { return "command" }
+
+// Detect implements GPUDetector.
+func (d CommandDetector) Detect() (string, int, error) {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", d.Command).Output()
+	if err != nil {
+		return "", 0, fmt.Errorf("gpu detect command failed: %w", err)
+	}
+
+	var result struct {
+		GPUCount int    `json:"gpu_count"`
+		GPUInfo  string `json:"gpu_info"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return "", 0, fmt.Errorf("invalid gpu detect command output: %w", err)
+	}
+
+	return result.GPUInfo, result.GPUCount, nil
+}
@@ -0,0 +1,71 @@
+package sysinfo
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// GPUProcess is a compute process running on a GPU, as reported by nvidia-smi.
+type GPUProcess struct {
+	PID          int32  `json:"pid"`
+	GPUUUID      string `json:"gpu_uuid"`
+	UsedMemoryMB int    `json:"used_memory_mb"`
+}
+
+// GetGPUProcesses lists compute processes using NVIDIA GPUs.
+// It returns nil when nvidia-smi is unavailable.
+func GetGPUProcesses() []GPUProcess {
+	cmd := exec.Command("nvidia-smi",
+		"--query-compute-apps=pid,used_memory,gpu_uuid",
+		"--format=csv,noheader,nounits")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var procs []GPUProcess
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			continue
+		}
+
+		pid, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 32)
+		if err != nil {
+			continue
+		}
+		mem, _ := strconv.Atoi(strings.TrimSpace(fields[1])) // "[N/A]" in some containers
+
+		procs = append(procs, GPUProcess{
+			PID:          int32(pid),
+			GPUUUID:      strings.TrimSpace(fields[2]),
+			UsedMemoryMB: mem,
+		})
+	}
+
+	return procs
+}
+
+// ParentPIDs returns a snapshot of the parent of every process on the host.
+func ParentPIDs() map[int32]int32 {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil
+	}
+
+	parents := make(map[int32]int32, len(pids))
+	for _, pid := range pids {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		if ppid, err := p.Ppid(); err == nil {
+			parents[pid] = ppid
+		}
+	}
+
+	return parents
+}
@@ -0,0 +1,70 @@
+package sysinfo
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GPUStat is the live state of one NVIDIA GPU.
+type GPUStat struct {
+	Index              int    `json:"index"`
+	UUID               string `json:"uuid"`
+	Name               string `json:"name"`
+	MemoryUsedMB       int    `json:"memory_used_mb"`
+	MemoryTotalMB      int    `json:"memory_total_mb"`
+	UtilizationPercent int    `json:"utilization_percent"`
+}
+
+// MemoryFreeMB returns the GPU memory not in use.
+func (g GPUStat) MemoryFreeMB() int {
+	return max(g.MemoryTotalMB-g.MemoryUsedMB, 0)
+}
+
+// GetGPUStats queries the memory and utilization of each NVIDIA GPU.
+// It returns nil when nvidia-smi is unavailable.
+func GetGPUStats() []GPUStat {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "nvidia-smi",
+		"--query-gpu=index,uuid,name,memory.used,memory.total,utilization.gpu",
+		"--format=csv,noheader,nounits")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var stats []GPUStat
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 6 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		// Unsupported readings come back as "[N/A]" and are left at 0
+		used, _ := strconv.Atoi(fields[3])
+		total, _ := strconv.Atoi(fields[4])
+		util, _ := strconv.Atoi(fields[5])
+
+		stats = append(stats, GPUStat{
+			Index:              index,
+			UUID:               fields[1],
+			Name:               fields[2],
+			MemoryUsedMB:       used,
+			MemoryTotalMB:      total,
+			UtilizationPercent: util,
+		})
+	}
+
+	return stats
+}
@@ -2,12 +2,10 @@
 package sysinfo
 
 import (
-	"os/exec"
 	"runtime"
-	"strings"
+	"slices"
 
 	"github.com/shirou/gopsutil/v4/cpu"
-	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/mem"
 )
 
@@ -19,10 +17,22 @@ type SystemInfo struct {
 	GPUInfo        *string `json:"gpu_info"`
 	StorageTotalGB *int    `json:"storage_total_gb"`
 	StorageUsedGB  *int    `json:"storage_used_gb"`
+
+	// NVIDIA driver version and the highest CUDA version it supports; nil
+	// without NVIDIA GPUs
+	DriverVersion *string `json:"driver_version,omitempty"`
+	CUDAVersion   *string `json:"cuda_version,omitempty"`
+
+	// Per-mount usage, the storage path first
+	Volumes []VolumeInfo `json:"volumes"`
+
+	// Live memory and utilization per NVIDIA GPU; empty without nvidia-smi
+	GPUs []GPUStat `json:"gpus"`
 }
 
-// Collect gathers system information.
-func Collect(storagePath string) *SystemInfo {
+// Collect gathers system information. Storage totals come from the storage
+// path; it and the probe's extra paths are reported per mount.
+func Collect(storagePath string, probe DiskProbe) *SystemInfo {
 	info := &SystemInfo{
 		CPUCount: runtime.NumCPU(),
 		GPUCount: 0,
@@ -34,45 +44,30 @@ func Collect(storagePath string) *SystemInfo {
 		info.MemoryTotalGB = &memGB
 	}
 
-	// GPU info via nvidia-smi
+	// GPU info from the first detector that finds devices
 	if gpuInfo, gpuCount := getGPUInfo(); gpuCount > 0 {
 		info.GPUCount = gpuCount
 		info.GPUInfo = &gpuInfo
 	}
-
-	// Storage info
-	if usage, err := disk.Usage(storagePath); err == nil {
-		totalGB := int(usage.Total / (1024 * 1024 * 1024))
-		usedGB := int(usage.Used / (1024 * 1024 * 1024))
-		info.StorageTotalGB = &totalGB
-		info.StorageUsedGB = &usedGB
+	info.GPUs = GetGPUStats()
+	if info.GPUs == nil {
+		info.GPUs = []GPUStat{}
+	} else {
+		info.DriverVersion, info.CUDAVersion = getCUDAVersions()
 	}
 
-	return info
-}
-
-// getGPUInfo queries nvidia-smi for GPU information.
-func getGPUInfo() (string, int) {
-	cmd := exec.Command("nvidia-smi", "--query-gpu=name,memory.total", "--format=csv,noheader")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", 0
-	}
-
-	outputStr := strings.TrimSpace(string(output))
-	if outputStr == "" {
-		return "", 0
-	}
-
-	lines := strings.Split(outputStr, "\n")
-	count := 0
-	for _, line := range lines {
-		if strings.TrimSpace(line) != "" {
-			count++
+	// Storage info
+	paths := []string{storagePath}
+	for _, p := range probe.Paths {
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
 		}
 	}
+	info.Volumes = ProbeVolumes(paths, probe.Timeout, probe.Concurrency)
+	info.StorageTotalGB = info.Volumes[0].TotalGB
+	info.StorageUsedGB = info.Volumes[0].UsedGB
 
-	return outputStr, count
+	return info
 }
 
 // GetCPUUsage returns current CPU usage percentage.
@@ -0,0 +1,102 @@
+// Package tlsutil builds TLS configurations that follow the agent's TLS policy.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/YangYuS8/mlsmanager-worker/internal/config"
+)
+
+// versions maps configured version names to TLS versions. Older versions are
+// deliberately absent so they are rejected as weak.
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// New returns a tls.Config enforcing the configured minimum version and
+// cipher suite allowlist. Unknown, insecure or too-old settings are rejected.
+func New(cfg *config.Config) (*tls.Config, error) {
+	minVersion, ok := versions[strings.TrimPrefix(cfg.TLSMinVersion, "TLS")]
+	if !ok {
+		return nil, fmt.Errorf("unsupported AGENT_TLS_MIN_VERSION %q (use 1.2 or 1.3)", cfg.TLSMinVersion)
+	}
+
+	tlsCfg := &tls.Config{MinVersion: minVersion}
+
+	if len(cfg.TLSCipherSuites) > 0 {
+		ids, err := cipherSuiteIDs(cfg.TLSCipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		// Go doesn't allow configuring TLS 1.3 suites; this applies to 1.2 connections
+		tlsCfg.CipherSuites = ids
+	}
+
+	return tlsCfg, nil
+}
+
+// NewMasterClient returns the tls.Config for connections to the master: the
+// agent's TLS policy plus the configured CA bundle. A CA file that can't be
+// read or holds no certificates is an error, never a silent fallback to the
+// system roots.
+func NewMasterClient(cfg *config.Config) (*tls.Config, error) {
+	tlsCfg, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MasterCAFile != "" {
+		pem, err := os.ReadFile(cfg.MasterCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AGENT_MASTER_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("AGENT_MASTER_CA_FILE %s contains no PEM certificates", cfg.MasterCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.MasterInsecureSkipVerify {
+		slog.Warn("TLS verification of the master is disabled (AGENT_MASTER_INSECURE_SKIP_VERIFY)")
+		tlsCfg.InsecureSkipVerify = true
+	}
+
+	return tlsCfg, nil
+}
+
+// cipherSuiteIDs resolves cipher suite names, rejecting insecure ones.
+func cipherSuiteIDs(names []string) ([]uint16, error) {
+	secure := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		secure[cs.Name] = cs.ID
+	}
+	insecure := make(map[string]bool)
+	for _, cs := range tls.InsecureCipherSuites() {
+		insecure[cs.Name] = true
+	}
+
+	var ids []uint16
+	var problems []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if id, ok := secure[name]; ok {
+			ids = append(ids, id)
+		} else if insecure[name] {
+			problems = append(problems, name+" is insecure")
+		} else {
+			problems = append(problems, name+" is unknown")
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid AGENT_TLS_CIPHER_SUITES: %s", strings.Join(problems, ", "))
+	}
+	return ids, nil
+}
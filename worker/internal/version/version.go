@@ -0,0 +1,42 @@
+// Package version holds the agent's build information.
+package version
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/YangYuS8/mlsmanager-worker/internal/version.Version=1.1.0"
+var (
+	Version = "1.0.0"
+	Commit  = ""
+)
+
+func init() {
+	// Fall back to the VCS revision Go embeds when building from a checkout
+	if Commit != "" {
+		return
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+				Commit = s.Value[:12]
+			}
+		}
+	}
+}
+
+// String returns the version with the commit, if known.
+func String() string {
+	if Commit == "" {
+		return Version
+	}
+	return fmt.Sprintf("%s (%s)", Version, Commit)
+}
+
+// UserAgent returns the User-Agent the agent sends to the master.
+func UserAgent(nodeName string) string {
+	return fmt.Sprintf("mlsmanager-worker/%s (node %s)", Version, nodeName)
+}